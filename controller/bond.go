@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bondSlaves 返回bond接口的成员接口，接口不是bond时返回nil
+func bondSlaves(name string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join("/sys/class/net", name, "bonding", "slaves"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read slaves of bond %s: %v", name, err)
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// resolvePF 返回实际拥有VF的物理PF：父接口不是bond时直接返回父接口，
+// 是bond时按bonding/slaves的顺序返回第一个存在该VF（virtfn<index>）的成员PF
+func resolvePF(parent string, vfIndex int) (string, error) {
+	slaves, err := bondSlaves(parent)
+	if err != nil {
+		return "", err
+	}
+	if len(slaves) == 0 {
+		return parent, nil
+	}
+	for _, slave := range slaves {
+		if _, err := os.Stat(sriovNumVFsPath(slave)); err != nil {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join("/sys/class/net", slave, "device", fmt.Sprintf("virtfn%d", vfIndex))); err == nil {
+			return slave, nil
+		}
+	}
+	return "", fmt.Errorf("no SR-IOV slave of bond %s (%s) has VF %d", parent, strings.Join(slaves, ", "), vfIndex)
+}
+
+// vfIFName 返回端口VF的接口名<pf>v<index>，父接口为bond时使用拥有该VF的成员PF，无法解析时退回父接口
+func vfIFName(parent string, vfIndex int) string {
+	if pf, err := resolvePF(parent, vfIndex); err == nil {
+		parent = pf
+	}
+	return fmt.Sprintf("%sv%d", parent, vfIndex)
+}
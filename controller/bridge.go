@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	bridgeTypeLinux = "linux"
+	bridgeTypeOVS   = "ovs"
+)
+
+// bridgeBackend 抽象网桥操作，默认使用Linux网桥，也可以使用Open vSwitch
+type bridgeBackend interface {
+	// EnsureBridge 确保网桥存在并处于up状态，返回网桥对应的网络接口
+	EnsureBridge(brName string, mtu int, promiscMode, vlanFiltering bool) (netlink.Link, error)
+	// AttachPort 将接口接入网桥
+	AttachPort(brName string, port netlink.Link) error
+	// DetachPort 将接口移出网桥，接口或网桥不存在时不报错
+	DetachPort(brName, portName string) error
+	// SetPortVlan 将网桥端口设置为指定VLAN的access端口
+	SetPortVlan(brName string, port netlink.Link, vlanID int) error
+	// PortBridge 返回接口所在的网桥名称，不在任何网桥中时返回空字符串
+	PortBridge(port netlink.Link) (string, error)
+	// DeleteBridge 删除网桥
+	DeleteBridge(brName string) error
+}
+
+// bridgeBackendFor 根据Spec.BridgeType返回网桥实现
+func bridgeBackendFor(bridgeType string) bridgeBackend {
+	if bridgeType == bridgeTypeOVS {
+		return ovsBridge{}
+	}
+	return linuxBridge{}
+}
+
+// linuxBridge 基于netlink的Linux网桥实现
+type linuxBridge struct{}
+
+func (linuxBridge) EnsureBridge(brName string, mtu int, promiscMode, vlanFiltering bool) (netlink.Link, error) {
+	return EnsureBridge(brName, mtu, promiscMode, vlanFiltering)
+}
+
+func (linuxBridge) AttachPort(brName string, port netlink.Link) error {
+	br, err := bridgeByName(brName)
+	if err != nil {
+		return err
+	}
+	if _, err := ensureLinkUp(br, "bridge"); err != nil {
+		return err
+	}
+	if err := netlink.LinkSetMaster(port, br); err != nil {
+		return fmt.Errorf("failed to attach %s (%s) to bridge %s: %v", port.Attrs().Name, linkState(port), brName, err)
+	}
+	return nil
+}
+
+func (linuxBridge) DetachPort(brName, portName string) error {
+	link, err := netlink.LinkByName(portName)
+	if err != nil {
+		return nil
+	}
+	return netlink.LinkSetNoMaster(link)
+}
+
+// SetPortVlan 在开启VLAN过滤的网桥上将端口的PVID设置为vlanID（出方向去标签），并在网桥自身上添加该VLAN
+func (linuxBridge) SetPortVlan(brName string, port netlink.Link, vlanID int) error {
+	br, err := bridgeByName(brName)
+	if err != nil {
+		return err
+	}
+
+	vid := uint16(vlanID)
+	if err := netlink.BridgeVlanAdd(port, vid, true, true, false, true); err != nil {
+		return fmt.Errorf("failed to add VLAN %d to bridge port %s: %v", vlanID, port.Attrs().Name, err)
+	}
+	// 移除默认VLAN 1，使端口只属于该VLAN
+	if vlanID != 1 {
+		if err := netlink.BridgeVlanDel(port, 1, true, true, false, true); err != nil {
+			logger.Printf("Warning: failed to remove default VLAN from %s: %v", port.Attrs().Name, err)
+		}
+	}
+	if err := netlink.BridgeVlanAdd(br, vid, false, false, true, false); err != nil && err != syscall.EEXIST {
+		return fmt.Errorf("failed to add VLAN %d to bridge %s: %v", vlanID, brName, err)
+	}
+	return nil
+}
+
+func (linuxBridge) PortBridge(port netlink.Link) (string, error) {
+	master := port.Attrs().MasterIndex
+	if master == 0 {
+		return "", nil
+	}
+	link, err := netlink.LinkByIndex(master)
+	if err != nil {
+		return "", fmt.Errorf("failed to get master of %s: %v", port.Attrs().Name, err)
+	}
+	return link.Attrs().Name, nil
+}
+
+func (linuxBridge) DeleteBridge(brName string) error {
+	link, err := netlink.LinkByName(brName)
+	if err != nil {
+		return nil
+	}
+	return netlink.LinkDel(link)
+}
+
+// ovsBridge 通过ovs-vsctl操作的Open vSwitch网桥实现
+type ovsBridge struct{}
+
+// ovsVsctl 执行ovs-vsctl命令并返回去除首尾空白的输出
+func ovsVsctl(args ...string) (string, error) {
+	out, err := exec.Command("ovs-vsctl", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("ovs-vsctl %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (ovsBridge) EnsureBridge(brName string, mtu int, promiscMode, vlanFiltering bool) (netlink.Link, error) {
+	if _, err := ovsVsctl("--may-exist", "add-br", brName); err != nil {
+		return nil, fmt.Errorf("could not add %q: %v", brName, err)
+	}
+	if _, err := ovsVsctl("set", "interface", brName, "mtu_request="+strconv.Itoa(mtu)); err != nil {
+		logger.Printf("Warning: failed to set MTU %d on OVS bridge %s: %v", mtu, brName, err)
+	}
+
+	br, err := netlink.LinkByName(brName)
+	if err != nil {
+		return nil, fmt.Errorf("could not lookup %q: %v", brName, err)
+	}
+
+	if promiscMode && br.Attrs().Promisc == 0 {
+		if err := netlink.SetPromiscOn(br); err != nil {
+			return nil, fmt.Errorf("could not set promiscuous mode on %q: %v", brName, err)
+		}
+		logger.Printf("Enabled promiscuous mode on bridge %s", brName)
+	}
+
+	// OVS网桥始终支持VLAN，无需单独开启VLAN过滤
+	if err := netlink.LinkSetUp(br); err != nil {
+		return nil, err
+	}
+
+	logger.Printf("Ensured OVS bridge %s", brName)
+	return br, nil
+}
+
+func (ovsBridge) AttachPort(brName string, port netlink.Link) error {
+	br, err := netlink.LinkByName(brName)
+	if err != nil {
+		return fmt.Errorf("bridge %s does not exist: %v", brName, err)
+	}
+	if _, err := ensureLinkUp(br, "bridge"); err != nil {
+		return err
+	}
+	_, err = ovsVsctl("--may-exist", "add-port", brName, port.Attrs().Name)
+	return err
+}
+
+func (ovsBridge) DetachPort(brName, portName string) error {
+	_, err := ovsVsctl("--if-exists", "del-port", brName, portName)
+	return err
+}
+
+func (ovsBridge) SetPortVlan(brName string, port netlink.Link, vlanID int) error {
+	_, err := ovsVsctl("set", "port", port.Attrs().Name, "tag="+strconv.Itoa(vlanID))
+	return err
+}
+
+func (ovsBridge) PortBridge(port netlink.Link) (string, error) {
+	// 接口不属于任何OVS网桥时ovs-vsctl返回错误
+	br, err := ovsVsctl("port-to-br", port.Attrs().Name)
+	if err != nil {
+		return "", nil
+	}
+	return br, nil
+}
+
+func (ovsBridge) DeleteBridge(brName string) error {
+	_, err := ovsVsctl("--if-exists", "del-br", brName)
+	return err
+}
+
+// linkState 描述接口的管理状态和运行状态，用于日志和错误信息
+func linkState(link netlink.Link) string {
+	admin := "down"
+	if link.Attrs().Flags&net.FlagUp != 0 {
+		admin = "up"
+	}
+	return fmt.Sprintf("admin %s, oper %s", admin, link.Attrs().OperState)
+}
+
+// ensureLinkUp 确保接口处于管理up状态，未up时将其拉起并重新读取，what用于错误信息中描述接口用途。
+// 没有端口的网桥运行状态为down属于正常情况，因此只检查管理状态
+func ensureLinkUp(link netlink.Link, what string) (netlink.Link, error) {
+	name := link.Attrs().Name
+	if link.Attrs().Flags&net.FlagUp != 0 {
+		return link, nil
+	}
+	logger.Printf("%s %s is %s, bringing it up", what, name, linkState(link))
+	if err := netlink.LinkSetUp(link); err != nil {
+		return nil, fmt.Errorf("%s %s is %s and could not be brought up: %v", what, name, linkState(link), err)
+	}
+	up, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s disappeared after bringing it up: %v", what, name, err)
+	}
+	if up.Attrs().Flags&net.FlagUp == 0 {
+		return nil, fmt.Errorf("%s %s is still %s after bringing it up", what, name, linkState(up))
+	}
+	return up, nil
+}
+
+// linkMaster 返回接口的master（网桥、bond等），没有master时返回nil
+func linkMaster(link netlink.Link) (netlink.Link, error) {
+	index := link.Attrs().MasterIndex
+	if index == 0 {
+		return nil, nil
+	}
+	master, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get master of %s: %v", link.Attrs().Name, err)
+	}
+	return master, nil
+}
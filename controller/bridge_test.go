@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+// addTestBridge 在当前命名空间中创建保持admin down的Linux网桥
+func addTestBridge(name string) (netlink.Link, error) {
+	if err := netlink.LinkAdd(&netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: name}}); err != nil {
+		return nil, fmt.Errorf("failed to add bridge %s: %w", name, err)
+	}
+	return netlink.LinkByName(name)
+}
+
+// isAdminUp 重新读取接口并判断其是否处于管理up状态
+func isAdminUp(name string) (bool, error) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return false, err
+	}
+	return link.Attrs().Flags&net.FlagUp != 0, nil
+}
+
+func TestEnsureBridgeBringsUpAdminDownBridge(t *testing.T) {
+	withTestNetns(t, func() error {
+		if _, err := addTestBridge("br-test"); err != nil {
+			return err
+		}
+		br, err := EnsureBridge("br-test", 1500, false, false)
+		if err != nil {
+			return fmt.Errorf("EnsureBridge: %v", err)
+		}
+		if br.Attrs().Flags&net.FlagUp == 0 {
+			t.Errorf("EnsureBridge returned br-test in state %s, want admin up", linkState(br))
+		}
+		if up, err := isAdminUp("br-test"); err != nil || !up {
+			t.Errorf("br-test admin up = %v (%v), want true", up, err)
+		}
+		return nil
+	})
+}
+
+func TestLinuxBridgeAttachPortBringsUpBridge(t *testing.T) {
+	withTestNetns(t, func() error {
+		br, err := addTestBridge("br-test")
+		if err != nil {
+			return err
+		}
+		port, _, err := addTestLink("veth0")
+		if err != nil {
+			return err
+		}
+		if err := (linuxBridge{}).AttachPort("br-test", port); err != nil {
+			return fmt.Errorf("AttachPort: %v", err)
+		}
+		if up, err := isAdminUp("br-test"); err != nil || !up {
+			t.Errorf("br-test admin up = %v (%v), want true", up, err)
+		}
+		port, err = netlink.LinkByName("veth0")
+		if err != nil {
+			return err
+		}
+		if port.Attrs().MasterIndex != br.Attrs().Index {
+			t.Errorf("veth0 master index = %d, want br-test (%d)", port.Attrs().MasterIndex, br.Attrs().Index)
+		}
+		return nil
+	})
+}
+
+func TestAttachUplink(t *testing.T) {
+	useTestStateStore(t)
+	withTestNetns(t, func() error {
+		if _, err := addTestBridge("br-test"); err != nil {
+			return err
+		}
+		other, err := addTestBridge("br-other")
+		if err != nil {
+			return err
+		}
+		// 上联接口保持down，接入后应被启用
+		veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "uplink0"}, PeerName: "uplink0p"}
+		if err := netlink.LinkAdd(veth); err != nil {
+			return fmt.Errorf("failed to add veth: %w", err)
+		}
+		if _, _, err := addTestLink("taken0"); err != nil {
+			return err
+		}
+		taken, err := netlink.LinkByName("taken0")
+		if err != nil {
+			return err
+		}
+		if err := netlink.LinkSetMaster(taken, other); err != nil {
+			return err
+		}
+
+		tests := []struct {
+			uplink  string
+			wantErr string
+		}{
+			{uplink: "missing0", wantErr: "uplink missing0 does not exist"},
+			{uplink: "taken0", wantErr: "is already enslaved to bridge br-other"},
+			{uplink: "uplink0"},
+			// 已接入同一网桥时不做任何修改
+			{uplink: "uplink0"},
+		}
+		for _, tt := range tests {
+			err := attachUplink(Spec{BrName: "br-test", Uplink: tt.uplink})
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("attachUplink(%s): %v", tt.uplink, err)
+			} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("attachUplink(%s) = %v, want error containing %q", tt.uplink, err, tt.wantErr)
+			}
+		}
+
+		uplink, err := netlink.LinkByName("uplink0")
+		if err != nil {
+			return err
+		}
+		master, err := linkMaster(uplink)
+		if err != nil {
+			return err
+		}
+		if master == nil || master.Attrs().Name != "br-test" {
+			t.Errorf("uplink0 master = %v, want br-test", master)
+		}
+		if uplink.Attrs().Flags&net.FlagUp == 0 {
+			t.Errorf("uplink0 is %s, want admin up", linkState(uplink))
+		}
+		if got := stateStore.UplinksOf("br-test"); len(got) != 1 || got[0] != "uplink0" {
+			t.Errorf("recorded uplinks of br-test = %v, want [uplink0]", got)
+		}
+
+		// 被其他网桥占用的接口保持不变
+		taken, err = netlink.LinkByName("taken0")
+		if err != nil {
+			return err
+		}
+		if taken.Attrs().MasterIndex != other.Attrs().Index {
+			t.Errorf("taken0 was moved off br-other")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// loadConfigFile 从YAML文件读取配置项，键名与命令行参数名一致。
+// 命令行中显式指定的参数优先于配置文件，未知的键名返回错误。
+func loadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	// 记录命令行中显式设置的参数
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for key, value := range values {
+		if key == "config" || flag.Lookup(key) == nil {
+			return fmt.Errorf("unknown option %q in %s", key, path)
+		}
+		if explicit[key] {
+			continue
+		}
+		if err := flag.Set(key, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("invalid value for option %q in %s: %v", key, path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TrexTemplatePort 模板中每个端口的信息
+type TrexTemplatePort struct {
+	VFName    string
+	PCI       string
+	IP        string
+	Gateway   string
+	DummyIP   string
+	DestMAC   string
+	VlanId    int
+	VlanProto string // 802.1q或802.1ad
+	QinQ      int    // QinQ内层VLAN，需要TRex在流量中添加
+	Socket    int
+	Threads   []int
+	PortIndex int
+}
+
+// TrexTemplateData 渲染trex_cfg.yaml模板时的上下文
+type TrexTemplateData struct {
+	Name            string
+	Ports           []TrexTemplatePort
+	Cores           int
+	NumaSocket      int
+	PortLimit       int
+	MasterThreadID  int
+	LatencyThreadID int
+	MgmtIP          string
+	MgmtGateway     string
+	Generated       TrexPortConfig // 内置生成的配置，便于模板复用
+}
+
+// isTemplatePath 判断ConfigTemplate是主机上的模板文件路径还是内联模板
+func isTemplatePath(tmpl string) bool {
+	return filepath.IsAbs(tmpl) && !strings.Contains(tmpl, "\n") && !strings.Contains(tmpl, "{{")
+}
+
+// parseConfigTemplate 解析Spec.ConfigTemplate，支持主机路径或内联text/template字符串
+func parseConfigTemplate(tmpl string) (*template.Template, error) {
+	text := tmpl
+	if isTemplatePath(tmpl) {
+		content, err := os.ReadFile(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config template %s: %v", tmpl, err)
+		}
+		text = string(content)
+	}
+
+	t, err := template.New("trex_cfg").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config template: %v", err)
+	}
+	return t, nil
+}
+
+// renderConfigTemplate 使用部署信息渲染trex_cfg.yaml模板
+func renderConfigTemplate(tmpl string, data TrexTemplateData) ([]byte, error) {
+	t, err := parseConfigTemplate(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render config template: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// readRawTrexConfig 读取Spec.RawTrexConfig的内容，支持主机路径或内联YAML
+func readRawTrexConfig(raw string) ([]byte, error) {
+	if filepath.IsAbs(raw) && !strings.Contains(raw, "\n") {
+		content, err := os.ReadFile(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read raw trex_cfg.yaml %s: %v", raw, err)
+		}
+		return content, nil
+	}
+	return []byte(raw), nil
+}
+
+// rawInterfaceCount 返回原样使用的trex_cfg.yaml中interfaces（包括dummy）的总数，无法按TRex格式解析时返回错误
+func rawInterfaceCount(content []byte) (int, error) {
+	var file TrexConfigFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return 0, fmt.Errorf("failed to parse raw trex_cfg.yaml: %v", err)
+	}
+	if len(file) == 0 {
+		return 0, fmt.Errorf("raw trex_cfg.yaml contains no port configuration")
+	}
+	n := 0
+	for _, entry := range file {
+		n += len(entry.Interfaces)
+	}
+	return n, nil
+}
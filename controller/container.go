@@ -7,11 +7,16 @@ import (
 	"fmt"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/strslice"
 	"github.com/docker/docker/client"
+	units "github.com/docker/go-units"
 	"github.com/vishvananda/netlink"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,21 +29,133 @@ func isProcessAlive(pid int) bool {
 	return true
 }
 
+// 标记controller管理的容器的标签
+const (
+	labelManaged = "trex-controller.managed"
+	labelName    = "trex-controller.name"
+	labelRole    = "trex-controller.role"
+	// 副本所属的部署名称
+	labelDeployment = "trex-controller.deployment"
+
+	roleWorker = "worker"
+	rolePause  = "pause"
+)
+
+func managedLabels(config TRExConfig, role string) map[string]string {
+	deployment := config.Metadata.Deployment
+	if deployment == "" {
+		deployment = config.Metadata.Name
+	}
+	return map[string]string{
+		labelManaged:    "true",
+		labelName:       config.Metadata.Name,
+		labelRole:       role,
+		labelDeployment: deployment,
+	}
+}
+
+// findDeploymentContainers 通过标签查找部署的工作容器和pause容器ID，
+// 未找到时按容器名称查找，以兼容添加标签之前创建的容器
+func findDeploymentContainers(ctx context.Context, name string) (string, string, error) {
+	containers, err := dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", labelManaged+"=true"),
+			filters.Arg("label", labelName+"="+name),
+		),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list containers: %v", err)
+	}
+	workerID, pauseID := matchDeploymentContainers(containers, name)
+	if workerID != "" && pauseID != "" {
+		return workerID, pauseID, nil
+	}
+
+	// Docker的name过滤是正则匹配，需要锚定并转义
+	legacy, err := dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", "^/"+regexp.QuoteMeta(name)+"(-pause)?$")),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list containers: %v", err)
+	}
+	legacyWorkerID, legacyPauseID := matchDeploymentContainers(legacy, name)
+	if workerID == "" {
+		workerID = legacyWorkerID
+	}
+	if pauseID == "" {
+		pauseID = legacyPauseID
+	}
+	return workerID, pauseID, nil
+}
+
+// netnsContainerID 返回持有部署网络命名空间的容器ID：默认为pause容器，
+// NoPause部署没有pause容器，由工作容器持有，工作容器重启后命名空间随之重建
+func netnsContainerID(config TRExConfig, workerID, pauseID string) string {
+	if config.Spec.NoPause {
+		return workerID
+	}
+	return pauseID
+}
+
+// matchDeploymentContainers 从容器列表中找出部署name的工作容器和pause容器：
+// 带标签的容器按标签精确匹配名称和角色；不带标签的旧容器按容器名称精确匹配<name>和<name>-pause，
+// 其他部署带标签的容器（例如部署foo的pause容器foo-pause）不会被误认为部署foo-pause的工作容器
+func matchDeploymentContainers(containers []types.Container, name string) (workerID, pauseID string) {
+	var legacyWorkerID, legacyPauseID string
+	for _, c := range containers {
+		if c.Labels[labelManaged] == "true" {
+			if c.Labels[labelName] != name {
+				continue
+			}
+			switch c.Labels[labelRole] {
+			case roleWorker:
+				workerID = c.ID
+			case rolePause:
+				pauseID = c.ID
+			}
+			continue
+		}
+		for _, cname := range c.Names {
+			switch strings.TrimPrefix(cname, "/") {
+			case name:
+				legacyWorkerID = c.ID
+			case name + "-pause":
+				legacyPauseID = c.ID
+			}
+		}
+	}
+	if workerID == "" {
+		workerID = legacyWorkerID
+	}
+	if pauseID == "" {
+		pauseID = legacyPauseID
+	}
+	return workerID, pauseID
+}
+
 func createAndStartPauseContainer(ctx context.Context, config TRExConfig) (string, int, error) {
 	name := config.Metadata.Name
 	// 创建pause容器
 	pauseName := fmt.Sprintf("%s-pause", name)
+	// 共享网络命名空间的工作容器使用pause容器的主机名和/etc/hosts，Docker不允许为其单独设置
 	resp, err := dockerClient.ContainerCreate(ctx, &container.Config{
-		Image: pauseImage,
+		Image:    *pauseImage,
+		Hostname: containerHostname(config),
+		Labels:   managedLabels(config, rolePause),
 	}, &container.HostConfig{
 		NetworkMode: "none",
+		ExtraHosts:  config.Spec.ExtraHosts,
+		// 与工作容器使用相同的重启策略，保证共享的网络命名空间随之恢复
+		RestartPolicy: restartPolicy(config.Spec),
 	}, nil, nil, pauseName)
 
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to create pause container: %v", err)
 	}
 	pauseID := resp.ID
-	logger.Printf("Pause container %s created with ID: %s", pauseName, pauseID)
+	logf(ctx, "Pause container %s created with ID: %s", pauseName, pauseID)
 
 	// 启动pause容器
 	if err := dockerClient.ContainerStart(ctx, pauseID, types.ContainerStartOptions{}); err != nil {
@@ -54,49 +171,128 @@ func createAndStartPauseContainer(ctx context.Context, config TRExConfig) (strin
 	return pauseID, pid, nil
 }
 
-func createWorkerContainer(ctx context.Context, config TRExConfig, pauseContainerID string, vfPCIMap map[string]string) (string, error) {
+func createWorkerContainer(ctx context.Context, config TRExConfig, pauseContainerID string, configFilePath string) (string, error) {
 	image := config.Metadata.Image
 	name := config.Metadata.Name
-	logger.Printf("Creating worker container for %s ...", name, "vfPCIMap is %v", vfPCIMap)
-	// 生成配置文件
-	configFilePath, err := createVFConfigFile(name, vfPCIMap, config)
-	if err != nil {
-		return "", fmt.Errorf("failed to create VF config file: %v", err)
-	}
+	logf(ctx, "Creating worker container for %s with config file %s ...", name, configFilePath)
 
-	logger.Printf("Generated VF config file: %s Success! ", configFilePath)
 	// 创建工作容器配置
 	containerConfig := &container.Config{
-		Image: image,
-		Cmd:   []string{"tail", "-f", "/dev/null"}, // 保持容器运行
-		Tty:   true,
+		Image:      image,
+		Cmd:        []string{"tail", "-f", "/dev/null"}, // 保持容器运行
+		Tty:        true,
+		Env:        config.Spec.Env,
+		WorkingDir: config.Spec.WorkingDir,
+		Labels:     managedLabels(config, roleWorker),
+	}
+	if len(config.Spec.Command) > 0 {
+		containerConfig.Cmd = config.Spec.Command
 	}
 
 	mounts := []mount.Mount{
-		{
-			Type:   mount.TypeBind,
-			Source: "/mnt/huge",
-			Target: "/mnt/huge",
-		},
 		{
 			Type:   mount.TypeBind,
 			Source: configFilePath,
-			Target: "/etc/trex_cfg.yaml",
+			Target: config.Spec.ConfigTarget,
 		},
 	}
 
+	// 挂载大页目录，software/af_packet模式可关闭
+	if !config.Spec.DisableHugepages {
+		if _, err := os.Stat(config.Spec.HugepagesPath); err != nil {
+			return "", fmt.Errorf("hugepages not mounted at %s: %v", config.Spec.HugepagesPath, err)
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeBind,
+			Source: config.Spec.HugepagesPath,
+			Target: config.Spec.HugepagesTarget,
+		})
+	}
+
+	// 只读挂载流量配置文件，并通过TREX_PROFILE告知容器内路径
+	if config.Spec.Profile != "" {
+		if _, err := os.Stat(config.Spec.Profile); err != nil {
+			return "", fmt.Errorf("profile %s not found: %v", config.Spec.Profile, err)
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   config.Spec.Profile,
+			Target:   profileTarget(config.Spec),
+			ReadOnly: true,
+		})
+		containerConfig.Env = append(append([]string(nil), config.Spec.Env...), "TREX_PROFILE="+profileTarget(config.Spec))
+	}
+
+	// 挂载生成的resolv.conf，未配置DNS时保持Docker默认行为
+	resolvConf, err := createResolvConf(config)
+	if err != nil {
+		return "", err
+	}
+	if resolvConf != "" {
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   resolvConf,
+			Target:   "/etc/resolv.conf",
+			ReadOnly: true,
+		})
+	}
+
+	// 添加额外挂载，目标路径相同时覆盖内置挂载
+	for _, m := range config.Spec.Mounts {
+		if _, err := os.Stat(m.Source); err != nil {
+			return "", fmt.Errorf("mount source %s not found: %v", m.Source, err)
+		}
+		userMount := mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		}
+
+		overridden := false
+		for i := range mounts {
+			if mounts[i].Target == m.Target {
+				mounts[i] = userMount
+				overridden = true
+			}
+		}
+		if !overridden {
+			mounts = append(mounts, userMount)
+		}
+	}
+
+	// 共享pause容器的网络命名空间，NoPause时使用自己的网络命名空间
+	networkMode := container.NetworkMode("container:" + pauseContainerID)
+	if pauseContainerID == "" {
+		networkMode = "none"
+	}
 	hostConfig := &container.HostConfig{
-		// 共享pause容器的网络命名空间
-		NetworkMode: container.NetworkMode("container:" + pauseContainerID),
+		NetworkMode: networkMode,
 		// 添加所有能力
 		CapAdd: strslice.StrSlice{"ALL"},
 		// 启用特权模式
 		Privileged: true,
 		// 设置挂载点
 		Mounts: mounts,
+		// 重启策略，默认不重启
+		RestartPolicy: restartPolicy(config.Spec),
+	}
+	// NoPause时工作容器自己持有网络命名空间，直接设置主机名和/etc/hosts
+	if pauseContainerID == "" {
+		containerConfig.Hostname = containerHostname(config)
+		hostConfig.ExtraHosts = config.Spec.ExtraHosts
+	}
+	if !isPrivileged(config.Spec) {
+		if err := restrictPrivileges(ctx, config, hostConfig); err != nil {
+			return "", err
+		}
+	}
+	// 开启AutoNuma时将内存分配限制在VF所在的NUMA节点
+	if config.Spec.AutoNuma {
+		hostConfig.Resources.CpusetMems = strconv.Itoa(config.Spec.NumaSocket)
 	}
 
-	logger.Printf("Creating worker container %s with config: %+v", config.Metadata.Name, containerConfig)
+	logf(ctx, "Creating worker container %s with config: %+v", config.Metadata.Name, containerConfig)
 	resp, err := dockerClient.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, config.Metadata.Name)
 	if err != nil {
 		return "", fmt.Errorf("failed to create worker container: %v", err)
@@ -104,7 +300,7 @@ func createWorkerContainer(ctx context.Context, config TRExConfig, pauseContaine
 	workerID := resp.ID
 
 	// 启动工作容器
-	logger.Printf("Starting worker container %s", config.Metadata.Name)
+	logf(ctx, "Starting worker container %s", config.Metadata.Name)
 	if err := dockerClient.ContainerStart(ctx, workerID, types.ContainerStartOptions{}); err != nil {
 		return "", fmt.Errorf("failed to start worker container: %v", err)
 	}
@@ -113,40 +309,75 @@ func createWorkerContainer(ctx context.Context, config TRExConfig, pauseContaine
 }
 
 func cleanupOnError(ctx context.Context, state *deploymentState, config TRExConfig) {
-	logger.Printf("Performing cleanup due to deployment failure")
+	logf(ctx, "Performing cleanup due to deployment failure")
+
+	// 先卸载命名网络命名空间，否则删除容器后命名空间及其中的VF仍然保留
+	if state.netnsExposed {
+		unexposeNetns(config.Metadata.Name)
+	}
 
 	// 清理工作容器
 	if state.workerContainerID != "" {
-		logger.Printf("Removing worker container %s", state.workerContainerID)
+		logf(ctx, "Removing worker container %s", state.workerContainerID)
 		if err := dockerClient.ContainerRemove(ctx, state.workerContainerID, types.ContainerRemoveOptions{
 			Force: true,
 		}); err != nil {
-			logger.Printf("Failed to remove worker container: %v", err)
+			logf(ctx, "Failed to remove worker container: %v", err)
 		}
 	}
 
 	// 清理网络配置
 	if state.networkConfigured {
 		hostName, _ := getPairName(config.Metadata.Name, state.pauseContainerID)
-		logger.Printf("Cleaning up network interfaces")
+		logf(ctx, "Cleaning up network interfaces")
+		if err := bridgeBackendFor(config.Spec.BridgeType).DetachPort(config.Spec.BrName, hostName); err != nil {
+			logf(ctx, "Failed to detach %s from bridge: %v", hostName, err)
+		}
 		if link, err := netlink.LinkByName(hostName); err == nil {
 			netlink.LinkDel(link)
 		}
 
-		if config.Spec.NetworkType == "SRIOV" {
-			// Todo...
+	}
+
+	// 恢复已重新绑定的VF驱动
+	if config.Spec.NetworkType == "SRIOV" {
+		releaseVFDrivers(config)
+	}
+	if config.Spec.NetworkType == "VETH" {
+		deleteVethDataPorts(config)
+	}
+
+	if state.portsPublished {
+		removePortForwards(config)
+	}
+
+	// 清理生成的配置文件
+	if state.configFilePath != "" {
+		if err := os.Remove(state.configFilePath); err != nil && !os.IsNotExist(err) {
+			logf(ctx, "Failed to remove config file %s: %v", state.configFilePath, err)
 		}
 	}
+	if err := os.Remove(resolvConfPath(config.Metadata.Name)); err != nil && !os.IsNotExist(err) {
+		logf(ctx, "Failed to remove resolv.conf of %s: %v", config.Metadata.Name, err)
+	}
 
 	// 清理pause容器
 	if state.pauseContainerID != "" {
-		logger.Printf("Removing pause container %s", state.pauseContainerID)
+		logf(ctx, "Removing pause container %s", state.pauseContainerID)
 		if err := dockerClient.ContainerRemove(ctx, state.pauseContainerID, types.ContainerRemoveOptions{
 			Force: true,
 		}); err != nil {
-			logger.Printf("Failed to remove pause container: %v", err)
+			logf(ctx, "Failed to remove pause container: %v", err)
 		}
 	}
+
+	// 释放网桥引用
+	if state.bridgeCreated {
+		releaseBridge(config.Spec.BrName, config.Spec.BridgeType)
+	}
+	for _, name := range state.portBridges {
+		releaseBridge(name, config.Spec.BridgeType)
+	}
 }
 
 // 部署状态结构体
@@ -154,64 +385,290 @@ type deploymentState struct {
 	bridgeCreated     bool
 	pauseContainerID  string
 	pausePID          int
+	netnsPID          int
+	hostVeth          string
 	workerContainerID string
 	networkConfigured bool
+	portsPublished    bool
+	netnsExposed      bool
+	portBridges       []string // 已获取引用的数据端口网桥
+	configFilePath    string
 }
 
-const pauseImage = "k8s.gcr.io/pause:3.8" // 官方轻量级pause容器
+const defaultPauseImage = "k8s.gcr.io/pause:3.8" // 官方轻量级pause容器
 
-func CreateTRExContainer(ctx context.Context, config TRExConfig) (string, error) {
+func CreateTRExContainer(ctx context.Context, config TRExConfig) (*ReplicaResult, error) {
 	state := &deploymentState{
 		pauseContainerID:  "",
 		workerContainerID: "",
 	}
-	bridgeName := config.Spec.BrName
 	var err error
 
 	defer func() {
 		if err != nil {
-			cleanupOnError(ctx, state, config)
+			// 请求超时或被取消后仍需完成清理
+			cleanupCtx, cancel := detachedContext(ctx)
+			defer cancel()
+			cleanupOnError(cleanupCtx, state, config)
 		}
 	}()
 
+	if err = checkPublishPortConflicts(config); err != nil {
+		return nil, err
+	}
+	if err = checkHugepages(ctx, config); err != nil {
+		return nil, err
+	}
+
+	// 预检查VF，避免创建容器后再回滚
+	var numaNodes map[string]int
+	if config.Spec.NetworkType == "SRIOV" {
+		if err = validateVFs(config); err != nil {
+			return nil, err
+		}
+		if numaNodes, err = checkVFNumaNodes(ctx, &config); err != nil {
+			return nil, err
+		}
+	}
+
 	// 1. 确保基础镜像存在
-	if err = ensureImageExists(ctx, dockerClient, pauseImage); err != nil {
-		return "", fmt.Errorf("failed to ensure pause image exists: %v", err)
+	if !config.Spec.NoPause {
+		if err = ensureImageExists(ctx, dockerClient, *pauseImage, pausePullPolicy(config.Spec.PullPolicy)); err != nil {
+			return nil, fmt.Errorf("failed to ensure pause image exists: %v", err)
+		}
 	}
-	if err = ensureImageExists(ctx, dockerClient, config.Metadata.Image); err != nil {
-		return "", fmt.Errorf("failed to ensure TREx image exists: %v", err)
+	if err = ensureImageExists(ctx, dockerClient, config.Metadata.Image, config.Spec.PullPolicy); err != nil {
+		return nil, fmt.Errorf("failed to ensure TREx image exists: %v", err)
 	}
 
 	// 2. 确保网桥存在
-	br, err := EnsureBridge(bridgeName, 1500, false, false)
+	br, err := acquireBridge(config.Spec)
 	if err != nil {
-		return "", fmt.Errorf("failed to ensure bridge: %v", err)
+		return nil, fmt.Errorf("failed to ensure bridge: %v", err)
 	}
 	state.bridgeCreated = true
+	if err = acquireDataPortBridges(state, config.Spec); err != nil {
+		return nil, err
+	}
+
+	// 接入上联接口
+	if config.Spec.Uplink != "" {
+		if err = attachUplink(config.Spec); err != nil {
+			return nil, err
+		}
+	}
+
+	var vfPCIMap map[string]string
+	var configFilePath string
+	if config.Spec.NoPause {
+		vfPCIMap, configFilePath, err = createWorkerOwningNetns(ctx, state, config, br)
+	} else {
+		vfPCIMap, configFilePath, err = createWorkerWithPause(ctx, state, config, br)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReplicaResult{
+		Name:             config.Metadata.Name,
+		ContainerID:      state.workerContainerID,
+		PauseContainerID: state.pauseContainerID,
+		NetnsPID:         state.netnsPID,
+		BrName:           config.Spec.BrName,
+		HostVeth:         state.hostVeth,
+		ConfigFile:       configFilePath,
+		NetnsName:        netnsName(config),
+		VFPCIMap:         vfPCIMap,
+		VFNumaNodes:      numaNodes,
+	}, nil
+}
 
+// createWorkerWithPause 创建pause容器并在其网络命名空间中配置网络，生成配置文件后创建共享该命名空间的工作容器
+func createWorkerWithPause(ctx context.Context, state *deploymentState, config TRExConfig, br netlink.Link) (map[string]string, string, error) {
 	// 3. 创建并启动pause容器
 	pauseID, pid, err := createAndStartPauseContainer(ctx, config)
 	if err != nil {
-		return "", fmt.Errorf("failed to create pause container: %v", err)
+		return nil, "", fmt.Errorf("failed to create pause container: %v", err)
 	}
 	state.pauseContainerID = pauseID
 	state.pausePID = pid
 
 	// 4. 配置pause容器的网络
-	vfPCIMap, err := configurePauseContainerNetwork(config, pid, br, pauseID)
+	vfPCIMap, err := configureNetns(ctx, state, config, pid, br, pauseID)
 	if err != nil {
-		return "", fmt.Errorf("failed to configure pause container network: %v", err)
+		return nil, "", err
 	}
-	state.networkConfigured = true
 
-	// 5. 创建工作容器（共享pause容器的网络命名空间）
-	workerID, err := createWorkerContainer(ctx, config, pauseID, vfPCIMap)
+	// 5. 生成trex_cfg.yaml配置文件
+	configFilePath, err := createVFConfigFile(config.Metadata.Name, vfPCIMap, config)
 	if err != nil {
-		return "", fmt.Errorf("failed to create worker container: %v", err)
+		return nil, "", fmt.Errorf("failed to create VF config file: %v", err)
+	}
+	state.configFilePath = configFilePath
+	logf(ctx, "Generated VF config file: %s Success! ", configFilePath)
+
+	// 6. 创建工作容器（共享pause容器的网络命名空间）
+	workerID, err := createWorkerContainer(ctx, config, pauseID, configFilePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create worker container: %v", err)
 	}
 	state.workerContainerID = workerID
+	return vfPCIMap, configFilePath, nil
+}
 
-	return workerID, nil
+// createWorkerOwningNetns NoPause模式：先以空配置文件创建并启动工作容器，在工作容器自己的网络命名空间中配置网络，
+// 再写入trex_cfg.yaml（原地覆盖，容器内的绑定挂载可以看到新内容）
+func createWorkerOwningNetns(ctx context.Context, state *deploymentState, config TRExConfig, br netlink.Link) (map[string]string, string, error) {
+	configFilePath := trexConfigPath(config.Metadata.Name)
+	if err := os.MkdirAll(*configDir, 0755); err != nil {
+		return nil, "", fmt.Errorf("failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(configFilePath, nil, 0644); err != nil {
+		return nil, "", fmt.Errorf("failed to create config file: %v", err)
+	}
+	state.configFilePath = configFilePath
+
+	// 3. 创建并启动工作容器（使用自己的网络命名空间）
+	workerID, err := createWorkerContainer(ctx, config, "", configFilePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create worker container: %v", err)
+	}
+	state.workerContainerID = workerID
+	pid, err := getValidContainerPID(ctx, workerID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get worker container PID: %v", err)
+	}
+
+	// 4. 配置工作容器的网络
+	vfPCIMap, err := configureNetns(ctx, state, config, pid, br, workerID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 5. 生成trex_cfg.yaml配置文件
+	if _, err := createVFConfigFile(config.Metadata.Name, vfPCIMap, config); err != nil {
+		return nil, "", fmt.Errorf("failed to create VF config file: %v", err)
+	}
+	logf(ctx, "Generated VF config file: %s Success! ", configFilePath)
+	return vfPCIMap, configFilePath, nil
+}
+
+// configureNetns 配置持有网络命名空间的容器（pause容器或NoPause时的工作容器）的网络并发布端口
+func configureNetns(ctx context.Context, state *deploymentState, config TRExConfig, pid int, br netlink.Link, containerID string) (map[string]string, error) {
+	vfPCIMap, err := configurePauseContainerNetwork(ctx, config, pid, br, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure container network: %v", err)
+	}
+	state.networkConfigured = true
+	state.netnsPID = pid
+	state.hostVeth, _ = getPairName(config.Metadata.Name, containerID)
+
+	// 暴露命名网络命名空间，便于使用ip netns exec排查
+	if config.Spec.ExposeNetns {
+		if err := exposeNetns(config.Metadata.Name, pid); err != nil {
+			return nil, err
+		}
+		state.netnsExposed = true
+		logf(ctx, "Exposed network namespace of %s at %s", config.Metadata.Name, netnsPath(config.Metadata.Name))
+	}
+
+	// 发布TREx API等端口到主机
+	if err := setupPortForwards(ctx, config); err != nil {
+		return nil, fmt.Errorf("failed to publish ports: %v", err)
+	}
+	state.portsPublished = true
+	return vfPCIMap, nil
+}
+
+// unprivilegedCaps 非特权模式下工作容器需要的能力：配置接口、锁定DPDK内存、设置线程调度优先级
+var unprivilegedCaps = strslice.StrSlice{"NET_ADMIN", "IPC_LOCK", "SYS_NICE"}
+
+// isPrivileged 返回工作容器是否以特权模式运行，未设置Spec.Privileged时默认为特权模式
+func isPrivileged(spec Spec) bool {
+	return spec.Privileged == nil || *spec.Privileged
+}
+
+// restrictPrivileges 以非特权模式运行工作容器：只添加必要的能力，显式映射VFIO设备，并解除memlock限制供DPDK锁定大页内存
+func restrictPrivileges(ctx context.Context, config TRExConfig, hostConfig *container.HostConfig) error {
+	hostConfig.Privileged = false
+	hostConfig.CapAdd = unprivilegedCaps
+
+	devices, err := vfioDevices(config)
+	if err != nil {
+		return fmt.Errorf("failed to find VFIO devices: %v", err)
+	}
+	for _, device := range devices {
+		hostConfig.Devices = append(hostConfig.Devices, container.DeviceMapping{
+			PathOnHost:        device,
+			PathInContainer:   device,
+			CgroupPermissions: "rwm",
+		})
+	}
+	hostConfig.Ulimits = append(hostConfig.Ulimits, &units.Ulimit{Name: "memlock", Soft: -1, Hard: -1})
+
+	logf(ctx, "Running worker %s unprivileged with capabilities %v and devices %v", config.Metadata.Name, unprivilegedCaps, devices)
+	return nil
+}
+
+// restartPolicy 根据Spec.RestartPolicy生成容器的重启策略，最大重试次数仅对on-failure有效
+func restartPolicy(spec Spec) container.RestartPolicy {
+	policy := container.RestartPolicy{Name: spec.RestartPolicy}
+	if spec.RestartPolicy == "on-failure" {
+		policy.MaximumRetryCount = spec.RestartMaxRetries
+	}
+	return policy
+}
+
+// stopTimeout 返回停止容器的超时时间，Spec.StopTimeout优先于-stop-timeout，均未设置时返回nil使用Docker默认值
+func stopTimeout(config TRExConfig) *int {
+	if config.Spec.StopTimeout != nil {
+		return config.Spec.StopTimeout
+	}
+	if *stopTimeoutFlag >= 0 {
+		timeout := *stopTimeoutFlag
+		return &timeout
+	}
+	return nil
+}
+
+func formatStopTimeout(timeout *int) string {
+	if timeout == nil {
+		return "docker default"
+	}
+	return fmt.Sprintf("%ds", *timeout)
+}
+
+// stopManagedDeployments 停止状态记录中的全部部署并删除其veth，保留状态记录以便重新部署
+func stopManagedDeployments(ctx context.Context) {
+	for name, record := range stateStore.List() {
+		if ctx.Err() != nil {
+			logf(ctx, "Shutdown deadline exceeded, leaving remaining deployments running")
+			return
+		}
+
+		lock := containerLocks.GetLock(name)
+		lock.Lock()
+		logf(ctx, "Stopping deployment %s", name)
+		stopOptions := container.StopOptions{Timeout: stopTimeout(record.Config)}
+		for _, replicaName := range replicaNames(record.Config) {
+			containerNames := []string{replicaName}
+			if !record.Config.Spec.NoPause {
+				containerNames = append(containerNames, fmt.Sprintf("%s-pause", replicaName))
+			}
+			for _, containerName := range containerNames {
+				logf(ctx, "Stopping container %s (timeout: %s)", containerName, formatStopTimeout(stopOptions.Timeout))
+				if err := dockerClient.ContainerStop(ctx, containerName, stopOptions); err != nil {
+					logf(ctx, "Warning: failed to stop container %s: %v", containerName, err)
+				}
+			}
+
+			vethHost, _ := getPairName(replicaName, "")
+			if err := deleteVethPair(vethHost); err != nil {
+				logf(ctx, "Warning: failed to delete veth pair: %v", err)
+			}
+		}
+		lock.Unlock()
+	}
 }
 
 func getValidContainerPID(ctx context.Context, containerID string) (int, error) {
@@ -235,7 +692,7 @@ func getValidContainerPID(ctx context.Context, containerID string) (int, error)
 			if isProcessAlive(pid) {
 				return pid, nil
 			}
-			logger.Printf("PID %d is not active, retrying...", pid)
+			logf(ctx, "PID %d is not active, retrying...", pid)
 		}
 
 		time.Sleep(retryDelay)
@@ -244,18 +701,23 @@ func getValidContainerPID(ctx context.Context, containerID string) (int, error)
 	return 0, fmt.Errorf("failed to get valid PID after %d retries", maxRetries)
 }
 
-func ensureImageExists(ctx context.Context, dockerClient *client.Client, image string) error {
+// ensureImageExists 按拉取策略确保镜像存在：IfNotPresent仅在本地不存在时拉取，Always总是拉取（带摘要且本地存在时除外），
+// Never从不拉取，本地不存在时报错
+func ensureImageExists(ctx context.Context, dockerClient *client.Client, image, policy string) error {
 	_, _, err := dockerClient.ImageInspectWithRaw(ctx, image)
-	if err == nil {
-		logger.Printf("Image already exists: %s", image)
-		return nil
-	}
-
-	if !client.IsErrNotFound(err) {
+	if err != nil && !client.IsErrNotFound(err) {
 		return fmt.Errorf("failed to inspect image %s: %v", image, err)
 	}
+	pull, err := needPull(image, policy, err == nil)
+	if err != nil {
+		return err
+	}
+	if !pull {
+		logf(ctx, "Image already exists: %s", image)
+		return nil
+	}
 
-	logger.Printf("Pulling image: %s", image)
+	logf(ctx, "Pulling image: %s", image)
 	pullResp, err := dockerClient.ImagePull(ctx, image, types.ImagePullOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to pull image %s: %v", image, err)
@@ -270,15 +732,15 @@ func ensureImageExists(ctx context.Context, dockerClient *client.Client, image s
 			ID     string `json:"id"`
 		}
 		if err := json.Unmarshal(scanner.Bytes(), &status); err == nil {
-			logger.Printf("Pulling image: %s - %s", status.ID, status.Status)
+			logf(ctx, "Pulling image: %s - %s", status.ID, status.Status)
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		logger.Printf("Error reading pull response: %v", err)
+		logf(ctx, "Error reading pull response: %v", err)
 	}
 
-	logger.Printf("Successfully pulled image: %s", image)
+	logf(ctx, "Successfully pulled image: %s", image)
 	return nil
 }
 
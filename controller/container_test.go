@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+// labelledContainer 返回带控制器标签的容器，pause容器的名称为<name>-pause
+func labelledContainer(id, name, role string) types.Container {
+	cname := "/" + name
+	if role == rolePause {
+		cname += "-pause"
+	}
+	return types.Container{
+		ID:    id,
+		Names: []string{cname},
+		Labels: map[string]string{
+			labelManaged: "true",
+			labelName:    name,
+			labelRole:    role,
+		},
+	}
+}
+
+func TestMatchDeploymentContainers(t *testing.T) {
+	foo := []types.Container{
+		labelledContainer("foo-worker", "foo", roleWorker),
+		labelledContainer("foo-pause", "foo", rolePause),
+	}
+	fooBar := []types.Container{
+		labelledContainer("foo-bar-worker", "foo-bar", roleWorker),
+		labelledContainer("foo-bar-pause", "foo-bar", rolePause),
+	}
+
+	tests := []struct {
+		name       string
+		containers []types.Container
+		deployment string
+		wantWorker string
+		wantPause  string
+	}{
+		{
+			name:       "labelled pair",
+			containers: foo,
+			deployment: "foo",
+			wantWorker: "foo-worker",
+			wantPause:  "foo-pause",
+		},
+		{
+			name:       "prefix of another deployment",
+			containers: append(append([]types.Container{}, fooBar...), foo...),
+			deployment: "foo",
+			wantWorker: "foo-worker",
+			wantPause:  "foo-pause",
+		},
+		{
+			name:       "only a longer deployment exists",
+			containers: fooBar,
+			deployment: "foo",
+		},
+		{
+			name:       "other deployment's pause named like this worker",
+			containers: foo,
+			deployment: "foo-pause",
+		},
+		{
+			name: "legacy containers without labels",
+			containers: []types.Container{
+				{ID: "legacy-bar", Names: []string{"/foo-bar"}},
+				{ID: "legacy-worker", Names: []string{"/foo"}},
+				{ID: "legacy-pause", Names: []string{"/foo-pause"}},
+			},
+			deployment: "foo",
+			wantWorker: "legacy-worker",
+			wantPause:  "legacy-pause",
+		},
+		{
+			name: "labels take precedence over legacy names",
+			containers: []types.Container{
+				{ID: "legacy-worker", Names: []string{"/foo"}},
+				labelledContainer("foo-worker", "foo", roleWorker),
+			},
+			deployment: "foo",
+			wantWorker: "foo-worker",
+		},
+		{
+			name: "worker without pause",
+			containers: []types.Container{
+				labelledContainer("foo-worker", "foo", roleWorker),
+			},
+			deployment: "foo",
+			wantWorker: "foo-worker",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workerID, pauseID := matchDeploymentContainers(tt.containers, tt.deployment)
+			if workerID != tt.wantWorker || pauseID != tt.wantPause {
+				t.Errorf("matchDeploymentContainers(%q) = %q, %q, want %q, %q", tt.deployment, workerID, pauseID, tt.wantWorker, tt.wantPause)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"trex-controller/pkg/api"
+)
+
+// profileDir 工作容器内挂载流量配置文件的目录
+const profileDir = "/etc/trex/profiles"
+
+// ExecResult 描述在一个副本的工作容器中执行命令的结果，定义在pkg/api中
+type ExecResult = api.ExecResult
+
+// profileTarget 返回流量配置文件在工作容器内的路径
+func profileTarget(spec Spec) string {
+	return filepath.Join(profileDir, filepath.Base(spec.Profile))
+}
+
+// startCommand 返回/start执行的命令：未配置StartCommand时后台启动t-rex-64加载Profile，.py按ASTF模式加载
+func startCommand(spec Spec) ([]string, error) {
+	if len(spec.StartCommand) > 0 {
+		return spec.StartCommand, nil
+	}
+	if spec.Profile == "" {
+		return nil, fmt.Errorf("neither spec.profile nor spec.startCommand is set")
+	}
+	opts := ""
+	if filepath.Ext(spec.Profile) == ".py" {
+		opts = "--astf "
+	}
+	// t-rex-64默认读取/etc/trex_cfg.yaml
+	if spec.ConfigTarget != "" && spec.ConfigTarget != configTarget {
+		opts += fmt.Sprintf("--cfg %s ", spec.ConfigTarget)
+	}
+	return []string{"sh", "-c", fmt.Sprintf(`nohup ./t-rex-64 %s-f "$TREX_PROFILE" -c %d > /tmp/trex.log 2>&1 &`, opts, spec.Cores)}, nil
+}
+
+// stopCommand 返回/stop执行的命令，默认向t-rex-64发送SIGINT
+func stopCommand(spec Spec) []string {
+	if len(spec.StopCommand) > 0 {
+		return spec.StopCommand
+	}
+	return []string{"sh", "-c", "pkill -INT -f _t-rex-64"}
+}
+
+// startHandler 在部署的各副本工作容器中执行启动命令
+func startHandler(w http.ResponseWriter, r *http.Request) {
+	handleControl(w, r, "start")
+}
+
+// stopHandler 在部署的各副本工作容器中执行停止命令
+func stopHandler(w http.ResponseWriter, r *http.Request) {
+	handleControl(w, r, "stop")
+}
+
+// handleControl 处理/start/{name}和/stop/{name}，返回各副本的退出码和输出
+func handleControl(w http.ResponseWriter, r *http.Request, action string) {
+	requestsTotal.WithLabelValues(action).Inc()
+
+	if r.Method != "POST" {
+		recordFailure(action, http.StatusMethodNotAllowed)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/"+action+"/")
+	if name == "" || strings.Contains(name, "/") {
+		recordFailure(action, http.StatusBadRequest)
+		http.Error(w, "Invalid deployment name", http.StatusBadRequest)
+		return
+	}
+
+	lock := containerLocks.GetLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	record, ok := stateStore.Get(name)
+	if !ok {
+		recordFailure(action, http.StatusNotFound)
+		http.Error(w, fmt.Sprintf("Deployment %s not exist", name), http.StatusNotFound)
+		return
+	}
+
+	cmd := stopCommand(record.Config.Spec)
+	if action == "start" {
+		var err error
+		if cmd, err = startCommand(record.Config.Spec); err != nil {
+			recordFailure(action, http.StatusBadRequest)
+			http.Error(w, fmt.Sprintf("Cannot start %s: %v", name, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := withLogFields(context.WithoutCancel(r.Context()), action, name)
+	results := []ExecResult{}
+	for _, replica := range replicaNames(record.Config) {
+		logf(ctx, "Executing %v in %s", cmd, replica)
+		result, err := execInContainer(ctx, replica, cmd)
+		if err != nil {
+			logf(ctx, "Failed to %s %s: %v", action, replica, err)
+			recordFailure(action, http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logf(ctx, "Command in %s exited with code %d", replica, result.ExitCode)
+		results = append(results, *result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// execInContainer 在容器中执行命令，等待其结束并返回退出码和合并的stdout/stderr
+func execInContainer(ctx context.Context, containerName string, cmd []string) (*ExecResult, error) {
+	created, err := dockerClient.ContainerExecCreate(ctx, containerName, types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec in %s: %v", containerName, err)
+	}
+
+	attach, err := dockerClient.ContainerExecAttach(ctx, created.ID, types.ExecStartCheck{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start exec in %s: %v", containerName, err)
+	}
+	defer attach.Close()
+
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, attach.Reader); err != nil {
+		return nil, fmt.Errorf("failed to read exec output from %s: %v", containerName, err)
+	}
+
+	inspect, err := dockerClient.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect exec in %s: %v", containerName, err)
+	}
+
+	return &ExecResult{
+		Name:     containerName,
+		Command:  cmd,
+		ExitCode: inspect.ExitCode,
+		Output:   output.String(),
+	}, nil
+}
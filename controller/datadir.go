@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// applyDataDir 设置了-data-dir时，将未显式指定的-config-dir和-state-file放到数据目录下，
+// 并在启动时创建目录、确认可写，避免只读根文件系统等环境下到部署时才失败
+func applyDataDir() error {
+	if *dataDir == "" {
+		return nil
+	}
+	if !filepath.IsAbs(*dataDir) {
+		return fmt.Errorf("-data-dir %q must be an absolute path", *dataDir)
+	}
+
+	// 命令行和配置文件中设置的参数都会被Visit到
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	if !explicit["config-dir"] {
+		*configDir = filepath.Join(*dataDir, "config")
+	}
+	if !explicit["state-file"] {
+		*stateFile = filepath.Join(*dataDir, "state.json")
+	}
+
+	if err := os.MkdirAll(*dataDir, 0750); err != nil {
+		return fmt.Errorf("failed to create data directory %s: %v", *dataDir, err)
+	}
+	// 生成的配置文件需要被Docker绑定挂载到容器中
+	if err := os.MkdirAll(*configDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory %s: %v", *configDir, err)
+	}
+	for _, dir := range []string{*dataDir, *configDir, filepath.Dir(*stateFile)} {
+		if err := checkWritable(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkWritable 通过创建临时文件确认目录可写
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %v", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
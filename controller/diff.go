@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+)
+
+// configsEqual 比较两个部署配置是否等价。
+// 配置按JSON编码后比较，忽略仅内部使用的字段（json:"-"），并将null、空数组和空对象视为未设置，
+// 避免状态文件往返序列化或YAML中的"[]"造成误判；指针字段（如privileged: false）保持原值比较
+func configsEqual(a, b TRExConfig) (bool, error) {
+	na, err := normalizedConfig(a)
+	if err != nil {
+		return false, err
+	}
+	nb, err := normalizedConfig(b)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(na, nb), nil
+}
+
+// normalizedConfig 返回去除空值后的通用JSON表示
+func normalizedConfig(config TRExConfig) (interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config of %s: %v", config.Metadata.Name, err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to decode config of %s: %v", config.Metadata.Name, err)
+	}
+	return pruneEmpty(v), nil
+}
+
+// pruneEmpty 递归删除值为null、空数组或空对象的字段
+func pruneEmpty(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if item = pruneEmpty(item); isEmptyJSON(item) {
+				delete(val, k)
+			} else {
+				val[k] = item
+			}
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = pruneEmpty(item)
+		}
+		return val
+	}
+	return v
+}
+
+func isEmptyJSON(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(val) == 0
+	case []interface{}:
+		return len(val) == 0
+	}
+	return false
+}
+
+// appliedConfigHandler 处理/config/{name}?applied=true：GET返回部署最近一次apply/update保存的配置；
+// POST提交配置时返回其中名为name的配置补全默认值（LoadConfig）后的结果，不做任何修改。
+// 两者使用相同的规范化YAML输出（去除空值、按键排序），便于客户端直接比较
+func appliedConfigHandler(w http.ResponseWriter, r *http.Request, name string) {
+	var config TRExConfig
+	if r.Method == "POST" {
+		configs, err := decodeConfigs(r)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid config: %v", err), http.StatusBadRequest)
+			return
+		}
+		found := false
+		for _, c := range configs {
+			if c.Metadata.Name == name {
+				config, found = c, true
+				break
+			}
+		}
+		if !found {
+			http.Error(w, fmt.Sprintf("Config of %s not found in request", name), http.StatusBadRequest)
+			return
+		}
+		if err := LoadConfig(&config); err != nil {
+			http.Error(w, fmt.Sprintf("failed to load config: %v", err), http.StatusBadRequest)
+			return
+		}
+	} else {
+		record, ok := stateStore.Get(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("Deployment %s not exist", name), http.StatusNotFound)
+			return
+		}
+		config = record.Config
+	}
+
+	content, err := renderConfig(config)
+	if err != nil {
+		logger.Printf("Error rendering config of %s: %v", name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
+// renderConfig 将配置输出为规范化的YAML
+func renderConfig(config TRExConfig) ([]byte, error) {
+	v, err := normalizedConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	content, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config of %s: %v", config.Metadata.Name, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const resolvConfSuffix = "_resolv.conf"
+
+// resolvConfPath 返回部署对应的resolv.conf路径，与trex_cfg.yaml位于同一目录
+func resolvConfPath(name string) string {
+	return filepath.Join(*configDir, name+resolvConfSuffix)
+}
+
+// validateDNS 校验Spec.DNS为合法IP地址，Spec.DNSSearch为合法域名
+func validateDNS(spec Spec) error {
+	for i, server := range spec.DNS {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("trexConfig.Spec.DNS[%d] %q is not a valid IP address", i, server)
+		}
+	}
+	for i, domain := range spec.DNSSearch {
+		if domain == "" || len(domain) > 253 || strings.ContainsAny(domain, " \t\n") {
+			return fmt.Errorf("trexConfig.Spec.DNSSearch[%d] %q is not a valid domain", i, domain)
+		}
+	}
+	return nil
+}
+
+// createResolvConf 根据Spec.DNS和Spec.DNSSearch生成resolv.conf，均未设置时返回空路径。
+// 工作容器共享pause容器的网络命名空间，Docker不允许为其设置DNS，因此通过绑定挂载提供
+func createResolvConf(config TRExConfig) (string, error) {
+	if len(config.Spec.DNS) == 0 && len(config.Spec.DNSSearch) == 0 {
+		return "", nil
+	}
+
+	var b strings.Builder
+	for _, server := range config.Spec.DNS {
+		fmt.Fprintf(&b, "nameserver %s\n", server)
+	}
+	if len(config.Spec.DNSSearch) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(config.Spec.DNSSearch, " "))
+	}
+
+	if err := os.MkdirAll(*configDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %v", err)
+	}
+	path := resolvConfPath(config.Metadata.Name)
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write resolv.conf: %v", err)
+	}
+	return path, nil
+}
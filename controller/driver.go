@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	driverVfioPCI = "vfio-pci"
+	pciDevicesDir = "/sys/bus/pci/devices"
+	pciDriversDir = "/sys/bus/pci/drivers"
+)
+
+// vfPCIAddressByIndex 通过PF的virtfn链接获取VF的PCI地址，VF绑定到vfio-pci后没有网络接口时也可用；父接口为bond时使用拥有该VF的成员PF
+func vfPCIAddressByIndex(parentIfName string, vfIndex int) (string, error) {
+	pf, err := resolvePF(parentIfName, vfIndex)
+	if err != nil {
+		return "", err
+	}
+	link := filepath.Join("/sys/class/net", pf, "device", fmt.Sprintf("virtfn%d", vfIndex))
+	target, err := os.Readlink(link)
+	if err != nil {
+		return "", fmt.Errorf("VF %d of %s not exist: %v", vfIndex, parentIfName, err)
+	}
+	return filepath.Base(target), nil
+}
+
+// currentDriver 返回PCI设备当前绑定的驱动，未绑定时返回空字符串
+func currentDriver(pciAddr string) (string, error) {
+	target, err := os.Readlink(filepath.Join(pciDevicesDir, pciAddr, "driver"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read driver of %s: %v", pciAddr, err)
+	}
+	return filepath.Base(target), nil
+}
+
+// writeSysfs 向sysfs文件写入内容
+func writeSysfs(path, value string) error {
+	if err := os.WriteFile(path, []byte(value), 0200); err != nil {
+		return fmt.Errorf("failed to write %q to %s: %v", value, path, err)
+	}
+	return nil
+}
+
+// bindDriver 通过driver_override将PCI设备重新绑定到指定驱动
+func bindDriver(pciAddr, driver string) error {
+	current, err := currentDriver(pciAddr)
+	if err != nil {
+		return err
+	}
+	if current == driver {
+		return nil
+	}
+
+	if err := writeSysfs(filepath.Join(pciDevicesDir, pciAddr, "driver_override"), driver); err != nil {
+		return err
+	}
+	if current != "" {
+		if err := writeSysfs(filepath.Join(pciDriversDir, current, "unbind"), pciAddr); err != nil {
+			return err
+		}
+	}
+	return writeSysfs(filepath.Join(pciDriversDir, driver, "bind"), pciAddr)
+}
+
+// restoreDriver 清除driver_override并将PCI设备绑定回原驱动，原驱动为空时交由内核重新探测
+func restoreDriver(pciAddr, original string) error {
+	current, err := currentDriver(pciAddr)
+	if err != nil {
+		return err
+	}
+	if current != "" && current != original {
+		if err := writeSysfs(filepath.Join(pciDriversDir, current, "unbind"), pciAddr); err != nil {
+			return err
+		}
+	}
+	if err := writeSysfs(filepath.Join(pciDevicesDir, pciAddr, "driver_override"), "\n"); err != nil {
+		return err
+	}
+	if current == original && original != "" {
+		return nil
+	}
+	if original == "" {
+		return writeSysfs("/sys/bus/pci/drivers_probe", pciAddr)
+	}
+	return writeSysfs(filepath.Join(pciDriversDir, original, "bind"), pciAddr)
+}
+
+// bindVFDriver 将VF绑定到指定驱动，并在状态记录中保存原驱动以便删除时恢复
+func bindVFDriver(pciAddr, driver string) error {
+	original, err := currentDriver(pciAddr)
+	if err != nil {
+		return err
+	}
+	if original == driver {
+		return nil
+	}
+	// 先记录原驱动，绑定过程中控制器退出也能恢复
+	if err := stateStore.RecordDriver(pciAddr, original); err != nil {
+		return err
+	}
+	if err := bindDriver(pciAddr, driver); err != nil {
+		return err
+	}
+	logger.Printf("Bound VF %s to %s (original driver: %s)", pciAddr, driver, original)
+	return nil
+}
+
+// releaseVFDrivers 将部署中绑定过驱动的VF恢复到状态记录中保存的原驱动
+func releaseVFDrivers(config TRExConfig) {
+	if config.Spec.DriverBind == "" {
+		return
+	}
+	for _, port := range config.Spec.Port {
+		pciAddr, err := vfPCIAddressByIndex(config.Spec.ParentInterface, port.VFIndex)
+		if err != nil {
+			logger.Printf("Warning: %v", err)
+			continue
+		}
+		original, ok := stateStore.Driver(pciAddr)
+		if !ok {
+			continue
+		}
+		if err := restoreDriver(pciAddr, original); err != nil {
+			logger.Printf("Warning: failed to restore driver of VF %s: %v", pciAddr, err)
+			continue
+		}
+		if err := stateStore.ForgetDriver(pciAddr); err != nil {
+			logger.Printf("Warning: failed to update state for VF %s: %v", pciAddr, err)
+		}
+		logger.Printf("Restored VF %s to driver %q", pciAddr, original)
+	}
+}
+
+// vfioGroupDevice 返回PCI设备所在IOMMU组对应的/dev/vfio设备路径
+func vfioGroupDevice(pciAddr string) (string, error) {
+	target, err := os.Readlink(filepath.Join(pciDevicesDir, pciAddr, "iommu_group"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read IOMMU group of %s: %v", pciAddr, err)
+	}
+	return filepath.Join("/dev/vfio", filepath.Base(target)), nil
+}
+
+// vfioDevices 返回部署中已绑定vfio-pci的VF所需的设备路径：/dev/vfio/vfio及各VF的IOMMU组设备
+func vfioDevices(config TRExConfig) ([]string, error) {
+	if config.Spec.NetworkType != "SRIOV" {
+		return nil, nil
+	}
+	seen := make(map[string]bool)
+	var devices []string
+	for _, port := range config.Spec.Port {
+		pciAddr, err := vfPCIAddressByIndex(config.Spec.ParentInterface, port.VFIndex)
+		if err != nil {
+			return nil, err
+		}
+		driver, err := currentDriver(pciAddr)
+		if err != nil {
+			return nil, err
+		}
+		if driver != driverVfioPCI {
+			continue
+		}
+		group, err := vfioGroupDevice(pciAddr)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[group] {
+			seen[group] = true
+			devices = append(devices, group)
+		}
+	}
+	if len(devices) > 0 {
+		devices = append([]string{"/dev/vfio/vfio"}, devices...)
+	}
+	return devices, nil
+}
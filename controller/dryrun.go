@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/vishvananda/netlink"
+)
+
+// isDryRun 判断请求是否要求仅校验不执行
+func isDryRun(r *http.Request) bool {
+	return r.URL.Query().Get("dryRun") == "true" || strings.EqualFold(r.Header.Get("X-Dry-Run"), "true")
+}
+
+// 预演的部署操作，与applyTRExContainer和updateTRExContainer的选择一致
+const (
+	planCreate      = "would be created"
+	planRecreate    = "would be updated by deleting and recreating it"
+	planReconfigure = "would be updated in place (network only)"
+	planRedeploy    = "would be updated by recreating the worker containers only"
+)
+
+// dryRunTRExContainer 校验配置并返回将要执行的操作，不做任何Docker或netlink变更。
+// 与apply相同，已跟踪的部署先与保存的配置比较，未变化时只报告unchanged；未跟踪的部署容器已存在且未指定force时返回错误
+func dryRunTRExContainer(ctx context.Context, config TRExConfig, recreate, force bool) (string, error) {
+	if err := LoadConfig(&config); err != nil {
+		return "", fmt.Errorf("failed to load config: %v", err)
+	}
+
+	name := config.Metadata.Name
+	plan := planCreate
+	if previous, ok := stateStore.Get(name); ok {
+		var err error
+		if plan, err = dryRunUpdatePlan(previous.Config, config, recreate, force); err != nil {
+			return "", err
+		}
+		if plan == "" {
+			return fmt.Sprintf("Dry run for %s: deployment unchanged", name), nil
+		}
+	}
+
+	replicas, err := replicaConfigs(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %v", err)
+	}
+	// 与apply一致，创建时任一副本的容器已存在则报告同样的错误，force时会先删除这些容器
+	if plan == planCreate && !force {
+		for _, replica := range replicas {
+			workerID, pauseID, err := findDeploymentContainers(ctx, replica.Metadata.Name)
+			if err != nil {
+				return "", err
+			}
+			if workerID != "" || pauseID != "" {
+				return "", fmt.Errorf("container with name %s already exists", replica.Metadata.Name)
+			}
+		}
+	}
+
+	var report []string
+	report = append(report, fmt.Sprintf("Dry run for %s:", name))
+	report = append(report, fmt.Sprintf("deployment %s: %s", name, plan))
+
+	// 校验镜像
+	images := map[string]string{*pauseImage: pausePullPolicy(config.Spec.PullPolicy), config.Metadata.Image: config.Spec.PullPolicy}
+	for _, image := range []string{*pauseImage, config.Metadata.Image} {
+		action, err := checkImageResolvable(ctx, image, images[image])
+		if err != nil {
+			return "", err
+		}
+		report = append(report, fmt.Sprintf("image %s: %s", image, action))
+	}
+
+	// 校验网桥
+	if link, err := netlink.LinkByName(config.Spec.BrName); err == nil {
+		if _, ok := link.(*netlink.Bridge); !ok && config.Spec.BridgeType != bridgeTypeOVS {
+			return "", fmt.Errorf("%q already exists but is not a bridge", config.Spec.BrName)
+		}
+		report = append(report, fmt.Sprintf("bridge %s: exists", config.Spec.BrName))
+	} else {
+		report = append(report, fmt.Sprintf("bridge %s: would be created", config.Spec.BrName))
+	}
+	for _, brName := range dataPortBridges(config.Spec) {
+		if link, err := netlink.LinkByName(brName); err == nil {
+			if _, ok := link.(*netlink.Bridge); !ok && config.Spec.BridgeType != bridgeTypeOVS {
+				return "", fmt.Errorf("%q already exists but is not a bridge", brName)
+			}
+			report = append(report, fmt.Sprintf("data port bridge %s: exists", brName))
+		} else {
+			report = append(report, fmt.Sprintf("data port bridge %s: would be created", brName))
+		}
+	}
+
+	for _, replica := range replicas {
+		switch {
+		case plan == planReconfigure || plan == planRedeploy:
+			report = append(report, fmt.Sprintf("network namespace of %s: would be reused", replica.Metadata.Name))
+		case replica.Spec.NoPause:
+			report = append(report, fmt.Sprintf("worker container %s: would own the network namespace (no pause container)", replica.Metadata.Name))
+		default:
+			report = append(report, fmt.Sprintf("pause container %s-pause: would be created", replica.Metadata.Name))
+		}
+		vethHost, _ := getPairName(replica.Metadata.Name, "")
+		gateway := replica.Spec.MgmtGateway
+		if gateway == "" {
+			gateway = "no default route"
+		}
+		report = append(report, fmt.Sprintf("veth %s: would be attached to %s, mgmt IP %s via %s",
+			vethHost, replica.Spec.BrName, strings.Join(mgmtAddresses(replica.Spec), ","), gateway))
+		if replica.Spec.ExposeNetns {
+			report = append(report, fmt.Sprintf("netns %s: would be exposed at %s", replica.Metadata.Name, netnsPath(replica.Metadata.Name)))
+		}
+		for _, r := range replica.Spec.Routes {
+			report = append(report, fmt.Sprintf("route %s via %s: would be added to %s", r.Dst, r.Gw, replica.Spec.MgmtIFName))
+		}
+	}
+
+	// 校验父接口及VF
+	if config.Spec.NetworkType == "SRIOV" {
+		if err := validateVFs(config); err != nil {
+			return "", err
+		}
+		nodes, err := checkVFNumaNodes(ctx, &config)
+		if err != nil {
+			return "", err
+		}
+		parent := config.Spec.ParentInterface
+		for _, port := range config.Spec.Port {
+			vfName := vfIFName(parent, port.VFIndex)
+			report = append(report, fmt.Sprintf("VF %s: would be set to VLAN %s (NUMA node %d)", vfName, vlanTagStack(port), nodes[vfName]))
+			if port.QinQ > 0 {
+				report = append(report, fmt.Sprintf("VF %s: inner VLAN %d would be passed to configTemplate", vfName, port.QinQ))
+			}
+		}
+		if node, spans := commonNumaNode(nodes); spans {
+			report = append(report, "warning: VFs span multiple NUMA nodes")
+		} else if node >= 0 && node != config.Spec.NumaSocket {
+			report = append(report, fmt.Sprintf("warning: VFs are on NUMA node %d but numaSocket is %d", node, config.Spec.NumaSocket))
+		}
+	}
+
+	if config.Spec.NetworkType == "VETH" {
+		for i, port := range config.Spec.Port {
+			brName := portBridgeName(config.Spec, port)
+			if port.VlanId > 0 && config.Spec.VlanFiltering {
+				report = append(report, fmt.Sprintf("data port %s: would be created as veth attached to %s with PVID %d", dataPortIFName(port, i), brName, port.VlanId))
+			} else if port.VlanId > 0 {
+				report = append(report, fmt.Sprintf("data port %s: would be created as VLAN %d sub-interface", dataPortIFName(port, i), port.VlanId))
+			} else {
+				report = append(report, fmt.Sprintf("data port %s: would be created as veth attached to %s", dataPortIFName(port, i), brName))
+			}
+		}
+	}
+
+	if plan != planReconfigure {
+		for _, replica := range replicas {
+			report = append(report, fmt.Sprintf("worker container %s: would be created from %s with %d port(s)", replica.Metadata.Name, replica.Metadata.Image, len(replica.Spec.Port)))
+		}
+	}
+
+	if !config.Spec.DisableHugepages {
+		if *requireHugepages {
+			if err := checkHugepages(ctx, config); err != nil {
+				return "", err
+			}
+		}
+		if total, free, err := hugepageCounts(); err != nil {
+			report = append(report, fmt.Sprintf("warning: %v", err))
+		} else if free == 0 {
+			report = append(report, fmt.Sprintf("warning: no free hugepages (%d reserved)", total))
+		} else {
+			report = append(report, fmt.Sprintf("hugepages: %d of %d free", free, total))
+		}
+	}
+
+	if err := checkPublishPortConflicts(config); err != nil {
+		return "", err
+	}
+	if targetIP, err := publishTargetIP(config.Spec); err == nil {
+		for _, p := range config.Spec.PublishPorts {
+			if fwd, err := parsePublishPort(p); err == nil {
+				report = append(report, fmt.Sprintf("host port %d/%s: would be forwarded to %s:%d", fwd.HostPort, fwd.Proto, targetIP, fwd.ContainerPort))
+			}
+		}
+	}
+
+	return strings.Join(report, "\n"), nil
+}
+
+// dryRunUpdatePlan 返回已跟踪的部署将执行的更新方式，配置未变化时返回空字符串。
+// force时总是重建；recreate时有变化即重建；否则按updateTRExContainer的顺序优先在线调整网络，其次只重建工作容器
+func dryRunUpdatePlan(previous, desired TRExConfig, recreate, force bool) (string, error) {
+	if force {
+		return planRecreate, nil
+	}
+	equal, err := configsEqual(previous, desired)
+	if err != nil || equal {
+		return "", err
+	}
+	if recreate {
+		return planRecreate, nil
+	}
+	hot, err := networkOnlyChange(previous, desired)
+	if err != nil {
+		return "", err
+	}
+	if hot {
+		return planReconfigure, nil
+	}
+	workerOnly, err := workerOnlyChange(previous, desired)
+	if err != nil {
+		return "", err
+	}
+	if workerOnly {
+		return planRedeploy, nil
+	}
+	return planRecreate, nil
+}
+
+// checkImageResolvable 检查镜像在本地或镜像仓库中是否可用
+func checkImageResolvable(ctx context.Context, image, policy string) (string, error) {
+	_, _, err := dockerClient.ImageInspectWithRaw(ctx, image)
+	if err != nil && !client.IsErrNotFound(err) {
+		return "", fmt.Errorf("failed to inspect image %s: %v", image, err)
+	}
+	present := err == nil
+	pull, err := needPull(image, policy, present)
+	if err != nil {
+		return "", err
+	}
+	if !pull {
+		return "present locally", nil
+	}
+
+	if _, err := dockerClient.DistributionInspect(ctx, image, ""); err != nil {
+		return "", fmt.Errorf("image %s is not resolvable: %v", image, err)
+	}
+	if present {
+		return "present locally, would be re-pulled (pullPolicy Always)", nil
+	}
+	return "would be pulled", nil
+}
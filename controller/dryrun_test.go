@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// loadedConfig 返回经过LoadConfig补全默认值的validConfig，mutate在LoadConfig之前修改配置
+func loadedConfig(t *testing.T, mutate func(*Spec)) TRExConfig {
+	t.Helper()
+	config := validConfig()
+	mutate(&config.Spec)
+	if err := LoadConfig(&config); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return config
+}
+
+func TestDryRunUpdatePlan(t *testing.T) {
+	previous := loadedConfig(t, func(s *Spec) {})
+
+	tests := []struct {
+		name     string
+		mutate   func(*TRExConfig)
+		recreate bool
+		force    bool
+		want     string
+	}{
+		{name: "unchanged", mutate: func(c *TRExConfig) {}},
+		{name: "unchanged with force", mutate: func(c *TRExConfig) {}, force: true, want: planRecreate},
+		{name: "unchanged with recreate", mutate: func(c *TRExConfig) {}, recreate: true},
+		{name: "management address", mutate: func(c *TRExConfig) { c.Spec.MgmtIP = "192.168.100.11/24" }, want: planReconfigure},
+		{name: "management address with recreate", mutate: func(c *TRExConfig) { c.Spec.MgmtIP = "192.168.100.11/24" }, recreate: true, want: planRecreate},
+		{name: "image", mutate: func(c *TRExConfig) { c.Metadata.Image = "docker.io/library/trex:v3.05" }, want: planRedeploy},
+		{name: "parent interface", mutate: func(c *TRExConfig) { c.Spec.ParentInterface = "ens2f0" }, want: planRecreate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired := previous
+			desired.Spec.Port = append([]Port(nil), previous.Spec.Port...)
+			tt.mutate(&desired)
+			got, err := dryRunUpdatePlan(previous, desired, tt.recreate, tt.force)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("dryRunUpdatePlan = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRunUnchangedDeployment(t *testing.T) {
+	useTestStateStore(t)
+	if err := stateStore.Put("trex-test", DeploymentRecord{Config: loadedConfig(t, func(s *Spec) {})}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未补全默认值的相同配置同样视为未变化，与apply一致
+	got, err := dryRunTRExContainer(context.Background(), validConfig(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Dry run for trex-test: deployment unchanged"; got != want {
+		t.Errorf("dryRunTRExContainer = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// errTooManyDeploys 并发部署数量已达上限
+var errTooManyDeploys = errors.New("too many concurrent deployments, retry later")
+
+// errDockerTimeout 操作在-docker-timeout内未完成，通常是Docker守护进程无响应
+var errDockerTimeout = errors.New("timed out waiting for the Docker daemon")
+
+// httpStatusFor 将操作错误映射为HTTP状态码
+func httpStatusFor(err error) int {
+	switch {
+	case errors.Is(err, errTooManyDeploys):
+		return http.StatusTooManyRequests
+	case errors.Is(err, errDockerTimeout):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// requestContext 返回单个操作使用的context：随客户端断开而取消，设置了-docker-timeout时带有截止时间
+func requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if *dockerTimeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), *dockerTimeout)
+}
+
+// detachedContext 返回不随请求取消、重新计时的context，保证失败后的清理和回滚在请求超时或客户端断开后仍能完成
+func detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithoutCancel(ctx)
+	if *dockerTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, *dockerTimeout)
+}
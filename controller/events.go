@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"trex-controller/pkg/api"
+)
+
+const (
+	eventStarted   = "started"
+	eventSucceeded = "succeeded"
+	eventFailed    = "failed"
+	eventRepaired  = "repaired"
+
+	// eventBufferSize 每个订阅者的缓冲事件数，缓冲满时断开该订阅者
+	eventBufferSize = 64
+	// eventKeepalive SSE保活注释的发送间隔，避免代理断开空闲连接
+	eventKeepalive = 15 * time.Second
+)
+
+// Event 部署生命周期事件，定义在pkg/api中
+type Event = api.Event
+
+// eventBroker 将事件分发给所有订阅者，发布时不阻塞
+type eventBroker struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+var events = &eventBroker{subs: make(map[chan Event]struct{})}
+
+// Subscribe 注册订阅者，返回的channel在取消订阅或消费过慢被断开时关闭
+func (b *eventBroker) Subscribe() chan Event {
+	ch := make(chan Event, eventBufferSize)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe 移除订阅者并关闭其channel，已被断开的订阅者忽略
+func (b *eventBroker) Unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// CloseAll 断开全部订阅者，用于服务关闭时结束SSE连接
+func (b *eventBroker) CloseAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Publish 向所有订阅者发送事件，缓冲已满的订阅者会被断开，不影响部署流程
+func (b *eventBroker) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			logger.Printf("Warning: dropping slow event subscriber")
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishEvent 发布与请求相关的事件，请求ID取自context
+func publishEvent(ctx context.Context, eventType, action, name, message string) {
+	events.Publish(Event{
+		Type:      eventType,
+		Action:    action,
+		Name:      name,
+		RequestID: requestIDFrom(ctx),
+		Message:   message,
+	})
+}
+
+// eventsHandler 以SSE（text/event-stream）推送部署生命周期事件，每个事件为一行JSON
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := events.Subscribe()
+	defer events.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-ch:
+			if !ok {
+				// 消费过慢被断开，客户端重连即可继续接收
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
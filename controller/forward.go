@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// portForward 描述一条主机端口到工作容器端口的转发
+type portForward struct {
+	HostPort      int
+	ContainerPort int
+	Proto         string
+}
+
+// parsePublishPort 解析Spec.PublishPorts中的一项，格式为hostPort:containerPort[/tcp|udp]
+func parsePublishPort(s string) (portForward, error) {
+	fwd := portForward{Proto: "tcp"}
+	ports := s
+	if spec, proto, ok := strings.Cut(s, "/"); ok {
+		if proto != "tcp" && proto != "udp" {
+			return fwd, fmt.Errorf("publish port %q: protocol must be tcp or udp", s)
+		}
+		ports, fwd.Proto = spec, proto
+	}
+
+	hostPort, containerPort, ok := strings.Cut(ports, ":")
+	if !ok {
+		return fwd, fmt.Errorf("publish port %q must be in the form hostPort:containerPort", s)
+	}
+	var err error
+	if fwd.HostPort, err = parsePortNumber(hostPort); err != nil {
+		return fwd, fmt.Errorf("publish port %q: %v", s, err)
+	}
+	if fwd.ContainerPort, err = parsePortNumber(containerPort); err != nil {
+		return fwd, fmt.Errorf("publish port %q: %v", s, err)
+	}
+	return fwd, nil
+}
+
+func parsePortNumber(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
+
+// publishTargetIP 返回端口转发的目的地址，即第一个IPv4管理地址
+func publishTargetIP(spec Spec) (string, error) {
+	for _, addr := range mgmtAddresses(spec) {
+		ip, _, err := net.ParseCIDR(addr)
+		if err == nil && ip.To4() != nil {
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("publishPorts requires an IPv4 management address")
+}
+
+// validatePublishPorts 校验Spec.PublishPorts，主机端口+协议不能重复
+func validatePublishPorts(spec Spec) error {
+	if len(spec.PublishPorts) == 0 {
+		return nil
+	}
+	if spec.Replicas > 1 {
+		return fmt.Errorf("trexConfig.Spec.PublishPorts is not supported with more than one replica")
+	}
+	if _, err := publishTargetIP(spec); err != nil {
+		return fmt.Errorf("trexConfig.Spec.PublishPorts: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for i, p := range spec.PublishPorts {
+		fwd, err := parsePublishPort(p)
+		if err != nil {
+			return fmt.Errorf("trexConfig.Spec.PublishPorts[%d]: %v", i, err)
+		}
+		key := fmt.Sprintf("%d/%s", fwd.HostPort, fwd.Proto)
+		if seen[key] {
+			return fmt.Errorf("trexConfig.Spec.PublishPorts[%d]: host port %s is published twice", i, key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// checkPublishPortConflicts 检查主机端口是否已被其他部署发布
+func checkPublishPortConflicts(config TRExConfig) error {
+	used := make(map[string]string)
+	for name, record := range stateStore.List() {
+		if name == config.Metadata.Name {
+			continue
+		}
+		for _, p := range record.Config.Spec.PublishPorts {
+			if fwd, err := parsePublishPort(p); err == nil {
+				used[fmt.Sprintf("%d/%s", fwd.HostPort, fwd.Proto)] = name
+			}
+		}
+	}
+	for _, p := range config.Spec.PublishPorts {
+		fwd, err := parsePublishPort(p)
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%d/%s", fwd.HostPort, fwd.Proto)
+		if owner, ok := used[key]; ok {
+			return fmt.Errorf("host port %s is already published by %s", key, owner)
+		}
+	}
+	return nil
+}
+
+// forwardRules 返回一条端口转发对应的iptables规则（表、链及规则参数）：
+// PREROUTING/OUTPUT做DNAT使外部和本机访问都能到达管理地址，FORWARD放行（Docker会将默认策略设为DROP），
+// POSTROUTING做MASQUERADE使回包经过本机，不依赖管理网关
+func forwardRules(name string, fwd portForward, targetIP string) [][]string {
+	comment := []string{"-m", "comment", "--comment", "trex-controller:" + name}
+	dest := fmt.Sprintf("%s:%d", targetIP, fwd.ContainerPort)
+	hostPort := strconv.Itoa(fwd.HostPort)
+	containerPort := strconv.Itoa(fwd.ContainerPort)
+
+	rule := func(table, chain string, args ...string) []string {
+		return append(append([]string{"-t", table, chain}, args...), comment...)
+	}
+	return [][]string{
+		rule("nat", "PREROUTING", "-p", fwd.Proto, "-m", "addrtype", "--dst-type", "LOCAL", "--dport", hostPort, "-j", "DNAT", "--to-destination", dest),
+		rule("nat", "OUTPUT", "-p", fwd.Proto, "-m", "addrtype", "--dst-type", "LOCAL", "--dport", hostPort, "-j", "DNAT", "--to-destination", dest),
+		rule("filter", "FORWARD", "-p", fwd.Proto, "-d", targetIP, "--dport", containerPort, "-j", "ACCEPT"),
+		rule("nat", "POSTROUTING", "-p", fwd.Proto, "-d", targetIP, "--dport", containerPort, "-j", "MASQUERADE"),
+	}
+}
+
+// iptables 执行iptables命令，action为-A、-I、-C或-D，rule为forwardRules返回的规则
+func iptables(action string, rule []string) error {
+	// rule格式为 -t TABLE CHAIN ARGS...
+	args := append([]string{"-w", rule[0], rule[1], action}, rule[2:]...)
+	out, err := exec.Command("iptables", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("iptables %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// setupPortForwards 为部署添加Spec.PublishPorts对应的iptables转发规则，失败时删除已添加的规则
+func setupPortForwards(ctx context.Context, config TRExConfig) error {
+	if len(config.Spec.PublishPorts) == 0 {
+		return nil
+	}
+	targetIP, err := publishTargetIP(config.Spec)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range config.Spec.PublishPorts {
+		fwd, err := parsePublishPort(p)
+		if err != nil {
+			removePortForwards(config)
+			return err
+		}
+		for _, rule := range forwardRules(config.Metadata.Name, fwd, targetIP) {
+			if iptables("-C", rule) == nil {
+				continue
+			}
+			action := "-A"
+			if rule[2] == "FORWARD" {
+				// FORWARD需要插入到Docker的规则之前
+				action = "-I"
+			}
+			if err := iptables(action, rule); err != nil {
+				removePortForwards(config)
+				return err
+			}
+		}
+		logf(ctx, "Published host port %d/%s to %s:%d for %s", fwd.HostPort, fwd.Proto, targetIP, fwd.ContainerPort, config.Metadata.Name)
+	}
+	return nil
+}
+
+// removePortForwards 删除部署的端口转发规则，规则不存在时忽略
+func removePortForwards(config TRExConfig) {
+	targetIP, err := publishTargetIP(config.Spec)
+	if err != nil {
+		return
+	}
+	for _, p := range config.Spec.PublishPorts {
+		fwd, err := parsePublishPort(p)
+		if err != nil {
+			continue
+		}
+		for _, rule := range forwardRules(config.Metadata.Name, fwd, targetIP) {
+			for iptables("-C", rule) == nil {
+				if err := iptables("-D", rule); err != nil {
+					logger.Printf("Warning: failed to remove port forward of %s: %v", config.Metadata.Name, err)
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/vishvananda/netlink"
+	"trex-controller/pkg/api"
+)
+
+const configFileSuffix = "_trex_cfg.yaml"
+
+// GCResult 定义/gc接口的返回结果，定义在pkg/api中
+type GCResult = api.GCResult
+
+// gcHandler 清理没有对应部署的veth和trex_cfg.yaml，?dryRun=true时只报告不删除
+func gcHandler(w http.ResponseWriter, r *http.Request) {
+	requestsTotal.WithLabelValues("gc").Inc()
+
+	if r.Method != "POST" {
+		recordFailure("gc", http.StatusMethodNotAllowed)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := collectGarbage(r.Context(), isDryRun(r))
+	if err != nil {
+		logger.Printf("Garbage collection failed: %v", err)
+		recordFailure("gc", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// collectGarbage 查找并删除孤立的host端veth和配置文件。
+// 先列出接口和文件再查询部署：列出之后才开始的apply在创建veth之前已经创建了pause容器，不会被误删
+func collectGarbage(ctx context.Context, dryRun bool) (*GCResult, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(*configDir, "*"+configFileSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config files: %v", err)
+	}
+
+	known, err := knownDeploymentNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	knownShort := make(map[string]bool, len(known))
+	for name := range known {
+		knownShort[shortName(name)] = true
+	}
+
+	result := &GCResult{DryRun: dryRun, Veths: []string{}, ConfigFiles: []string{}}
+
+	for _, link := range links {
+		linkName := link.Attrs().Name
+		if !strings.HasPrefix(linkName, "trex_") || knownShort[strings.TrimPrefix(linkName, "trex_")] {
+			continue
+		}
+		if !dryRun {
+			logger.Printf("GC: deleting orphaned veth %s", linkName)
+			if err := deleteVethPair(linkName); err != nil {
+				logger.Printf("Warning: failed to delete veth %s: %v", linkName, err)
+				continue
+			}
+		}
+		result.Veths = append(result.Veths, linkName)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), configFileSuffix)
+		if known[name] {
+			continue
+		}
+		if !dryRun {
+			// 正在操作的部署跳过，下次再清理
+			lock := containerLocks.GetLock(name)
+			if !lock.TryLock() {
+				continue
+			}
+			logger.Printf("GC: deleting orphaned config file %s", file)
+			err := os.Remove(file)
+			lock.Unlock()
+			if err != nil && !os.IsNotExist(err) {
+				logger.Printf("Warning: failed to delete config file %s: %v", file, err)
+				continue
+			}
+		}
+		result.ConfigFiles = append(result.ConfigFiles, file)
+	}
+
+	return result, nil
+}
+
+// knownDeploymentNames 返回状态记录中的部署（含副本）以及Docker中带有管理标签的容器名称
+func knownDeploymentNames(ctx context.Context) (map[string]bool, error) {
+	known := make(map[string]bool)
+	for _, record := range stateStore.List() {
+		for _, name := range replicaNames(record.Config) {
+			known[name] = true
+		}
+	}
+
+	containers, err := dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", labelManaged+"=true")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list managed containers: %v", err)
+	}
+	for _, c := range containers {
+		if name := c.Labels[labelName]; name != "" {
+			known[name] = true
+		}
+	}
+	return known, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// containerHostname 返回容器的主机名，未设置Spec.Hostname时使用部署（副本）名称
+func containerHostname(config TRExConfig) string {
+	if config.Spec.Hostname != "" {
+		return config.Spec.Hostname
+	}
+	return config.Metadata.Name
+}
+
+// isValidHostname 校验RFC 1123主机名：总长度不超过253，各标签为1-63个字母、数字或'-'，且不以'-'开头或结尾
+func isValidHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validateHosts 校验Spec.Hostname（多副本时包括追加的副本序号）及Spec.ExtraHosts，
+// ExtraHosts与docker run --add-host格式相同：主机名:IP，IP也可以是host-gateway
+func validateHosts(spec Spec) error {
+	if spec.Hostname != "" {
+		hostname := spec.Hostname
+		if spec.Replicas > 1 {
+			hostname = fmt.Sprintf("%s-%d", spec.Hostname, spec.Replicas-1)
+		}
+		if !isValidHostname(hostname) {
+			return fmt.Errorf("trexConfig.Spec.Hostname %q is not a valid hostname", hostname)
+		}
+	}
+	for i, entry := range spec.ExtraHosts {
+		host, ip, ok := strings.Cut(entry, ":")
+		if !ok || !isValidHostname(host) {
+			return fmt.Errorf("trexConfig.Spec.ExtraHosts[%d] %q must be in the form host:IP", i, entry)
+		}
+		if ip != "host-gateway" && net.ParseIP(ip) == nil {
+			return fmt.Errorf("trexConfig.Spec.ExtraHosts[%d] %q has an invalid IP address %q", i, entry, ip)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	hugepagesSysfsDir = "/sys/kernel/mm/hugepages"
+	// recommendedHugepages 提示中建议每个部署预留的2MB大页数（2GB）
+	recommendedHugepages = 1024
+)
+
+// hugepageCounts 返回宿主机全部大页大小合计的大页总数和空闲数：
+// 优先读取/sys/kernel/mm/hugepages/hugepages-*/{nr_hugepages,free_hugepages}，
+// 不存在时读取/proc/meminfo中默认大页大小的HugePages_Total和HugePages_Free
+func hugepageCounts() (int, int, error) {
+	dirs, _ := filepath.Glob(filepath.Join(hugepagesSysfsDir, "hugepages-*"))
+	if len(dirs) > 0 {
+		total, free := 0, 0
+		for _, dir := range dirs {
+			nr, err := readIntFile(filepath.Join(dir, "nr_hugepages"))
+			if err != nil {
+				return 0, 0, err
+			}
+			n, err := readIntFile(filepath.Join(dir, "free_hugepages"))
+			if err != nil {
+				return 0, 0, err
+			}
+			total += nr
+			free += n
+		}
+		return total, free, nil
+	}
+
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read hugepages: %v", err)
+	}
+	defer file.Close()
+	total, free := -1, -1
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "HugePages_Total":
+			total, _ = strconv.Atoi(strings.TrimSpace(value))
+		case "HugePages_Free":
+			free, _ = strconv.Atoi(strings.TrimSpace(value))
+		}
+	}
+	if total < 0 || free < 0 {
+		return 0, 0, fmt.Errorf("HugePages_Total/HugePages_Free not found in /proc/meminfo")
+	}
+	return total, free, nil
+}
+
+func readIntFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid value in %s: %v", path, err)
+	}
+	return n, nil
+}
+
+// checkHugepages 部署前检查宿主机是否有空闲大页，DPDK在没有大页时会启动失败。
+// 没有空闲大页时默认只记录告警（software模式不需要大页），开启-require-hugepages时返回错误；DisableHugepages的部署不检查
+func checkHugepages(ctx context.Context, config TRExConfig) error {
+	if config.Spec.DisableHugepages {
+		return nil
+	}
+	total, free, err := hugepageCounts()
+	if err != nil {
+		if *requireHugepages {
+			return err
+		}
+		logf(ctx, "Warning: failed to check hugepages for %s: %v", config.Metadata.Name, err)
+		return nil
+	}
+	if free > 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("no free hugepages for %s (%d reserved, all in use); reserve at least %d 2MB pages, e.g. `echo %d > %s/hugepages-2048kB/nr_hugepages`",
+		config.Metadata.Name, total, recommendedHugepages, total+recommendedHugepages, hugepagesSysfsDir)
+	if total == 0 {
+		msg = fmt.Sprintf("no hugepages reserved for %s; reserve at least %d 2MB pages, e.g. `echo %d > %s/hugepages-2048kB/nr_hugepages`",
+			config.Metadata.Name, recommendedHugepages, recommendedHugepages, hugepagesSysfsDir)
+	}
+	if *requireHugepages {
+		return fmt.Errorf("%s", msg)
+	}
+	logf(ctx, "Warning: %s", msg)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	// 注册sha256，否则带摘要的引用会被判定为不支持的算法
+	_ "crypto/sha256"
+	"fmt"
+
+	"github.com/docker/distribution/reference"
+)
+
+// normalizeImageRef 校验镜像引用并返回规范化的简写形式（如docker.io/library/trex:latest返回trex:latest），
+// 首尾空白、大写仓库名、非法标签或摘要都会被拒绝
+func normalizeImageRef(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %v", image, err)
+	}
+	return reference.FamiliarString(named), nil
+}
+
+// 镜像拉取策略，与Kubernetes的imagePullPolicy一致
+const (
+	pullAlways       = "Always"
+	pullIfNotPresent = "IfNotPresent"
+	pullNever        = "Never"
+)
+
+// isDigestRef 判断镜像引用是否带摘要，带摘要的镜像内容不可变，本地存在时无需重新拉取
+func isDigestRef(image string) bool {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return false
+	}
+	_, ok := named.(reference.Digested)
+	return ok
+}
+
+// pausePullPolicy pause镜像不会变化，Always时也只在本地不存在时拉取
+func pausePullPolicy(policy string) string {
+	if policy == pullNever {
+		return pullNever
+	}
+	return pullIfNotPresent
+}
+
+// needPull 根据拉取策略和本地是否存在判断是否需要拉取镜像，Never且本地不存在时返回错误
+func needPull(image, policy string, present bool) (bool, error) {
+	switch {
+	case present && (policy != pullAlways || isDigestRef(image)):
+		return false, nil
+	case present:
+		return true, nil
+	case policy == pullNever:
+		return false, fmt.Errorf("image %s not present locally and pullPolicy is Never", image)
+	}
+	return true, nil
+}
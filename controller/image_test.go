@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func TestNormalizeImageRef(t *testing.T) {
+	const digest = "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		image   string
+		want    string
+		wantErr bool
+	}{
+		{image: "trex:v3.04", want: "trex:v3.04"},
+		{image: "docker.io/library/trex:v3.04", want: "trex:v3.04"},
+		{image: "docker.io/cisco/trex", want: "cisco/trex"},
+		{image: "registry.example.com:5000/net/trex:v3.04", want: "registry.example.com:5000/net/trex:v3.04"},
+		{image: "trex@" + digest, want: "trex@" + digest},
+		{image: "registry.example.com/trex:v3.04@" + digest, want: "registry.example.com/trex:v3.04@" + digest},
+		{image: "Trex:v3.04", wantErr: true},
+		{image: "trex:", wantErr: true},
+		{image: "trex@sha256:1234", wantErr: true},
+		{image: "trex:v3.04 ", wantErr: true},
+		{image: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, err := normalizeImageRef(tt.image)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("normalizeImageRef(%q) = %q, want error", tt.image, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeImageRef(%q): %v", tt.image, err)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeImageRef(%q) = %q, want %q", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsDigestRef(t *testing.T) {
+	const digest = "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := map[string]bool{
+		"trex:v3.04":                    false,
+		"trex@" + digest:                true,
+		"trex:v3.04@" + digest:          true,
+		"invalid reference with spaces": false,
+	}
+	for image, want := range tests {
+		if got := isDigestRef(image); got != want {
+			t.Errorf("isDigestRef(%q) = %v, want %v", image, got, want)
+		}
+	}
+}
+
+func TestNeedPull(t *testing.T) {
+	const digest = "trex@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		image, policy string
+		present       bool
+		want          bool
+		wantErr       bool
+	}{
+		{"trex:v3.04", pullIfNotPresent, true, false, false},
+		{"trex:v3.04", pullIfNotPresent, false, true, false},
+		{"trex:v3.04", pullAlways, true, true, false},
+		{digest, pullAlways, true, false, false},
+		{"trex:v3.04", pullNever, false, false, true},
+	}
+	for _, tt := range tests {
+		got, err := needPull(tt.image, tt.policy, tt.present)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("needPull(%q, %s, %v) = %v, %v; want %v, error %v", tt.image, tt.policy, tt.present, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// deploySem 限制同时进行的部署数量，为nil时不限制
+var deploySem *semaphore.Weighted
+
+func initDeployLimiter() {
+	if *maxConcurrentDeploys > 0 {
+		deploySem = semaphore.NewWeighted(int64(*maxConcurrentDeploys))
+	}
+}
+
+// acquireDeploySlot 获取一个部署名额，-deploy-queue-timeout为0时不等待
+func acquireDeploySlot() (func(), error) {
+	if deploySem == nil {
+		return func() {}, nil
+	}
+
+	if *deployQueueTimeout <= 0 {
+		if !deploySem.TryAcquire(1) {
+			return nil, errTooManyDeploys
+		}
+		return func() { deploySem.Release(1) }, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *deployQueueTimeout)
+	defer cancel()
+	if err := deploySem.Acquire(ctx, 1); err != nil {
+		return nil, fmt.Errorf("waited %s for a deploy slot: %w", *deployQueueTimeout, errTooManyDeploys)
+	}
+	return func() { deploySem.Release(1) }, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// resolveListenAddr 返回API的监听地址：以-listen为准，显式设置的-port只替换其中的端口
+func resolveListenAddr() (string, error) {
+	host, port, err := net.SplitHostPort(*listenAddr)
+	if err != nil {
+		return "", fmt.Errorf("-listen %q must be host:port: %v", *listenAddr, err)
+	}
+
+	portSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "port" {
+			portSet = true
+		}
+	})
+	if portSet {
+		port = *serverPort
+	}
+
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("listen port %q must be in 1-65535", port)
+	}
+	// 主机部分为空表示监听所有接口，否则必须是IP地址或可解析的主机名
+	if host != "" && net.ParseIP(host) == nil {
+		if _, err := net.LookupHost(host); err != nil {
+			return "", fmt.Errorf("listen host %q is not an IP address or resolvable host name: %v", host, err)
+		}
+	}
+	return net.JoinHostPort(host, port), nil
+}
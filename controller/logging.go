@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// slogger JSON日志格式下使用的结构化日志记录器，文本格式时为nil
+var slogger *slog.Logger
+
+// logFieldsKey 请求日志字段在context中的键
+type logFieldsKey struct{}
+
+// logFields 随context传递的日志字段
+type logFields struct {
+	Action string
+	Name   string
+}
+
+// withLogFields 在context中记录当前请求的操作和部署名称
+func withLogFields(ctx context.Context, action, name string) context.Context {
+	return context.WithValue(ctx, logFieldsKey{}, logFields{Action: action, Name: name})
+}
+
+// newLogger 根据日志格式创建日志记录器；JSON格式下返回的*log.Logger会把每行日志转为结构化日志，
+// 已有的logger.Printf调用无需修改
+func newLogger(w io.Writer, format, level string) (*log.Logger, error) {
+	switch format {
+	case "", logFormatText:
+		return log.New(w, "", log.LstdFlags|log.Lmicroseconds|log.Lshortfile), nil
+	case logFormatJSON:
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %v", level, err)
+		}
+		slogger = slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl}))
+		return log.New(slogWriter{}, "", 0), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, must be %s or %s", format, logFormatText, logFormatJSON)
+	}
+}
+
+// slogWriter 将*log.Logger输出的一行日志转发给slogger
+type slogWriter struct{}
+
+func (slogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	slogger.Log(context.Background(), levelOf(msg), msg)
+	return len(p), nil
+}
+
+// levelOf 根据日志内容推断级别，兼容现有以Warning/Error开头的日志
+func levelOf(msg string) slog.Level {
+	switch {
+	case strings.HasPrefix(msg, "Debug"):
+		return slog.LevelDebug
+	case strings.HasPrefix(msg, "Warning"):
+		return slog.LevelWarn
+	case strings.HasPrefix(msg, "Error"), strings.HasPrefix(msg, "Failed"):
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// logf 输出日志；文本格式下context中带有请求ID时在日志前加上[请求ID]，
+// JSON格式下请求ID、操作和部署名称作为独立字段输出
+func logf(ctx context.Context, format string, args ...interface{}) {
+	id := requestIDFrom(ctx)
+	if slogger != nil {
+		msg := fmt.Sprintf(format, args...)
+		var attrs []any
+		if id != "" {
+			attrs = append(attrs, "request_id", id)
+		}
+		if fields, ok := ctx.Value(logFieldsKey{}).(logFields); ok {
+			attrs = append(attrs, "action", fields.Action, "name", fields.Name)
+		}
+		slogger.Log(ctx, levelOf(msg), msg, attrs...)
+		return
+	}
+	if id != "" {
+		logger.Printf("[%s] "+format, append([]interface{}{id}, args...)...)
+		return
+	}
+	logger.Printf(format, args...)
+}
+
+// debugf 输出以Debug开头的调试日志，只在-level debug时输出
+func debugf(ctx context.Context, format string, args ...interface{}) {
+	if !strings.EqualFold(*logLevel, "debug") {
+		return
+	}
+	logf(ctx, "Debug: "+format, args...)
+}
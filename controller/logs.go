@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// logStreams 在关闭时取消，结束follow中的日志请求，否则Shutdown会一直等待其返回
+var logStreams, closeLogStreams = context.WithCancel(context.Background())
+
+// flushWriter 每次写入后立即刷新，follow时日志可以实时到达客户端
+type flushWriter struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if fw.f != nil {
+		fw.f.Flush()
+	}
+	return n, err
+}
+
+// logsHandler 返回副本工作容器的日志，多副本部署按副本名称（<name>-0）查询。
+// tail为返回的行数（默认全部），follow=true时持续输出直到客户端断开
+func logsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/logs/")
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "Invalid deployment name", http.StatusBadRequest)
+		return
+	}
+	tail := r.URL.Query().Get("tail")
+	if tail == "" {
+		tail = "all"
+	} else if n, err := strconv.Atoi(tail); err != nil || n < 0 {
+		http.Error(w, fmt.Sprintf("Invalid tail %q", tail), http.StatusBadRequest)
+		return
+	}
+	follow := r.URL.Query().Get("follow") == "true"
+
+	workerID, _, err := findDeploymentContainers(r.Context(), name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if workerID == "" {
+		http.Error(w, fmt.Sprintf("Worker container of %s not exist", name), http.StatusNotFound)
+		return
+	}
+	info, err := dockerClient.ContainerInspect(r.Context(), workerID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to inspect worker container of %s: %v", name, err), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stop := context.AfterFunc(logStreams, cancel)
+	defer stop()
+
+	logs, err := dockerClient.ContainerLogs(ctx, workerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+		Tail:       tail,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get logs of %s: %v", name, err), http.StatusInternalServerError)
+		return
+	}
+	defer logs.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	out := flushWriter{w: w, f: flusher}
+
+	// 工作容器默认使用TTY，日志不带stdout/stderr的多路复用头
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(out, logs)
+	} else {
+		_, err = stdcopy.StdCopy(out, out, logs)
+	}
+	if err != nil && ctx.Err() == nil {
+		logger.Printf("Error streaming logs of %s: %v", name, err)
+	}
+}
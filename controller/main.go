@@ -1,18 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"io"
 	"log"
-	"math/rand"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
@@ -20,46 +22,37 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/natefinch/lumberjack"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/vishvananda/netlink"
+	"trex-controller/pkg/api"
 )
 
-type Metadata struct {
-	Name  string `json:"name" yaml:"name"`
-	Image string `json:"image" yaml:"image"`
-}
-type Port struct {
-	IFName  string `json:"ifName" yaml:"ifName"`
-	VFIndex int    `json:"vfIndex" yaml:"vfIndex"`
-	IP      string `json:"ip" yaml:"ip"`
-	Gateway string `json:"gateway" yaml:"gateway"`
-	VlanId  int    `json:"vlanId" yaml:"vlanId"`
-}
-
-type Spec struct {
-	BrName          string `json:"brName" yaml:"brName"`
-	MgmtIP          string `json:"mgmtIP" yaml:"mgmtIP"`
-	MgmtGateway     string `json:"mgmtGateway" yaml:"mgmtGateway"`
-	NetworkType     string `json:"networkType" yaml:"networkType"`
-	ParentInterface string `json:"parentInterface" yaml:"parentInterface"`
-	Port            []Port `json:"port" yaml:"port"`
-}
-
-// TRExConfig 定义TREx容器的配置
-type TRExConfig struct {
-	Kind     string   `json:"kind" yaml:"kind"` // 资源类型 TrexConfig
-	Metadata Metadata `json:"metadata" yaml:"metadata"`
-	Spec     Spec     `json:"spec" yaml:"spec"`
-}
+// 配置和返回结构定义在pkg/api中，与trexctl及pkg/client共用
+type (
+	Metadata          = api.Metadata
+	Port              = api.Port
+	Peer              = api.Peer
+	Route             = api.Route
+	Mount             = api.Mount
+	Spec              = api.Spec
+	ReplicaResult     = api.ReplicaResult
+	ActionResult      = api.ActionResult
+	TRExConfig        = api.TRExConfig
+	DeploymentSummary = api.DeploymentSummary
+)
 
 var (
 	dockerClient   *client.Client
 	mu             sync.Mutex // 用于同步网络操作
 	server         *http.Server
+	listenAddress  string // 由-listen和-port解析出的监听地址
 	logger         *log.Logger
 	logFile        *os.File
 	containerLocks ContainerLockManager
+	stateStore     *StateStore
 )
 
 type ContainerLockManager struct {
@@ -73,15 +66,57 @@ func (clm *ContainerLockManager) GetLock(containerName string) *sync.Mutex {
 
 // 命令行参数
 var (
-	logPath    = flag.String("log", "/var/log/trex-controller.log", "Path to log file")
-	logLevel   = flag.String("level", "info", "Log level (debug, info, warn, error)")
-	serverPort = flag.String("port", "21111", "Port to listen on")
+	logPath              = flag.String("log", "/var/log/trex-controller.log", "Path to log file")
+	logLevel             = flag.String("level", "info", "Log level (debug, info, warn, error)")
+	logFormat            = flag.String("log-format", logFormatText, "Log format (text, json)")
+	listenAddr           = flag.String("listen", "0.0.0.0:21111", "Address (host:port) the API listens on, e.g. 127.0.0.1:21111 to serve loopback only")
+	serverPort           = flag.String("port", "21111", "Port to listen on, overrides the port of -listen when set")
+	tlsCert              = flag.String("tls-cert", "", "Path to TLS certificate file")
+	tlsKey               = flag.String("tls-key", "", "Path to TLS private key file")
+	authToken            = flag.String("auth-token", "", "Bearer token required on mutating API requests")
+	deleteEmptyBridges   = flag.Bool("delete-empty-bridges", false, "Delete a bridge when no deployment uses it anymore")
+	stateFile            = flag.String("state-file", "/var/lib/trex-controller/state.json", "Path to the deployment state file")
+	pauseImage           = flag.String("pause-image", defaultPauseImage, "Image used for the pause container")
+	defaultBridge        = flag.String("bridge", brName, "Default bridge name when spec.brName is not set")
+	dockerHost           = flag.String("docker-host", "", "Docker daemon address, e.g. tcp://host:2376 or unix:///path/docker.sock (defaults to DOCKER_HOST)")
+	stopOnShutdown       = flag.Bool("stop-on-shutdown", false, "Stop managed TREx containers when the controller shuts down")
+	stopTimeoutFlag      = flag.Int("stop-timeout", -1, "Seconds to wait for containers to stop before killing them (-1 uses the Docker default)")
+	configDir            = flag.String("config-dir", "/tmp/trex", "Directory for generated trex_cfg.yaml files (must be the same path on the Docker host)")
+	dataDir              = flag.String("data-dir", "", "Root directory for generated files (<dir>/config) and the state file (<dir>/state.json) unless -config-dir or -state-file is set")
+	maxBodySize          = flag.Int64("max-body", 1<<20, "Maximum request body size in bytes")
+	maxConcurrentDeploys = flag.Int("max-concurrent-deploys", 0, "Maximum number of deployments created at the same time (0 means unlimited)")
+	dockerTimeout        = flag.Duration("docker-timeout", 10*time.Minute, "Deadline of each apply/update/delete including Docker calls and image pulls, exceeded requests fail with 504 (0 disables it)")
+	deployQueueTimeout   = flag.Duration("deploy-queue-timeout", 0, "How long a deploy waits for a free slot before failing with 429 (0 fails immediately)")
+	reconcileInterval    = flag.Duration("reconcile-interval", 0, "Interval of the background loop repairing drifted deployments (0 disables it)")
+	configFile           = flag.String("config", "", "Path to a YAML file setting any of the command line options")
+	allowDriverBind      = flag.Bool("allow-driver-bind", false, "Allow deployments to rebind VFs to another driver via spec.driverBind (privileged)")
+	netnsRetries         = flag.Int("netns-retries", 3, "Retries of netlink operations on a container network namespace that fail while the container is starting")
+	netnsRetryDelay      = flag.Duration("netns-retry-delay", 200*time.Millisecond, "Delay between retries of network namespace operations")
+	summaryDir           = flag.String("summary-dir", "", "Directory where a JSON summary <name>.json of each deployment is written after a successful apply/update and removed on delete")
+	requireHugepages     = flag.Bool("require-hugepages", false, "Fail deployments using hugepages when the host has no free hugepages instead of only logging a warning")
 )
 
-func init() {
+// setup 解析命令行参数并初始化日志、Docker客户端和状态，在main开始时调用，测试中不执行
+func setup() {
 	// 解析命令行参数
 	flag.Parse()
 
+	// 加载配置文件，命令行参数优先
+	if *configFile != "" {
+		if err := loadConfigFile(*configFile); err != nil {
+			log.Fatalf("Failed to load config file: %v", err)
+		}
+	}
+
+	if err := applyDataDir(); err != nil {
+		log.Fatalf("Invalid data directory: %v", err)
+	}
+
+	var err error
+	if listenAddress, err = resolveListenAddr(); err != nil {
+		log.Fatalf("Invalid listen address: %v", err)
+	}
+
 	// 创建日志目录（如果需要）
 	logDir := filepath.Dir(*logPath)
 	if _, err := os.Stat(logDir); os.IsNotExist(err) {
@@ -100,47 +135,126 @@ func init() {
 	multiWriter := io.MultiWriter(os.Stdout, logRotator)
 
 	// 创建自定义日志记录器
-	logger = log.New(multiWriter, "", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	logger, err = newLogger(multiWriter, *logFormat, *logLevel)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
 
 	// 初始化 Docker 客户端
-	var err error
-	dockerClient, err = client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	// 命令行参数优先于DOCKER_HOST环境变量
+	dockerOpts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if *dockerHost != "" {
+		dockerOpts = append(dockerOpts, client.WithHost(*dockerHost))
+	}
+	dockerClient, err = client.NewClientWithOpts(dockerOpts...)
 	if err != nil {
 		logger.Fatalf("Error creating Docker client: %v", err)
 	}
 
-	logger.Printf("Logging initialized. Level: %s, Path: %s", *logLevel, *logPath)
+	// 加载部署状态
+	stateStore, err = NewStateStore(*stateFile)
+	if err != nil {
+		logger.Fatalf("Error loading state: %v", err)
+	}
+	managedDeployments.Set(float64(len(stateStore.List())))
+
+	// 启动时校验pause镜像，避免部署时才报出Docker错误
+	if *pauseImage, err = normalizeImageRef(*pauseImage); err != nil {
+		logger.Fatalf("Invalid -pause-image: %v", err)
+	}
+
+	initDeployLimiter()
+
+	logger.Printf("Logging initialized. Level: %s, Format: %s, Path: %s", *logLevel, *logFormat, *logPath)
 }
 
 func main() {
-	logger.Println("Starting TREx Controller...")
+	setup()
+	logger.Printf("Starting TREx Controller %s (commit: %s, built: %s)...", version, gitCommit, buildDate)
 
 	// 设置HTTP路由
 	mux := http.NewServeMux()
 	mux.HandleFunc("/apply", applyHandler)
 	mux.HandleFunc("/update", updateHandler)
 	mux.HandleFunc("/delete", deleteHandler)
+	mux.HandleFunc("/list", listHandler)
+	mux.HandleFunc("/config/", configHandler)
+	mux.HandleFunc("/gc", gcHandler)
+	mux.HandleFunc("/events", eventsHandler)
+	mux.HandleFunc("/stats/", statsHandler)
+	mux.HandleFunc("/status/", statusHandler)
+	mux.HandleFunc("/start/", startHandler)
+	mux.HandleFunc("/stop/", stopHandler)
+	mux.HandleFunc("/restart/", restartHandler)
+	mux.HandleFunc("/logs/", logsHandler)
 	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/livez", livezHandler)
+	mux.HandleFunc("/ready", readyHandler)
+	mux.HandleFunc("/version", versionHandler)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	var handler http.Handler = mux
+	if *authToken != "" {
+		handler = authMiddleware(*authToken, handler)
+	}
+	handler = recoverMiddleware(handler)
+	handler = requestIDMiddleware(handler)
 
 	// 创建HTTP服务器
 	server = &http.Server{
-		Addr:    fmt.Sprintf(":%s", *serverPort),
-		Handler: mux,
+		Addr:    listenAddress,
+		Handler: handler,
+	}
+	// 关闭时结束SSE连接，否则Shutdown会一直等待/events请求返回
+	server.RegisterOnShutdown(events.CloseAll)
+	server.RegisterOnShutdown(closeLogStreams)
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		logger.Fatalf("Both -tls-cert and -tls-key must be set to enable TLS")
 	}
 
 	// 在goroutine中启动服务器
 	go func() {
-		logger.Println(fmt.Sprintf("Starting HTTP server on :%s", *serverPort))
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if *tlsCert != "" && *tlsKey != "" {
+			logger.Printf("Starting HTTPS server on %s", listenAddress)
+			err = server.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			if *authToken == "" {
+				logger.Printf("Warning: TLS is not configured, the API is unauthenticated and unencrypted")
+			} else {
+				logger.Printf("Warning: TLS is not configured, the API token is sent unencrypted")
+			}
+			logger.Printf("Starting HTTP server on %s", listenAddress)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatalf("HTTP server failed: %v", err)
 		}
 	}()
 
+	// 启动后台修复循环
+	reconcileCtx, stopReconcile := context.WithCancel(context.Background())
+	defer stopReconcile()
+	if *reconcileInterval > 0 {
+		logger.Printf("Reconciling deployments every %s", *reconcileInterval)
+		go func() {
+			// 启动时先修复一次，完成前/ready返回503
+			reconcileAll(reconcileCtx)
+			startupReconciled.Store(true)
+			logger.Printf("Startup reconcile completed")
+			runReconcileLoop(reconcileCtx, *reconcileInterval)
+		}()
+	} else {
+		startupReconciled.Store(true)
+	}
+
 	// 设置优雅关闭
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	logger.Println("Shutting down server...")
+	stopReconcile()
 
 	// 设置关闭超时
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -150,14 +264,52 @@ func main() {
 		logger.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	if *stopOnShutdown {
+		stopManagedDeployments(ctx)
+	}
+
 	logger.Println("Server exiting")
 }
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
+// livezHandler 仅反映进程存活
+func livezHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
 
+// healthHandler 检查Docker守护进程和netlink是否可用
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	checks := make(map[string]string)
+	healthy := true
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if _, err := dockerClient.Ping(ctx); err != nil {
+		checks["docker"] = err.Error()
+		healthy = false
+	} else {
+		checks["docker"] = "ok"
+	}
+
+	if _, err := netlink.LinkByName("lo"); err != nil {
+		checks["netlink"] = err.Error()
+		healthy = false
+	} else {
+		checks["netlink"] = "ok"
+	}
+
+	status := http.StatusOK
+	body := map[string]interface{}{"status": "ok", "checks": checks}
+	if !healthy {
+		status = http.StatusServiceUnavailable
+		body["status"] = "unhealthy"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
 func applyHandler(w http.ResponseWriter, r *http.Request) {
 	handleRequest(w, r, "apply")
 }
@@ -170,217 +322,686 @@ func deleteHandler(w http.ResponseWriter, r *http.Request) {
 	handleRequest(w, r, "delete")
 }
 
+// configHandler 返回为部署生成的trex_cfg.yaml，多副本部署按副本名称（<name>-0）查询；
+// applied=true时返回部署保存的配置，见appliedConfigHandler
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	applied := r.URL.Query().Get("applied") == "true"
+	if r.Method != "GET" && !(applied && r.Method == "POST") {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/config/")
+	if name == "" || strings.Contains(name, "/") || name == "." || name == ".." {
+		http.Error(w, "Invalid deployment name", http.StatusBadRequest)
+		return
+	}
+	if applied {
+		appliedConfigHandler(w, r, name)
+		return
+	}
+
+	content, err := os.ReadFile(trexConfigPath(name))
+	if os.IsNotExist(err) {
+		http.Error(w, fmt.Sprintf("Config of %s not exist", name), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Printf("Error reading config of %s: %v", name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
+// listHandler 返回状态记录中的全部部署，按名称排序
+func listHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	deployments := []DeploymentSummary{}
+	for name, record := range stateStore.List() {
+		deployments = append(deployments, DeploymentSummary{
+			Name:      name,
+			Image:     record.Config.Metadata.Image,
+			BrName:    record.Config.Spec.BrName,
+			UpdatedAt: record.UpdatedAt,
+		})
+	}
+
+	// 补充未记录在状态中、但带有controller标签的部署
+	containers, err := dockerClient.ContainerList(r.Context(), types.ContainerListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", labelManaged+"=true"),
+			filters.Arg("label", labelRole+"="+roleWorker),
+		),
+	})
+	if err != nil {
+		logger.Printf("Warning: failed to list managed containers: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, c := range containers {
+		name := c.Labels[labelDeployment]
+		if name == "" {
+			name = c.Labels[labelName]
+		}
+		if _, ok := stateStore.Get(name); ok || name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		deployments = append(deployments, DeploymentSummary{
+			Name:  name,
+			Image: c.Image,
+		})
+	}
+
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].Name < deployments[j].Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deployments)
+}
+
 func handleRequest(w http.ResponseWriter, r *http.Request, action string) {
+	requestsTotal.WithLabelValues(action).Inc()
+
 	if r.Method != "POST" {
+		recordFailure(action, http.StatusMethodNotAllowed)
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	// 只有apply支持预演，update和delete忽略dryRun会直接修改部署
+	if isDryRun(r) && action != "apply" {
+		recordFailure(action, http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("dryRun is only supported by apply, not %s", action), http.StatusBadRequest)
+		return
+	}
 	// 关闭请求体避免资源泄露
 	defer r.Body.Close()
 
-	var config TRExConfig
+	// 限制请求体大小
+	r.Body = http.MaxBytesReader(w, r.Body, *maxBodySize)
+
+	configs, err := decodeConfigs(r)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		logger.Printf("Request body exceeds %d bytes", maxBytesErr.Limit)
+		recordFailure(action, http.StatusRequestEntityTooLarge)
+		http.Error(w, fmt.Sprintf("Request body too large, limit is %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		logger.Printf("Error decoding request: %v", err)
+		recordFailure(action, http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// 单个配置保持原有的响应格式
+	if len(configs) == 1 {
+		result, err := runAction(r, action, configs[0])
+		if err != nil {
+			status := httpStatusFor(err)
+			recordFailure(action, status)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		// 创建部署时返回结构化的JSON结果
+		if len(result.Replicas) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(result.Message))
+		return
+	}
+
+	// 多个配置依次执行，汇总每个部署的结果
+	failed := 0
+	var lines []string
+	for _, config := range configs {
+		result, err := runAction(r, action, config)
+		if err != nil {
+			failed++
+			lines = append(lines, fmt.Sprintf("%s: FAILED: %v", config.Metadata.Name, err))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", config.Metadata.Name, result.Message))
+	}
+	summary := fmt.Sprintf("%d succeeded, %d failed\n%s", len(configs)-failed, failed, strings.Join(lines, "\n"))
+
+	if failed > 0 {
+		recordFailure(action, http.StatusInternalServerError)
+		http.Error(w, summary, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(summary))
+}
+
+// decodeConfigs 根据内容类型解码请求体，YAML支持以"---"分隔的多个文档，JSON支持顶层数组。
+// 使用严格模式解码，未知字段（如拼写错误的mgmtIp）会返回错误而不是被静默忽略
+func decodeConfigs(r *http.Request) ([]TRExConfig, error) {
 	contentType := r.Header.Get("Content-Type")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
 
-	// 根据内容类型选择解码器
 	if strings.Contains(contentType, "application/json") {
-		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-			logger.Printf("Error decoding request: %v", err)
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+			var configs []TRExConfig
+			if err := decoder.Decode(&configs); err != nil {
+				return nil, err
+			}
+			if len(configs) == 0 {
+				return nil, fmt.Errorf("empty config list")
+			}
+			return configs, nil
 		}
+		var config TRExConfig
+		if err := decoder.Decode(&config); err != nil {
+			return nil, err
+		}
+		return []TRExConfig{config}, nil
 	}
 
 	if strings.Contains(contentType, "application/yaml") {
-		if err := yaml.NewDecoder(r.Body).Decode(&config); err != nil {
-			logger.Printf("Error decoding request: %v", err)
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
+		var configs []TRExConfig
+		decoder := yaml.NewDecoder(bytes.NewReader(body))
+		decoder.SetStrict(true)
+		for {
+			var config TRExConfig
+			err := decoder.Decode(&config)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			configs = append(configs, config)
+		}
+		if len(configs) == 0 {
+			return nil, io.EOF
 		}
+		return configs, nil
 	}
 
-	logger.Printf("Received %s request for container: %s", action, config.Metadata.Name)
+	return []TRExConfig{{}}, nil
+}
+
+// runAction 对单个配置执行指定操作
+func runAction(r *http.Request, action string, config TRExConfig) (*ActionResult, error) {
+	// 客户端断开或超过-docker-timeout时取消操作，失败后的清理使用detachedContext
+	reqCtx, cancel := requestContext(r)
+	defer cancel()
+	ctx := withLogFields(reqCtx, action, config.Metadata.Name)
+	logf(ctx, "Received %s request for container: %s", action, config.Metadata.Name)
+	publishEvent(ctx, eventStarted, action, config.Metadata.Name, "")
 
-	var result string
+	var result *ActionResult
+	var message string
 	var err error
 
 	switch action {
 	case "apply":
-		result, err = createTRExContainer(config)
+		if isDryRun(r) {
+			message, err = dryRunTRExContainer(ctx, config, isRecreate(r), isForce(r))
+		} else {
+			result, err = applyTRExContainer(ctx, config, isRecreate(r), isForce(r))
+		}
 	case "update":
-		result, err = updateTRExContainer(config)
+		result, err = updateTRExContainer(ctx, config, isRecreate(r))
 	case "delete":
-		result, err = deleteTRExContainer(config)
+		message, err = deleteTRExContainer(ctx, config)
 	default:
 		err = fmt.Errorf("unknown action: %s", action)
 	}
 
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w after %s: %v", errDockerTimeout, *dockerTimeout, err)
+	}
 	if err != nil {
-		logger.Printf("%s failed for %s: %v", action, config.Metadata.Name, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		logf(ctx, "%s failed for %s: %v", action, config.Metadata.Name, err)
+		publishEvent(ctx, eventFailed, action, config.Metadata.Name, err.Error())
+		return nil, err
+	}
+	if result == nil {
+		result = &ActionResult{Message: message}
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(result))
-	logger.Printf("%s completed for %s: %s", action, config.Metadata.Name, result)
+	// 对外的部署摘要：apply/update成功后按保存的配置重写，delete后删除
+	switch {
+	case action == "delete":
+		removeSummary(ctx, config.Metadata.Name)
+	case action == "update" || (action == "apply" && !isDryRun(r)):
+		writeSummary(ctx, config.Metadata.Name)
+	}
+
+	logf(ctx, "%s completed for %s: %s", action, config.Metadata.Name, result.Message)
+	publishEvent(ctx, eventSucceeded, action, config.Metadata.Name, result.Message)
+	return result, nil
 }
 
-// 生成trex开头的veth-pair网卡名称对
-func generateTrexVethPair() (string, string) {
-	// 初始化随机数生成器
-	rand.Seed(time.Now().UnixNano())
+// isForce 判断apply请求是否要求覆盖同名部署
+func isForce(r *http.Request) bool {
+	return r.URL.Query().Get("force") == "true" || strings.EqualFold(r.Header.Get("X-Force"), "true")
+}
 
-	// 定义可用字符集：小写字母和数字
-	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
-	const suffixLength = 11
+// applyTRExContainer 部署不存在时创建；已存在时与上次apply保存的配置比较，未变化时直接返回，否则执行更新。
+// recreate为true时更新总是完整重建部署；force为true时无论配置是否变化，都在名称锁内删除同名部署（包括未跟踪的残留容器）后重建，
+// 已跟踪的部署重建失败时回滚到保存的配置
+func applyTRExContainer(ctx context.Context, config TRExConfig, recreate, force bool) (*ActionResult, error) {
+	name := config.Metadata.Name
+	if force {
+		logf(ctx, "Force applying %s, recreating any existing deployment", name)
+		return updateTRExContainer(ctx, config, true)
+	}
 
-	// 生成11位随机后缀
-	b := make([]byte, suffixLength)
-	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+	previous, ok := stateStore.Get(name)
+	if !ok {
+		return createTRExContainer(ctx, config)
 	}
-	randomSuffix := string(b)
 
-	// 生成主机端和容器端的veth名称
-	vethHost := fmt.Sprintf("trex%s-h", randomSuffix) // h表示host端
-	vethCont := fmt.Sprintf("trex%s-c", randomSuffix) // c表示container端
+	// 保存的配置已经过LoadConfig，新配置同样补全默认值后再比较
+	desired := config
+	if err := LoadConfig(&desired); err != nil {
+		return nil, fmt.Errorf("failed to load config: %v", err)
+	}
+	equal, err := configsEqual(previous.Config, desired)
+	if err != nil {
+		return nil, err
+	}
+	if equal {
+		return &ActionResult{Message: fmt.Sprintf("Deployment %s unchanged", name)}, nil
+	}
 
-	return vethHost, vethCont
+	logf(ctx, "Config of %s changed, updating deployment", name)
+	return updateTRExContainer(ctx, config, recreate)
 }
 
-func createTRExContainer(config TRExConfig) (string, error) {
-	name := config.Metadata.Name
-	workName := fmt.Sprintf("/%s", name)
+func createTRExContainer(ctx context.Context, config TRExConfig) (*ActionResult, error) {
+	release, err := acquireDeploySlot()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	ctx := context.Background()
-	lock := containerLocks.GetLock(name)
+	lock := containerLocks.GetLock(config.Metadata.Name)
 	lock.Lock()
 	defer lock.Unlock()
 
+	return createTRExContainerLocked(ctx, config)
+}
+
+// createTRExContainerLocked 创建TREx部署，调用方需持有该名称的锁
+func createTRExContainerLocked(ctx context.Context, config TRExConfig) (*ActionResult, error) {
+	name := config.Metadata.Name
+
 	err := LoadConfig(&config)
 	if err != nil {
-		return "", fmt.Errorf("failed to load config: %v", err)
+		return nil, fmt.Errorf("failed to load config: %v", err)
 	}
 
-	logger.Printf("Creating container: %s", name)
-	containers, err := dockerClient.ContainerList(ctx, types.ContainerListOptions{
-		All: true,
-	})
+	logf(ctx, "Creating container: %s", name)
+	replicas, err := replicaConfigs(config)
 	if err != nil {
-		return "", fmt.Errorf("failed to list containers: %v", err)
+		return nil, fmt.Errorf("failed to load config: %v", err)
+	}
+	// 创建任何副本之前检查全部VF
+	if config.Spec.NetworkType == "SRIOV" {
+		if err := validateVFs(config); err != nil {
+			return nil, err
+		}
+	}
+	for _, replica := range replicas {
+		workerID, pauseID, err := findDeploymentContainers(ctx, replica.Metadata.Name)
+		if err != nil {
+			return nil, err
+		}
+		if workerID != "" || pauseID != "" {
+			return nil, fmt.Errorf("container with name %s already exists", replica.Metadata.Name)
+		}
 	}
 
-	for _, c := range containers {
-		for _, cname := range c.Names {
-			if strings.Compare(cname, workName) == 0 {
-				return "", fmt.Errorf("container with name %s already exists", name)
+	start := time.Now()
+	var results []ReplicaResult
+	for i, replica := range replicas {
+		result, err := CreateTRExContainer(ctx, replica)
+		if err != nil {
+			// 回滚已创建的副本
+			cleanupCtx, cancel := detachedContext(ctx)
+			for _, created := range replicas[:i] {
+				teardownDeployment(cleanupCtx, created, container.StopOptions{Timeout: stopTimeout(created)})
+				releaseDeploymentBridges(created)
 			}
+			cancel()
+			return nil, fmt.Errorf("failed to create TREx container: %v", err)
 		}
+		results = append(results, *result)
 	}
-	workloadId, err := CreateTRExContainer(ctx, config)
-	if err != nil {
-		return "", fmt.Errorf("failed to create TREx container: %v", err)
+	deployDuration.Observe(time.Since(start).Seconds())
+	managedDeployments.Inc()
+
+	workloadIds := make([]string, 0, len(results))
+	var lines []string
+	for _, result := range results {
+		workloadIds = append(workloadIds, result.ContainerID)
+		lines = append(lines, fmt.Sprintf("Container %s created and started with ID: %s", result.Name, result.ContainerID))
+	}
+	if err := stateStore.Put(name, DeploymentRecord{Config: config, WorkerContainerID: strings.Join(workloadIds, ",")}); err != nil {
+		logf(ctx, "Warning: failed to save state for %s: %v", name, err)
 	}
 
-	return fmt.Sprintf("Container %s created and started with ID: %s", name, workloadId), nil
+	return &ActionResult{Message: strings.Join(lines, "\n"), Replicas: results}, nil
 }
 
-func updateTRExContainer(config TRExConfig) (string, error) {
+// updateTRExContainer 将部署更新为新配置：只修改了网络字段时在线调整，只修改了工作容器字段时复用pause容器只重建工作容器，
+// 否则（或recreate为true时）删除后重建，重建失败时按保存的配置回滚
+func updateTRExContainer(ctx context.Context, config TRExConfig, recreate bool) (*ActionResult, error) {
 	name := config.Metadata.Name
-	logger.Printf("Updating container: %s", name)
-	// 简化实现：删除旧容器，创建新容器
-	if _, err := deleteTRExContainer(config); err != nil {
-		return "", err
-	}
 
+	// 先校验新配置，避免无效配置导致旧部署被删除
 	err := LoadConfig(&config)
 	if err != nil {
-		return "", fmt.Errorf("failed to load config: %v", err)
+		return nil, fmt.Errorf("failed to load config: %v", err)
 	}
 
-	return createTRExContainer(config)
-}
-
-func deleteTRExContainer(config TRExConfig) (string, error) {
-	name := config.Metadata.Name
+	release, err := acquireDeploySlot()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	lock := containerLocks.GetLock(name)
 	lock.Lock()
 	defer lock.Unlock()
 
-	pauseName := fmt.Sprintf("/%s-pause", name)
-	workName := fmt.Sprintf("/%s", name)
-	ctx := context.Background()
+	logf(ctx, "Updating container: %s", name)
+	previous, hasPrevious := stateStore.Get(name)
+	if !hasPrevious {
+		logf(ctx, "Warning: no saved state for %s, update cannot be rolled back", name)
+	}
 
-	logger.Printf("Deleting container: %s", name)
-	// 查找容器
-	containers, err := dockerClient.ContainerList(ctx, types.ContainerListOptions{
-		All: true,
-	})
+	// 只修改了网络字段时在线调整，只修改了工作容器字段时复用pause容器，失败时回退到重建
+	if hasPrevious && !recreate {
+		equal, err := configsEqual(previous.Config, config)
+		if err != nil {
+			return nil, err
+		}
+		hot, err := networkOnlyChange(previous.Config, config)
+		if err != nil {
+			return nil, err
+		}
+		workerOnly, err := workerOnlyChange(previous.Config, config)
+		if err != nil {
+			return nil, err
+		}
+		if !equal && hot {
+			result, err := reconfigureTRExContainer(ctx, previous, config)
+			if err == nil {
+				return result, nil
+			}
+			logf(ctx, "Warning: in-place network update of %s failed, recreating the deployment: %v", name, err)
+		} else if !equal && workerOnly {
+			result, err := redeployTRExWorkers(ctx, previous, config)
+			if err == nil {
+				return result, nil
+			}
+			logf(ctx, "Warning: worker-only update of %s failed, recreating the deployment: %v", name, err)
+		}
+	}
+
+	if _, err := deleteTRExContainerLocked(ctx, config); err != nil {
+		return nil, err
+	}
+
+	result, err := createTRExContainerLocked(ctx, config)
+	if err == nil {
+		return result, nil
+	}
+	if !hasPrevious {
+		return nil, err
+	}
+
+	// 新部署失败，使用保存的配置恢复旧部署
+	logf(ctx, "Update of %s failed, rolling back to previous config: %v", name, err)
+	rollbackCtx, cancel := detachedContext(ctx)
+	defer cancel()
+	if _, rbErr := createTRExContainerLocked(rollbackCtx, previous.Config); rbErr != nil {
+		return nil, fmt.Errorf("update failed: %v; rollback also failed: %v", err, rbErr)
+	}
+	return nil, fmt.Errorf("update failed and was rolled back to the previous deployment: %v", err)
+}
+
+// reconfigureTRExContainer 在线调整部署的网络配置并保存新配置
+func reconfigureTRExContainer(ctx context.Context, previous DeploymentRecord, config TRExConfig) (*ActionResult, error) {
+	result, err := reconfigureTRExNetwork(ctx, previous.Config, config)
 	if err != nil {
-		return "", nil
+		return nil, err
+	}
+	previous.Config = config
+	if err := stateStore.Put(config.Metadata.Name, previous); err != nil {
+		logf(ctx, "Warning: failed to save state for %s: %v", config.Metadata.Name, err)
 	}
+	return result, nil
+}
 
-	var containerID string
-	var pauseID string
+func deleteTRExContainer(ctx context.Context, config TRExConfig) (string, error) {
+	lock := containerLocks.GetLock(config.Metadata.Name)
+	lock.Lock()
+	defer lock.Unlock()
 
-	for _, c := range containers {
-		for _, cname := range c.Names {
-			if strings.Compare(cname, workName) == 0 {
-				containerID = c.ID
-			}
-			if strings.Compare(cname, pauseName) == 0 {
-				pauseID = c.ID
-			}
+	return deleteTRExContainerLocked(ctx, config)
+}
+
+// deleteTRExContainerLocked 删除TREx部署（包括全部副本），调用方需持有该名称的锁
+func deleteTRExContainerLocked(ctx context.Context, config TRExConfig) (string, error) {
+	name := config.Metadata.Name
+
+	logf(ctx, "Deleting container: %s", name)
+
+	// 优先使用保存的配置确定副本和停止超时时间
+	record, tracked := stateStore.Get(name)
+	if tracked && config.Spec.StopTimeout == nil {
+		config.Spec.StopTimeout = record.Config.Spec.StopTimeout
+	}
+	if tracked {
+		config.Spec.Replicas = record.Config.Spec.Replicas
+		config.Spec.BrName = record.Config.Spec.BrName
+		config.Spec.BridgeType = record.Config.Spec.BridgeType
+	}
+	stopOptions := container.StopOptions{Timeout: stopTimeout(config)}
+
+	// 已跟踪的部署使用保存的副本配置，以便恢复各副本VF的驱动
+	var replicas []TRExConfig
+	if tracked {
+		replicas, _ = replicaConfigs(record.Config)
+	}
+	if len(replicas) == 0 {
+		for _, replicaName := range replicaNames(config) {
+			replica := config
+			replica.Metadata.Name = replicaName
+			replica.Spec.Port = dataPorts(config.Spec)
+			replicas = append(replicas, replica)
 		}
 	}
 
-	if containerID == "" {
-		return fmt.Sprintf("Container %s not exist", name), nil
+	// 尽力删除全部副本，最后汇总清理结果和失败
+	deleted := 0
+	var lines []string
+	var failures []string
+	for _, replica := range replicas {
+		summary, err := teardownDeployment(ctx, replica, stopOptions)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", replica.Metadata.Name, err))
+		}
+		if summary.Found {
+			deleted++
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", replica.Metadata.Name, summary))
 	}
-	if pauseID == "" {
-		return fmt.Sprintf("Container %s not exist", pauseName), nil
+	if len(failures) > 0 {
+		return "", fmt.Errorf("%s", strings.Join(failures, "\n"))
 	}
 
-	if containerID != "" {
-		logger.Printf("Stopping container: %s (ID: %s)", name, containerID)
-		// 停止容器
-		if err := dockerClient.ContainerStop(ctx, containerID, container.StopOptions{}); err != nil {
-			logger.Printf("Warning: failed to stop container %s: %v", containerID, err)
+	// 全部副本删除成功后，在删除状态记录之前释放网桥引用：失败的删除保留记录且不释放，
+	// 重试时不会重复释放；未跟踪的残留容器没有持有网桥引用
+	if tracked {
+		for _, replica := range replicas {
+			releaseDeploymentBridges(replica)
 		}
+	}
 
-		logger.Printf("Removing container: %s (ID: %s)", name, containerID)
-		// 删除容器
-		if err := dockerClient.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{
+	// 只有状态记录中的部署计入gauge，删除未跟踪的残留容器不减少
+	if tracked {
+		managedDeployments.Dec()
+	}
+
+	if err := stateStore.Delete(name); err != nil {
+		logf(ctx, "Warning: failed to delete state for %s: %v", name, err)
+	}
+
+	if deleted == 0 {
+		return fmt.Sprintf("Container %s not exist\n%s", name, strings.Join(lines, "\n")), nil
+	}
+	return fmt.Sprintf("Container %s deleted\n%s", name, strings.Join(lines, "\n")), nil
+}
+
+// TeardownSummary 记录删除部署时实际清理的资源和原本就不存在的资源
+type TeardownSummary struct {
+	Cleaned []string
+	Absent  []string
+	// Found 是否找到了部署的工作容器或pause容器
+	Found bool
+}
+
+func (t TeardownSummary) String() string {
+	var parts []string
+	if len(t.Cleaned) > 0 {
+		parts = append(parts, "removed "+strings.Join(t.Cleaned, ", "))
+	}
+	if len(t.Absent) > 0 {
+		parts = append(parts, "already absent: "+strings.Join(t.Absent, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// teardownDeployment 尽力停止并删除单个部署（或副本）的工作容器、pause容器、veth、端口转发和配置文件，
+// 缺失的资源直接跳过，因此重试失败的删除是安全的；只有实际的删除操作失败时才返回错误。
+// 不释放网桥引用，由调用方在确认删除完成后调用releaseDeploymentBridges，保证每个副本只释放一次
+func teardownDeployment(ctx context.Context, config TRExConfig, stopOptions container.StopOptions) (TeardownSummary, error) {
+	var summary TeardownSummary
+	var failures []string
+	name := config.Metadata.Name
+
+	// 查找容器
+	containerID, pauseID, err := findDeploymentContainers(ctx, name)
+	if err != nil {
+		return summary, err
+	}
+
+	// 先卸载命名网络命名空间，否则删除容器后命名空间及其中的VF仍然保留
+	if config.Spec.ExposeNetns {
+		unexposeNetns(name)
+	}
+
+	// 删除pause容器后veth会随网络命名空间一起消失，先记录它是否存在
+	vethHost, vethCont := getPairName(name, pauseID)
+	_, vethErr := netlink.LinkByName(vethHost)
+	vethExists := vethErr == nil
+
+	containers := []struct {
+		kind, name, id string
+	}{
+		{"worker container", name, containerID},
+		{"pause container", name + "-pause", pauseID},
+	}
+	for _, c := range containers {
+		if c.id == "" {
+			// NoPause部署本来就没有pause容器
+			if c.kind == "worker container" || !config.Spec.NoPause {
+				summary.Absent = append(summary.Absent, c.kind)
+			}
+			continue
+		}
+		summary.Found = true
+		logf(ctx, "Stopping %s: %s (ID: %s, timeout: %s)", c.kind, c.name, c.id, formatStopTimeout(stopOptions.Timeout))
+		if err := dockerClient.ContainerStop(ctx, c.id, stopOptions); err != nil {
+			logf(ctx, "Warning: failed to stop container %s: %v", c.id, err)
+		}
+		logf(ctx, "Removing %s: %s (ID: %s)", c.kind, c.name, c.id)
+		if err := dockerClient.ContainerRemove(ctx, c.id, types.ContainerRemoveOptions{
 			Force: true,
-		}); err != nil {
-			return "", fmt.Errorf("failed to remove container: %v", err)
+		}); err != nil && !client.IsErrNotFound(err) {
+			failures = append(failures, fmt.Sprintf("%s %s: %v", c.kind, c.name, err))
+			continue
 		}
+		summary.Cleaned = append(summary.Cleaned, fmt.Sprintf("%s %.12s", c.kind, c.id))
 	}
 
-	if pauseID != "" {
-		//删除Pause容器
-		logger.Printf("Stopping pause container: %s (ID: %s)", pauseName, pauseID)
-		if err := dockerClient.ContainerRemove(ctx, pauseID, types.ContainerRemoveOptions{
-			Force: true,
-		}); err != nil {
-			return "", fmt.Errorf("failed to remove container: %v", err)
+	if vethExists {
+		logf(ctx, "Deleting veth pair: %s <-> %s", vethHost, vethCont)
+		if err := bridgeBackendFor(config.Spec.BridgeType).DetachPort(config.Spec.BrName, vethHost); err != nil {
+			logf(ctx, "Warning: failed to detach %s from bridge: %v", vethHost, err)
+		}
+		// pause容器删除后veth可能已随网络命名空间消失
+		if link, err := netlink.LinkByName(vethHost); err == nil {
+			if err := netlink.LinkDel(link); err != nil {
+				failures = append(failures, fmt.Sprintf("veth %s: %v", vethHost, err))
+			}
 		}
+		summary.Cleaned = append(summary.Cleaned, "veth "+vethHost)
+	} else {
+		summary.Absent = append(summary.Absent, "veth "+vethHost)
+	}
+
+	// 删除端口转发规则
+	removePortForwards(config)
 
-		vethHost, vethCont := getPairName(config.Metadata.Name, pauseID)
-		logger.Printf("Deleting veth pair: %s <-> %s", vethHost, vethCont)
-		// 删除veth pair
-		if err := deleteVethPair(vethHost); err != nil {
-			logger.Printf("Warning: failed to delete veth pair: %v", err)
+	// 恢复VF的原驱动
+	if config.Spec.NetworkType == "SRIOV" {
+		releaseVFDrivers(config)
+	}
+	if config.Spec.NetworkType == "VETH" {
+		deleteVethDataPorts(config)
+	}
+
+	for _, file := range []string{trexConfigPath(name), resolvConfPath(name)} {
+		err := os.Remove(file)
+		switch {
+		case err == nil:
+			summary.Cleaned = append(summary.Cleaned, "file "+file)
+		case os.IsNotExist(err):
+			if file == trexConfigPath(name) {
+				summary.Absent = append(summary.Absent, "file "+file)
+			}
+		default:
+			failures = append(failures, fmt.Sprintf("file %s: %v", file, err))
 		}
 	}
 
-	configFile := fmt.Sprintf("/tmp/trex/%s_trex_cfg.yaml", config.Metadata.Name)
-	if err := os.Remove(configFile); err != nil && !os.IsNotExist(err) {
-		logger.Printf("Warning: failed to delete config file %s: %v", configFile, err)
+	if len(failures) > 0 {
+		return summary, fmt.Errorf("failed to delete %s", strings.Join(failures, "; "))
 	}
+	return summary, nil
+}
 
-	return fmt.Sprintf("Container %s deleted", name), nil
+// releaseDeploymentBridges 释放副本持有的管理网桥和数据端口网桥引用
+func releaseDeploymentBridges(config TRExConfig) {
+	releaseBridge(config.Spec.BrName, config.Spec.BridgeType)
+	releaseDataPortBridges(config.Spec)
 }
 
 func deleteVethPair(vethHost string) error {
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// 测试中不写日志文件
+	logger = log.New(io.Discard, "", 0)
+	os.Exit(m.Run())
+}
+
+// useTestStateStore 将stateStore替换为临时目录中的状态文件，测试结束后恢复
+func useTestStateStore(t *testing.T) {
+	t.Helper()
+	store, err := NewStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := stateStore
+	stateStore = store
+	t.Cleanup(func() { stateStore = old })
+}
+
+func TestHandleRequestDryRunOnlyForApply(t *testing.T) {
+	for _, action := range []string{"update", "delete"} {
+		t.Run(action, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/"+action+"?dryRun=true", strings.NewReader("metadata:\n  name: trex-test\n"))
+			rec := httptest.NewRecorder()
+			handleRequest(rec, req, action)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			if !strings.Contains(rec.Body.String(), "only supported by apply") {
+				t.Errorf("body = %q, want it to mention apply", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus 监控指标
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "trex_controller_requests_total",
+		Help: "Total number of apply/update/delete requests.",
+	}, []string{"action"})
+
+	requestFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "trex_controller_request_failures_total",
+		Help: "Total number of failed apply/update/delete requests.",
+	}, []string{"action", "code"})
+
+	managedDeployments = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "trex_controller_managed_deployments",
+		Help: "Number of TREx deployments currently managed by the controller.",
+	})
+
+	reconcileRunsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "trex_controller_reconcile_runs_total",
+		Help: "Total number of reconcile runs.",
+	})
+
+	deployDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "trex_controller_deploy_duration_seconds",
+		Help:    "Time taken to deploy a TREx container.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 10),
+	})
+)
+
+// recordFailure 记录一次失败的请求
+func recordFailure(action string, code int) {
+	requestFailuresTotal.WithLabelValues(action, strconv.Itoa(code)).Inc()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// 无需认证即可访问的路径
+var publicPaths = map[string]bool{
+	"/health":  true,
+	"/livez":   true,
+	"/ready":   true,
+	"/version": true,
+	"/metrics": true,
+}
+
+// authMiddleware 校验请求头中的 Bearer Token
+func authMiddleware(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		auth := r.Header.Get("Authorization")
+		const prefix = "Bearer "
+		if !strings.HasPrefix(auth, prefix) ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, prefix)), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestIDKey 请求ID在context中的键
+type requestIDKey struct{}
+
+// requestIDMiddleware 为每个请求生成请求ID（或使用请求头X-Request-ID），放入context并通过响应头返回
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// isValidRequestID 校验客户端提供的请求ID，避免在日志中注入任意内容
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID 生成随机的请求ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestIDFrom 返回context中的请求ID，不存在时返回空字符串
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// recoverMiddleware 捕获处理函数中的panic，记录堆栈并返回500，避免单个请求导致控制器进程退出
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// 客户端断开等情况由net/http主动中止处理，保持原有行为
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			logf(r.Context(), "Error: panic while handling %s %s: %v\n%s", r.Method, r.URL.Path, rec, debug.Stack())
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":     "internal server error",
+				"requestId": requestIDFrom(r.Context()),
+			})
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
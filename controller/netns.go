@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+)
+
+// netnsRunDir ip netns查找命名网络命名空间的目录
+const netnsRunDir = "/var/run/netns"
+
+// netnsPath 返回部署在netnsRunDir下的命名空间路径
+func netnsPath(name string) string {
+	return filepath.Join(netnsRunDir, name)
+}
+
+// netnsName 返回开启ExposeNetns时ip netns使用的名称，未开启时为空
+func netnsName(config TRExConfig) string {
+	if !config.Spec.ExposeNetns {
+		return ""
+	}
+	return config.Metadata.Name
+}
+
+// ensureNetnsRunDir 与ip netns add相同：创建目录并将其设为shared挂载点，
+// 使其中的绑定挂载传播到其他挂载命名空间（例如宿主机上的shell）
+func ensureNetnsRunDir() error {
+	if err := os.MkdirAll(netnsRunDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", netnsRunDir, err)
+	}
+	err := syscall.Mount("", netnsRunDir, "none", syscall.MS_SHARED|syscall.MS_REC, "")
+	if err == syscall.EINVAL {
+		// 目录还不是挂载点，先绑定挂载到自身
+		if err := syscall.Mount(netnsRunDir, netnsRunDir, "none", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to bind mount %s: %v", netnsRunDir, err)
+		}
+		err = syscall.Mount("", netnsRunDir, "none", syscall.MS_SHARED|syscall.MS_REC, "")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to make %s a shared mount: %v", netnsRunDir, err)
+	}
+	return nil
+}
+
+// exposeNetns 将进程的网络命名空间绑定挂载到/var/run/netns/<name>，之后可以使用ip netns exec <name>
+func exposeNetns(name string, pid int) error {
+	if err := ensureNetnsRunDir(); err != nil {
+		return err
+	}
+
+	path := netnsPath(name)
+	// 清理上次未正常删除的挂载
+	unexposeNetns(name)
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE|os.O_EXCL, 0444)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	f.Close()
+
+	if err := syscall.Mount(fmt.Sprintf("/proc/%d/ns/net", pid), path, "none", syscall.MS_BIND, ""); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to bind mount network namespace to %s: %v", path, err)
+	}
+	return nil
+}
+
+// unexposeNetns 卸载并删除/var/run/netns/<name>。绑定挂载会使网络命名空间在容器删除后继续存在，
+// 其中的VF也不会回到宿主机，因此需要在删除容器之前调用
+func unexposeNetns(name string) {
+	path := netnsPath(name)
+	if err := syscall.Unmount(path, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL && err != syscall.ENOENT {
+		logger.Printf("Warning: failed to unmount %s: %v", path, err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logger.Printf("Warning: failed to remove %s: %v", path, err)
+	}
+}
+
+// retryNetns 执行依赖容器网络命名空间的操作，失败时按-netns-retries和-netns-retry-delay重试：
+// 繁忙的宿主机上容器刚启动时命名空间可能短暂不可用，避免一次竞争导致整个部署失败并回滚
+func retryNetns(ctx context.Context, what string, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || attempt >= *netnsRetries {
+			return err
+		}
+		debugf(ctx, "%s failed (attempt %d/%d), retrying in %s: %v", what, attempt+1, *netnsRetries+1, *netnsRetryDelay, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(*netnsRetryDelay):
+		}
+	}
+}
+
+// linkSetNetns 将接口移入网络命名空间，命名空间路径无法打开或移动失败时重试
+func linkSetNetns(ctx context.Context, link netlink.Link, netnsPath string) error {
+	return retryNetns(ctx, fmt.Sprintf("moving %s to %s", link.Attrs().Name, netnsPath), func() error {
+		file, err := os.Open(netnsPath)
+		if err != nil {
+			return fmt.Errorf("failed to open netns path %s: %v", netnsPath, err)
+		}
+		defer file.Close()
+		return netlink.LinkSetNsFd(link, int(file.Fd()))
+	})
+}
+
+// renameInNetns 在网络命名空间中将移入的接口重命名为ifName，失败时重试；ifName已存在时视为上次尝试已完成重命名
+func renameInNetns(ctx context.Context, netnsPath string, link netlink.Link, ifName string) error {
+	return retryNetns(ctx, fmt.Sprintf("renaming %s to %s", link.Attrs().Name, ifName), func() error {
+		return ns.WithNetNSPath(netnsPath, func(_ ns.NetNS) error {
+			if _, err := netlink.LinkByName(ifName); err == nil {
+				return nil
+			}
+			if err := netlink.LinkSetName(link, ifName); err != nil {
+				return fmt.Errorf("failed to rename %s: %v", link.Attrs().Name, err)
+			}
+			_, err := netlink.LinkByName(ifName)
+			return err
+		})
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/containernetworking/plugins/pkg/testutils"
+)
+
+// withTestNetns 在新建的网络命名空间中执行fn，没有CAP_NET_ADMIN（无法创建命名空间）或内核不支持所需的接口类型
+// （fn返回包装了EOPNOTSUPP的错误）时跳过测试。
+// fn运行在锁定线程的其他goroutine中，不能调用t.Fatal，致命错误通过返回值报告
+func withTestNetns(t *testing.T, fn func() error) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("requires root (CAP_NET_ADMIN) to create a network namespace")
+	}
+	netns, err := testutils.NewNS()
+	if err != nil {
+		t.Skipf("cannot create network namespace: %v", err)
+	}
+	defer func() {
+		netns.Close()
+		testutils.UnmountNS(netns)
+	}()
+
+	if err := netns.Do(func(ns.NetNS) error {
+		return fn()
+	}); errors.Is(err, syscall.EOPNOTSUPP) {
+		t.Skipf("not supported by the kernel: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/vishvananda/netlink"
+	"hash/fnv"
 	"log"
 	"net"
 	"os"
@@ -48,12 +50,6 @@ func EnsureBridge(brName string, mtu int, promiscMode, vlanFiltering bool) (*net
 		return nil, fmt.Errorf("could not add %q: %v", brName, err)
 	}
 
-	//if promiscMode {
-	//	if err := netlink.SetPromiscOn(br); err != nil {
-	//		return nil, fmt.Errorf("could not set promiscuous mode on %q: %v", brName, err)
-	//	}
-	//}
-
 	// Re-fetch link to read all attributes and if it already existed,
 	// ensure it's really a bridge with similar configuration
 	br, err = bridgeByName(brName)
@@ -61,102 +57,619 @@ func EnsureBridge(brName string, mtu int, promiscMode, vlanFiltering bool) (*net
 		return nil, err
 	}
 
-	if err := netlink.LinkSetUp(br); err != nil {
+	// 共享的网桥MTU与部署不一致时只告警，不修改其他部署正在使用的网桥
+	if br.Attrs().MTU != mtu {
+		logger.Printf("Warning: bridge %s already exists with MTU %d, requested MTU is %d", brName, br.Attrs().MTU, mtu)
+	}
+
+	if promiscMode && br.Attrs().Promisc == 0 {
+		if err := netlink.SetPromiscOn(br); err != nil {
+			return nil, fmt.Errorf("could not set promiscuous mode on %q: %v", brName, err)
+		}
+		logger.Printf("Enabled promiscuous mode on bridge %s", brName)
+	}
+
+	// 已存在的网桥按需开启VLAN过滤
+	if vlanFiltering && (br.VlanFiltering == nil || !*br.VlanFiltering) {
+		if err := netlink.BridgeSetVlanFiltering(br, true); err != nil {
+			return nil, fmt.Errorf("could not enable VLAN filtering on %q: %v", brName, err)
+		}
+		logger.Printf("Enabled VLAN filtering on bridge %s", brName)
+	}
+
+	// 返回拉起后重新读取的网桥，避免调用方看到过期的状态
+	up, err := ensureLinkUp(br, "bridge")
+	if err != nil {
 		return nil, err
 	}
+	br = up.(*netlink.Bridge)
+
+	logger.Printf("Created bridge %s Successed!", brName)
 
-	logger.Println(fmt.Sprintf("Created bridge %s Successed!", brName))
+	return br, nil
+}
+
+// acquireBridge 确保网桥存在并增加其引用计数，网桥锁保证并发创建的安全
+func acquireBridge(spec Spec) (netlink.Link, error) {
+	return acquireNamedBridge(spec, spec.BrName)
+}
+
+// acquireNamedBridge 按部署的MTU、混杂模式和VLAN过滤设置确保指定网桥存在并增加其引用计数
+func acquireNamedBridge(spec Spec, brName string) (netlink.Link, error) {
+	lock := containerLocks.GetLock("bridge:" + brName)
+	lock.Lock()
+	defer lock.Unlock()
 
+	br, err := bridgeBackendFor(spec.BridgeType).EnsureBridge(brName, linkMTU(spec), spec.PromiscMode, spec.VlanFiltering)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stateStore.AcquireBridge(brName); err != nil {
+		logger.Printf("Warning: failed to save reference count for bridge %s: %v", brName, err)
+	}
 	return br, nil
 }
 
+// releaseBridge 减少网桥的引用计数，计数为0且开启了-delete-empty-bridges时删除网桥
+func releaseBridge(brName, bridgeType string) {
+	lock := containerLocks.GetLock("bridge:" + brName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	count, err := stateStore.ReleaseBridge(brName)
+	if err != nil {
+		logger.Printf("Warning: failed to save reference count for bridge %s: %v", brName, err)
+	}
+	if count > 0 {
+		return
+	}
+
+	backend := bridgeBackendFor(bridgeType)
+
+	// 网桥不再被使用时，将由控制器接入的上联接口移出网桥
+	for _, uplink := range stateStore.UplinksOf(brName) {
+		detachUplink(backend, brName, uplink)
+	}
+
+	if !*deleteEmptyBridges {
+		return
+	}
+
+	logger.Printf("Deleting unused bridge %s", brName)
+	if err := backend.DeleteBridge(brName); err != nil {
+		logger.Printf("Warning: failed to delete bridge %s: %v", brName, err)
+	}
+}
+
+// attachUplink 将上联接口接入网桥以便流量离开主机，已在该网桥中时不做修改
+func attachUplink(spec Spec) error {
+	brName := spec.BrName
+	uplink := spec.Uplink
+	backend := bridgeBackendFor(spec.BridgeType)
+	lock := containerLocks.GetLock("bridge:" + brName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	link, err := netlink.LinkByName(uplink)
+	if err != nil {
+		return fmt.Errorf("uplink %s does not exist: %v", uplink, err)
+	}
+	// 已被bond、VRF或其他网桥占用的接口不能再接入网桥；OVS端口的master为ovs-system，由PortBridge判断
+	master, err := linkMaster(link)
+	if err != nil {
+		return err
+	}
+	if master != nil && master.Attrs().Name != brName && master.Type() != "openvswitch" {
+		return fmt.Errorf("uplink %s (%s) is already enslaved to %s %s", uplink, linkState(link), master.Type(), master.Attrs().Name)
+	}
+	current, err := backend.PortBridge(link)
+	if err != nil {
+		return err
+	}
+	if current == brName {
+		return nil
+	}
+	if current != "" {
+		return fmt.Errorf("uplink %s is already attached to bridge %s", uplink, current)
+	}
+
+	// 接入网桥后接口上的地址将不再可用，可能导致主机失联
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("failed to list addresses of uplink %s: %v", uplink, err)
+	}
+	for _, addr := range addrs {
+		if addr.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		logger.Printf("WARNING: uplink %s carries host address %s, which will stop working once it is attached to bridge %s; move the address to the bridge if the host needs it", uplink, addr.IPNet, brName)
+	}
+
+	if err := backend.AttachPort(brName, link); err != nil {
+		return fmt.Errorf("failed to attach uplink %s to bridge %s: %v", uplink, brName, err)
+	}
+	if _, err := ensureLinkUp(link, "uplink"); err != nil {
+		return err
+	}
+	if err := stateStore.RecordUplink(uplink, brName); err != nil {
+		logger.Printf("Warning: failed to save uplink %s: %v", uplink, err)
+	}
+	logger.Printf("Attached uplink %s to bridge %s", uplink, brName)
+	return nil
+}
+
+// detachUplink 将由控制器接入的上联接口移出网桥，调用方需持有网桥锁
+func detachUplink(backend bridgeBackend, brName, uplink string) {
+	if err := backend.DetachPort(brName, uplink); err != nil {
+		logger.Printf("Warning: failed to detach uplink %s: %v", uplink, err)
+		return
+	}
+	logger.Printf("Detached uplink %s from bridge %s", uplink, brName)
+	if err := stateStore.ForgetUplink(uplink); err != nil {
+		logger.Printf("Warning: failed to update state for uplink %s: %v", uplink, err)
+	}
+}
+
+// getPairName 根据部署名称生成确定的veth-pair名称，删除和清理时可直接重新计算而无需保存
 func getPairName(name, pauseID string) (string, string) {
+	name = shortName(name)
+	return fmt.Sprintf("trex_%s", name), fmt.Sprintf("tmp%s", name)
+}
+
+// shortName 将部署名称缩短到10个字符以内，名称过长时截断并附加名称的哈希，避免副本（<name>-0、<name>-1）之间冲突
+func shortName(name string) string {
 	if len(name) > 10 {
-		name = name[:9]
+		h := fnv.New32a()
+		h.Write([]byte(name))
+		name = fmt.Sprintf("%s%06x", name[:4], h.Sum32()&0xffffff)
 	}
-	return fmt.Sprintf("trex_%s", name), fmt.Sprintf("tmp%s", name)
+	return name
+}
+
+// getDataPortNames 返回VETH模式下第i个数据端口的host端和容器端临时名称
+func getDataPortNames(name string, i int) (string, string) {
+	name = shortName(name)
+	return fmt.Sprintf("t%s_%d", name, i), fmt.Sprintf("c%s_%d", name, i)
+}
+
+// dataPortIFName 返回数据端口在容器内的网卡名称，未配置时为data<i>
+func dataPortIFName(port Port, i int) string {
+	if port.IFName != "" {
+		return port.IFName
+	}
+	return fmt.Sprintf("data%d", i)
+}
+
+func configurePauseContainerNetwork(ctx context.Context, config TRExConfig, pid int, br netlink.Link, pauseID string) (map[string]string, error) {
+	if err := configureMgmtNetwork(ctx, config, pid, br, pauseID); err != nil {
+		return nil, err
+	}
+
+	vfPCIMap := make(map[string]string)
+
+	// 配置VF vlanID
+	if config.Spec.NetworkType == "SRIOV" {
+		var err error
+		vfPCIMap, err = configVFNetwork(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 配置veth数据端口
+	if config.Spec.NetworkType == "VETH" {
+		bridges, err := dataPortBridgeLinks(config.Spec, br)
+		if err != nil {
+			return nil, err
+		}
+		vfPCIMap, err = configVethDataPorts(ctx, config, pid, bridges)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return vfPCIMap, nil
 }
 
-func configurePauseContainerNetwork(config TRExConfig, pid int, br *netlink.Bridge, pauseID string) (map[string]string, error) {
+// configureMgmtNetwork 创建管理网veth pair，host端接入网桥，容器端移入pause容器并配置地址和路由
+func configureMgmtNetwork(ctx context.Context, config TRExConfig, pid int, br netlink.Link, pauseID string) error {
 	// 使用网络命名空间文件路径
 	vethHost, vethCont := getPairName(config.Metadata.Name, pauseID)
 
 	// 创建veth pair
-	hostVeth, contVeth, err := createVethPair(vethHost, vethCont, 1500)
+	hostVeth, contVeth, err := createVethPair(vethHost, vethCont, linkMTU(config.Spec), config.Spec.TxQLen)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// 将host端veth连接到网桥
-	if err := netlink.LinkSetMaster(hostVeth, br); err != nil {
-		return nil, fmt.Errorf("failed to connect veth to bridge: %v", err)
+	if err := bridgeBackendFor(config.Spec.BridgeType).AttachPort(br.Attrs().Name, hostVeth); err != nil {
+		return fmt.Errorf("failed to connect veth to bridge: %v", err)
 	}
 
 	// 启用host端veth
 	if err := netlink.LinkSetUp(hostVeth); err != nil {
-		return nil, fmt.Errorf("failed to set host veth up: %v", err)
+		return fmt.Errorf("failed to set host veth up: %v", err)
 	}
 	netnsPath := fmt.Sprintf("/proc/%d/ns/net", pid)
-	if err := netlink.LinkSetNsFd(contVeth, int(netnsPathFD(netnsPath))); err != nil {
-		return nil, fmt.Errorf("failed to move veth to container: %v", err)
+	if err := linkSetNetns(ctx, contVeth, netnsPath); err != nil {
+		return fmt.Errorf("failed to move veth to container: %v", err)
 	}
 
-	vfPCIMap := make(map[string]string)
-
-	// 配置VF vlanID
-	if config.Spec.NetworkType == "SRIOV" {
-		vfPCIMap, err = configVFNetwork(config)
-		if err != nil {
-			return nil, err
-		}
+	// 重命名容器端veth
+	ifName := config.Spec.MgmtIFName
+	if err := renameInNetns(ctx, netnsPath, contVeth, ifName); err != nil {
+		return fmt.Errorf("failed to rename container veth: %v", err)
 	}
 
 	// 进入网络命名空间配置
-	return vfPCIMap, ns.WithNetNSPath(netnsPath, func(_ ns.NetNS) error {
-		// 重命名容器端veth
-		if err := netlink.LinkSetName(contVeth, "mgmt"); err != nil {
-			return fmt.Errorf("failed to rename container veth: %v", err)
-		}
-		eth0, err := netlink.LinkByName("mgmt")
+	return ns.WithNetNSPath(netnsPath, func(_ ns.NetNS) error {
+		eth0, err := netlink.LinkByName(ifName)
 		if err != nil {
-			return fmt.Errorf("failed to find mgmt: %v", err)
+			return fmt.Errorf("failed to find %s: %v", ifName, err)
+		}
+
+		// 启用前设置固定的MAC地址，未配置时保持内核分配的地址
+		if config.Spec.MgmtMAC != "" {
+			if err := setLinkMAC(eth0, config.Spec.MgmtMAC); err != nil {
+				return err
+			}
 		}
 
 		// 启用容器端接口
 		if err := netlink.LinkSetUp(eth0); err != nil {
-			return fmt.Errorf("failed to set mgmt up: %v", err)
+			return fmt.Errorf("failed to set %s up: %v", ifName, err)
 		}
 
 		// 添加IP地址
-		if !strings.Contains(config.Spec.MgmtIP, "/") {
-			config.Spec.MgmtIP = fmt.Sprintf("%s/32", config.Spec.MgmtIP)
+		var addrs []*netlink.Addr
+		for _, mgmtIP := range mgmtAddresses(config.Spec) {
+			addr, err := netlink.ParseAddr(mgmtIP)
+			if err != nil {
+				return fmt.Errorf("failed to parse IP address %s: %v", mgmtIP, err)
+			}
+			if err := netlink.AddrAdd(eth0, addr); err != nil {
+				return fmt.Errorf("failed to add IP address %s: %v", mgmtIP, err)
+			}
+			addrs = append(addrs, addr)
 		}
-		addr, err := netlink.ParseAddr(config.Spec.MgmtIP)
-		if err != nil {
-			return fmt.Errorf("failed to parse IP address: %v", err)
+
+		return addMgmtRoutes(eth0, addrs, config.Spec)
+	})
+}
+
+// setLinkMAC 将接口的MAC地址设置为mac
+func setLinkMAC(link netlink.Link, mac string) error {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return fmt.Errorf("invalid MAC address %s: %v", mac, err)
+	}
+	if err := netlink.LinkSetHardwareAddr(link, hw); err != nil {
+		return fmt.Errorf("failed to set MAC address %s on %s: %v", mac, link.Attrs().Name, err)
+	}
+	return nil
+}
+
+// addMgmtRoutes 在管理网接口上添加默认路由（IPv6网关使用::/0）及静态路由，未设置MgmtGateway时不添加默认路由
+func addMgmtRoutes(link netlink.Link, addrs []*netlink.Addr, spec Spec) error {
+	if spec.MgmtGateway != "" {
+		gateway := net.ParseIP(spec.MgmtGateway)
+		if err := addOnLinkRoute(link, addrs, gateway); err != nil {
+			return err
+		}
+		route := netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Dst:       nil,
+			Gw:        gateway,
 		}
-		if err := netlink.AddrAdd(eth0, addr); err != nil {
-			return fmt.Errorf("failed to add IP address: %v", err)
+		if gateway.To4() == nil {
+			route.Dst = &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
 		}
+		if err := netlink.RouteAdd(&route); err != nil && err != syscall.EEXIST {
+			if err != syscall.ENETUNREACH {
+				return fmt.Errorf("failed to add default route: %v", err)
+			}
+			log.Printf("Warning: Network unreachable when adding default route, continuing anyway")
+		}
+	}
 
-		// 添加默认路由
+	// 添加额外的静态路由
+	for _, r := range spec.Routes {
+		_, dst, err := net.ParseCIDR(r.Dst)
+		if err != nil {
+			return fmt.Errorf("failed to parse route destination %s: %v", r.Dst, err)
+		}
+		gw := net.ParseIP(r.Gw)
+		if err := addOnLinkRoute(link, addrs, gw); err != nil {
+			return err
+		}
 		route := netlink.Route{
-			Dst: nil,
-			Gw:  net.ParseIP(config.Spec.MgmtGateway),
+			LinkIndex: link.Attrs().Index,
+			Dst:       dst,
+			Gw:        gw,
 		}
 		if err := netlink.RouteAdd(&route); err != nil && err != syscall.EEXIST {
-			if err == syscall.ENETUNREACH {
-				log.Printf("Warning: Network unreachable when adding default route, continuing anyway")
-				return nil
+			return fmt.Errorf("failed to add route %s via %s: %v", r.Dst, r.Gw, err)
+		}
+	}
+	return nil
+}
+
+// reconfigureMgmtNetwork 在运行中的网络命名空间内将管理网的地址和路由从old更新为desired：
+// 先删除控制器添加的路由（内核生成的子网路由除外），再增删地址，最后按desired重新添加路由
+func reconfigureMgmtNetwork(old, desired Spec, pid int) error {
+	netnsPath := fmt.Sprintf("/proc/%d/ns/net", pid)
+	return ns.WithNetNSPath(netnsPath, func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(desired.MgmtIFName)
+		if err != nil {
+			return fmt.Errorf("failed to find %s: %v", desired.MgmtIFName, err)
+		}
+
+		routes, err := netlink.RouteList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			return fmt.Errorf("failed to list routes of %s: %v", desired.MgmtIFName, err)
+		}
+		for _, route := range routes {
+			if route.Protocol == syscall.RTPROT_KERNEL {
+				continue
+			}
+			if err := netlink.RouteDel(&route); err != nil && err != syscall.ESRCH {
+				return fmt.Errorf("failed to delete route %s: %v", route, err)
 			}
-			return fmt.Errorf("failed to add default route: %v", err)
 		}
 
-		return nil
+		wanted := make(map[string]bool)
+		var addrs []*netlink.Addr
+		for _, mgmtIP := range mgmtAddresses(desired) {
+			addr, err := netlink.ParseAddr(mgmtIP)
+			if err != nil {
+				return fmt.Errorf("failed to parse IP address %s: %v", mgmtIP, err)
+			}
+			wanted[addr.IPNet.String()] = true
+			addrs = append(addrs, addr)
+		}
+		for _, mgmtIP := range mgmtAddresses(old) {
+			addr, err := netlink.ParseAddr(mgmtIP)
+			if err != nil || wanted[addr.IPNet.String()] {
+				continue
+			}
+			if err := netlink.AddrDel(link, addr); err != nil && err != syscall.EADDRNOTAVAIL {
+				return fmt.Errorf("failed to delete IP address %s: %v", mgmtIP, err)
+			}
+		}
+		for _, addr := range addrs {
+			if err := netlink.AddrAdd(link, addr); err != nil && err != syscall.EEXIST {
+				return fmt.Errorf("failed to add IP address %s: %v", addr.IPNet, err)
+			}
+		}
+
+		return addMgmtRoutes(link, addrs, desired)
 	})
 }
 
-func createVethPair(hostName, contName string, mtu int) (netlink.Link, netlink.Link, error) {
+// addOnLinkRoute 网关不在任何地址的子网内（如/32主机地址）时，先添加到网关的on-link路由
+func addOnLinkRoute(link netlink.Link, addrs []*netlink.Addr, gateway net.IP) error {
+	for _, addr := range addrs {
+		if ones, bits := addr.Mask.Size(); ones < bits && addr.Contains(gateway) {
+			return nil
+		}
+	}
+
+	bits := 8 * len(gateway)
+	if gw4 := gateway.To4(); gw4 != nil {
+		gateway = gw4
+		bits = 32
+	}
+	gwRoute := netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Scope:     netlink.SCOPE_LINK,
+		Dst: &net.IPNet{
+			IP:   gateway,
+			Mask: net.CIDRMask(bits, bits),
+		},
+	}
+	if err := netlink.RouteAdd(&gwRoute); err != nil && err != syscall.EEXIST {
+		return fmt.Errorf("failed to add on-link route to gateway %s: %v", gateway, err)
+	}
+	return nil
+}
+
+// portBridgeName 返回数据端口接入的网桥，未设置Port.Bridge时为管理网桥
+func portBridgeName(spec Spec, port Port) string {
+	if port.Bridge != "" {
+		return port.Bridge
+	}
+	return spec.BrName
+}
+
+// dataPortBridges 返回VETH模式下数据端口使用的、管理网桥之外的网桥（去重，按端口顺序）
+func dataPortBridges(spec Spec) []string {
+	if spec.NetworkType != "VETH" {
+		return nil
+	}
+	var bridges []string
+	seen := map[string]bool{spec.BrName: true}
+	for _, port := range spec.Port {
+		if port.Bridge != "" && !seen[port.Bridge] {
+			seen[port.Bridge] = true
+			bridges = append(bridges, port.Bridge)
+		}
+	}
+	return bridges
+}
+
+// acquireDataPortBridges 确保数据端口使用的网桥存在并增加引用计数，已获取的网桥记录在state中以便回滚
+func acquireDataPortBridges(state *deploymentState, spec Spec) error {
+	for _, name := range dataPortBridges(spec) {
+		if _, err := acquireNamedBridge(spec, name); err != nil {
+			return fmt.Errorf("failed to ensure data port bridge %s: %v", name, err)
+		}
+		state.portBridges = append(state.portBridges, name)
+	}
+	return nil
+}
+
+// releaseDataPortBridges 释放数据端口使用的网桥引用
+func releaseDataPortBridges(spec Spec) {
+	for _, name := range dataPortBridges(spec) {
+		releaseBridge(name, spec.BridgeType)
+	}
+}
+
+// dataPortBridgeLinks 返回网桥名到接口的映射，包含管理网桥和数据端口使用的网桥
+func dataPortBridgeLinks(spec Spec, br netlink.Link) (map[string]netlink.Link, error) {
+	bridges := map[string]netlink.Link{spec.BrName: br}
+	for _, name := range dataPortBridges(spec) {
+		link, err := netlink.LinkByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bridge %s: %v", name, err)
+		}
+		bridges[name] = link
+	}
+	return bridges, nil
+}
+
+// configVethDataPorts 为VETH模式的每个数据端口创建接口并移入pause容器：
+// 配置了VlanId且未开启VLAN过滤时在父接口（未配置时为网桥）上创建VLAN子接口，
+// 否则创建veth pair并将host端接入端口的网桥（Port.Bridge，未设置时为管理网桥），开启VLAN过滤时在网桥端口上设置PVID。
+// bridges为网桥名到接口的映射；返回容器内网卡名到TRex接口（af_packet vdev）的映射
+func configVethDataPorts(ctx context.Context, config TRExConfig, pid int, bridges map[string]netlink.Link) (map[string]string, error) {
+	netnsPath := fmt.Sprintf("/proc/%d/ns/net", pid)
+	interfaces := make(map[string]string)
+
+	for i, port := range config.Spec.Port {
+		hostName, contName := getDataPortNames(config.Metadata.Name, i)
+		ifName := dataPortIFName(port, i)
+		br, ok := bridges[portBridgeName(config.Spec, port)]
+		if !ok {
+			return nil, fmt.Errorf("bridge %s of data port %s is not ready", portBridgeName(config.Spec, port), ifName)
+		}
+
+		var contLink netlink.Link
+		var err error
+		if port.VlanId > 0 && !config.Spec.VlanFiltering {
+			contLink, err = createVlanLink(config, contName, port.VlanId, br)
+		} else {
+			var hostVeth netlink.Link
+			hostVeth, contLink, err = createVethPair(hostName, contName, linkMTU(config.Spec), config.Spec.TxQLen)
+			if err == nil {
+				backend := bridgeBackendFor(config.Spec.BridgeType)
+				if err = backend.AttachPort(br.Attrs().Name, hostVeth); err != nil {
+					err = fmt.Errorf("failed to connect veth to bridge: %v", err)
+				} else if err = netlink.LinkSetUp(hostVeth); err != nil {
+					err = fmt.Errorf("failed to set host veth up: %v", err)
+				} else if config.Spec.VlanFiltering && port.VlanId > 0 {
+					err = backend.SetPortVlan(br.Attrs().Name, hostVeth, port.VlanId)
+				}
+				if err == nil && port.Hairpin {
+					err = setPortHairpin(config.Spec.BridgeType, hostVeth)
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create data port %s: %v", ifName, err)
+		}
+
+		if err := linkSetNetns(ctx, contLink, netnsPath); err != nil {
+			return nil, fmt.Errorf("failed to move data port %s to container: %v", ifName, err)
+		}
+		if err := renameInNetns(ctx, netnsPath, contLink, ifName); err != nil {
+			return nil, fmt.Errorf("failed to rename data port %s: %v", contName, err)
+		}
+
+		err = ns.WithNetNSPath(netnsPath, func(_ ns.NetNS) error {
+			link, err := netlink.LinkByName(ifName)
+			if err != nil {
+				return fmt.Errorf("failed to find %s: %v", ifName, err)
+			}
+			return netlink.LinkSetUp(link)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		logf(ctx, "Configured data port %s (VLAN %d, bridge %s) for %s", ifName, port.VlanId, br.Attrs().Name, config.Metadata.Name)
+		interfaces[ifName] = afPacketVdev(i, ifName)
+	}
+
+	return interfaces, nil
+}
+
+// afPacketVdev 返回第i个VETH数据端口对应的TRex af_packet虚拟设备参数
+func afPacketVdev(i int, ifName string) string {
+	return fmt.Sprintf("--vdev=net_af_packet%d,iface=%s", i, ifName)
+}
+
+// setPortHairpin 在Linux网桥端口上开启hairpin，允许流量从接收端口发回
+func setPortHairpin(bridgeType string, port netlink.Link) error {
+	if bridgeType == bridgeTypeOVS {
+		logger.Printf("Warning: hairpin is not supported on OVS bridge port %s, ignored", port.Attrs().Name)
+		return nil
+	}
+	if err := netlink.LinkSetHairpin(port, true); err != nil {
+		return fmt.Errorf("failed to enable hairpin on %s: %v", port.Attrs().Name, err)
+	}
+	return nil
+}
+
+// createVlanLink 在父接口（未配置父接口时为网桥）上创建指定VLAN的子接口
+func createVlanLink(config TRExConfig, name string, vlanID int, br netlink.Link) (netlink.Link, error) {
+	parent := br
+	if config.Spec.ParentInterface != "" {
+		var err error
+		parent, err = netlink.LinkByName(config.Spec.ParentInterface)
+		if err != nil {
+			return nil, fmt.Errorf("VLAN parent interface %s does not exist: %v", config.Spec.ParentInterface, err)
+		}
+	}
+	// 父接口down时VLAN子接口无法收发报文
+	parent, err := ensureLinkUp(parent, "VLAN parent interface")
+	if err != nil {
+		return nil, err
+	}
+
+	// 清理可能存在的残留接口
+	if link, err := netlink.LinkByName(name); err == nil {
+		netlink.LinkDel(link)
+	}
+
+	vlan := &netlink.Vlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        name,
+			MTU:         linkMTU(config.Spec),
+			ParentIndex: parent.Attrs().Index,
+		},
+		VlanId: vlanID,
+	}
+	if err := netlink.LinkAdd(vlan); err != nil {
+		return nil, fmt.Errorf("failed to create VLAN %d on %s: %v", vlanID, parent.Attrs().Name, err)
+	}
+
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find VLAN link: %v", err)
+	}
+	return link, nil
+}
+
+// deleteVethDataPorts 删除VETH模式数据端口的host端veth，容器内的接口随pause容器的网络命名空间一起删除
+func deleteVethDataPorts(config TRExConfig) {
+	for i, port := range config.Spec.Port {
+		if port.VlanId > 0 && !config.Spec.VlanFiltering {
+			continue
+		}
+		hostName, _ := getDataPortNames(config.Metadata.Name, i)
+		if err := bridgeBackendFor(config.Spec.BridgeType).DetachPort(portBridgeName(config.Spec, port), hostName); err != nil {
+			logger.Printf("Warning: failed to detach data port veth %s: %v", hostName, err)
+		}
+		if err := deleteVethPair(hostName); err != nil {
+			logger.Printf("Warning: failed to delete data port veth %s: %v", hostName, err)
+		}
+	}
+}
+
+// createVethPair 创建veth pair，txQLen不为空时同时设置两端的发送队列长度
+func createVethPair(hostName, contName string, mtu int, txQLen *int) (netlink.Link, netlink.Link, error) {
 	// 清理可能存在的残留接口
 	if link, err := netlink.LinkByName(hostName); err == nil {
 		netlink.LinkDel(link)
@@ -187,42 +700,186 @@ func createVethPair(hostName, contName string, mtu int) (netlink.Link, netlink.L
 		return nil, nil, fmt.Errorf("failed to find container veth: %v", err)
 	}
 
-	return hostVeth, contVeth, nil
-}
-
-// 辅助函数：获取网络命名空间文件描述符
-func netnsPathFD(netnsPath string) uintptr {
-	file, err := os.Open(netnsPath)
-	if err != nil {
-		panic(fmt.Sprintf("failed to open netns path %s: %v", netnsPath, err))
+	if txQLen != nil {
+		for _, link := range []netlink.Link{hostVeth, contVeth} {
+			if err := netlink.LinkSetTxQLen(link, *txQLen); err != nil {
+				return nil, nil, fmt.Errorf("failed to set txqueuelen of %s: %v", link.Attrs().Name, err)
+			}
+		}
 	}
-	return file.Fd()
+
+	return hostVeth, contVeth, nil
 }
 
-func configVFNetwork(config TRExConfig) (map[string]string, error) {
+func configVFNetwork(ctx context.Context, config TRExConfig) (map[string]string, error) {
 	parentIfName := config.Spec.ParentInterface
 	vfPCIMap := make(map[string]string)
 
 	for _, port := range config.Spec.Port {
+		// 父接口为bond时，VF属于某个成员PF
+		pfName, err := resolvePF(parentIfName, port.VFIndex)
+		if err != nil {
+			return nil, err
+		}
 		portIndex := strconv.Itoa(port.VFIndex)
-		//logger.Println(fmt.Sprintf("Configure VF %s Network", portIndex))
-		vfName := fmt.Sprintf("%sv%s", parentIfName, portIndex)
-		logger.Println(fmt.Sprintf("Configure VF %s Network", vfName))
-		vfPciAddress, err := getVFPciAddress(parentIfName, vfName)
+		//logger.Printf("Configure VF %s Network", portIndex)
+		vfName := fmt.Sprintf("%sv%s", pfName, portIndex)
+		logf(ctx, "Configure VF %s Network", vfName)
+		var vfPciAddress string
+		if config.Spec.DriverBind != "" {
+			// VF可能已绑定到vfio-pci而没有网络接口
+			vfPciAddress, err = vfPCIAddressByIndex(pfName, port.VFIndex)
+		} else {
+			vfPciAddress, err = getVFPciAddress(pfName, vfName)
+		}
 		if err != nil {
 			return nil, err
 		}
 		vfPCIMap[vfName] = vfPciAddress
 
-		if err = setVFVlan(parentIfName, port.VFIndex, port.VlanId); err != nil && err != syscall.EEXIST {
-			logger.Println(fmt.Sprintf("Warning: Failed to set VF VLAN ID: %v", err))
+		// 显式配置了MTU时设置VF网络接口的MTU
+		if config.Spec.MTU != 0 && config.Spec.DriverBind == "" {
+			if err = setLinkMTU(vfName, config.Spec.MTU); err != nil {
+				return nil, err
+			}
+		}
+
+		if config.Spec.DriverBind != "" {
+			if err = bindVFDriver(vfPciAddress, config.Spec.DriverBind); err != nil {
+				return nil, fmt.Errorf("failed to bind VF %s to %s: %v", vfName, config.Spec.DriverBind, err)
+			}
+		}
+
+		if err = setVFVlan(parentIfName, port); err != nil && err != syscall.EEXIST {
+			logf(ctx, "Warning: Failed to set VF VLAN ID: %v", err)
 			return nil, err
 		}
+
+		if port.LinkState != "" {
+			if err = setVFLinkState(parentIfName, port); err != nil {
+				return nil, err
+			}
+			logf(ctx, "Set VF %s link state: %s", vfName, port.LinkState)
+		}
 	}
 
 	return vfPCIMap, nil
 }
 
+// validateVFs 在做任何变更之前检查父接口是SR-IOV PF，且每个端口的VF接口都存在
+func validateVFs(config TRExConfig) error {
+	parent := config.Spec.ParentInterface
+	if parent == "" {
+		return fmt.Errorf("trexConfig.Spec.ParentInterface is empty, please configure trexConfig.Spec.ParentInterface")
+	}
+	if _, err := netlink.LinkByName(parent); err != nil {
+		return fmt.Errorf("parent interface %s not found: %v", parent, err)
+	}
+	slaves, err := bondSlaves(parent)
+	if err != nil {
+		return err
+	}
+
+	// 同时检查由Peer展开的VF
+	ports := dataPorts(config.Spec)
+	var missing []string
+	for i, port := range ports {
+		// 父接口为bond时检查拥有该VF的成员PF
+		pf := parent
+		if len(slaves) > 0 {
+			if pf, err = resolvePF(parent, port.VFIndex); err != nil {
+				return fmt.Errorf("Port[%d]: %v", i, err)
+			}
+		}
+		numVFs, totalVFs, err := sriovVFCounts(pf)
+		if err != nil {
+			return err
+		}
+		if totalVFs > 0 && port.VFIndex >= totalVFs {
+			return fmt.Errorf("Port[%d]: VF index %d exceeds the %d VFs supported by parent %s", i, port.VFIndex, totalVFs, pf)
+		}
+		if port.VFIndex >= numVFs {
+			path := sriovNumVFsPath(pf)
+			hint := fmt.Sprintf("echo %d > %s", minVFs(ports, totalVFs), path)
+			if numVFs > 0 {
+				// 内核要求先将sriov_numvfs置0才能修改VF数量
+				hint = fmt.Sprintf("echo 0 > %s && %s", path, hint)
+			}
+			return fmt.Errorf("Port[%d]: parent %s has %d/%d VFs enabled but VF index %d is requested; run `%s` to enable more VFs",
+				i, pf, numVFs, totalVFs, port.VFIndex, hint)
+		}
+		vfName := fmt.Sprintf("%sv%d", pf, port.VFIndex)
+		vfPath := filepath.Join("/sys/class/net", vfName)
+		if config.Spec.DriverBind != "" {
+			// 绑定到vfio-pci的VF没有网络接口，检查PF的virtfn链接
+			vfPath = filepath.Join("/sys/class/net", pf, "device", fmt.Sprintf("virtfn%d", port.VFIndex))
+		}
+		if _, err := os.Stat(vfPath); err != nil {
+			missing = append(missing, fmt.Sprintf("Port[%d]: VF %s not exist", i, vfName))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("requested VFs not found: %s", strings.Join(missing, "; "))
+	}
+	return nil
+}
+
+// sriovNumVFsPath 返回PF的sriov_numvfs文件路径
+func sriovNumVFsPath(parent string) string {
+	return filepath.Join("/sys/class/net", parent, "device", "sriov_numvfs")
+}
+
+// sriovVFCounts 读取PF当前启用的VF数量和支持的最大VF数量
+func sriovVFCounts(parent string) (int, int, error) {
+	readCount := func(file string) (int, error) {
+		data, err := os.ReadFile(filepath.Join("/sys/class/net", parent, "device", file))
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(strings.TrimSpace(string(data)))
+	}
+
+	numVFs, err := readCount("sriov_numvfs")
+	if err != nil {
+		return 0, 0, fmt.Errorf("parent interface %s is not an SR-IOV physical function: %v", parent, err)
+	}
+	totalVFs, err := readCount("sriov_totalvfs")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read sriov_totalvfs of %s: %v", parent, err)
+	}
+	return numVFs, totalVFs, nil
+}
+
+// minVFs 返回满足所有端口VF索引所需启用的最少VF数量，不超过totalVFs
+func minVFs(ports []Port, totalVFs int) int {
+	n := 0
+	for _, port := range ports {
+		if port.VFIndex+1 > n {
+			n = port.VFIndex + 1
+		}
+	}
+	if totalVFs > 0 && n > totalVFs {
+		n = totalVFs
+	}
+	return n
+}
+
+// setLinkMTU 设置网络接口的MTU，MTU已一致时不做修改
+func setLinkMTU(name string, mtu int) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("failed to get link %s: %v", name, err)
+	}
+	if link.Attrs().MTU == mtu {
+		return nil
+	}
+	if err := netlink.LinkSetMTU(link, mtu); err != nil {
+		return fmt.Errorf("failed to set MTU %d on %s: %v", mtu, name, err)
+	}
+	logger.Printf("Set MTU of %s to %d", name, mtu)
+	return nil
+}
+
 // getVFPciAddress 通过父接口名和VF名获取VF的PCI地址
 func getVFPciAddress(parentIfName, vfName string) (string, error) {
 	// 获取VF网络接口
@@ -239,7 +896,10 @@ func getVFPciAddress(parentIfName, vfName string) (string, error) {
 
 	// 检查父接口是否是SR-IOV支持的设备
 	if parentLink.Type() != "device" {
-		return "", fmt.Errorf("parent interface is not a physical device")
+		return "", fmt.Errorf("parent interface %s is not a physical device (type %s)", parentIfName, parentLink.Type())
+	}
+	if _, err := os.Stat(sriovNumVFsPath(parentIfName)); err != nil {
+		return "", fmt.Errorf("parent interface %s is a device but not an SR-IOV physical function: %v", parentIfName, err)
 	}
 
 	// 获取VF的索引
@@ -251,11 +911,32 @@ func getVFPciAddress(parentIfName, vfName string) (string, error) {
 		return "", fmt.Errorf("failed to find VF PCI address: %v", err)
 	}
 
-	logger.Println(fmt.Sprintf("VF %s PCI Address: %s", vfName, pciAddress))
+	if err := checkVFPhysFn(parentIfName, vfName, pciAddress); err != nil {
+		return "", err
+	}
+
+	logger.Printf("VF %s PCI Address: %s", vfName, pciAddress)
 
 	return pciAddress, nil
 }
 
+// checkVFPhysFn 检查VF的physfn链接指向父接口的PCI设备，防止ParentInterface配置成了其他物理设备
+func checkVFPhysFn(parentIfName, vfName, vfPCI string) error {
+	parentDev, err := filepath.EvalSymlinks(filepath.Join("/sys/class/net", parentIfName, "device"))
+	if err != nil {
+		return fmt.Errorf("failed to resolve PCI device of parent interface %s: %v", parentIfName, err)
+	}
+	physfn, err := filepath.EvalSymlinks(filepath.Join(pciDevicesDir, vfPCI, "physfn"))
+	if err != nil {
+		return fmt.Errorf("%s (%s) is not an SR-IOV virtual function: %v", vfName, vfPCI, err)
+	}
+	if filepath.Base(physfn) != filepath.Base(parentDev) {
+		return fmt.Errorf("VF %s (%s) belongs to PF %s, not to parent interface %s (%s)",
+			vfName, vfPCI, filepath.Base(physfn), parentIfName, filepath.Base(parentDev))
+	}
+	return nil
+}
+
 // findVFPciAddress 通过sysfs查找VF的PCI地址
 func findVFPciAddress(parentIfName string, vfName string) (string, error) {
 	// 构建sysfs路径
@@ -263,7 +944,7 @@ func findVFPciAddress(parentIfName string, vfName string) (string, error) {
 
 	ifacePath := filepath.Join("/sys/class/net", vfName)
 	if _, err := os.Stat(ifacePath); os.IsNotExist(err) {
-		logger.Println(fmt.Sprintf("VF %s not exist", vfName))
+		logger.Printf("VF %s not exist", vfName)
 
 		return "", fmt.Errorf("VF %s not exist", vfName)
 	}
@@ -333,29 +1014,99 @@ func extractPCIFromUevent(ueventPath string) (string, error) {
 	return "", fmt.Errorf("PCI_SLOT_NAME not found in uevent file")
 }
 
-// setVFVlan 设置VF的VLAN ID
-func setVFVlan(parentIfName string, vfIndex int, vlanID int) error {
+// VF VLAN协议
+const (
+	vlanProto8021Q  = "802.1q"
+	vlanProto8021AD = "802.1ad"
+)
+
+// setVFVlan 按端口配置设置VF的VLAN及QoS优先级：VlanProto为802.1ad时由PF添加QinQ外层S-tag，未设置时保持802.1q。
+// 父接口为bond时在拥有该VF的成员PF上设置
+func setVFVlan(parentIfName string, port Port) error {
+	parentIfName, err := resolvePF(parentIfName, port.VFIndex)
+	if err != nil {
+		return err
+	}
+
 	// 获取父接口
 	parentLink, err := netlink.LinkByName(parentIfName)
 	if err != nil {
 		return fmt.Errorf("failed to get parent link: %v", err)
 	}
 
-	//// 获取VF网络接口
-	//vfLink, err := netlink.LinkByName(vfName)
-	//if err != nil {
-	//	return fmt.Errorf("failed to get VF link: %v", err)
-	//}
-	//
-	//// 获取VF索引
-	//vfIndex := vfLink.Attrs().Index
-
 	// 设置VF的VLAN
-	if err := netlink.LinkSetVfVlan(parentLink, vfIndex, vlanID); err != nil {
+	switch {
+	case port.VlanProto == vlanProto8021AD:
+		err = netlink.LinkSetVfVlanQosProto(parentLink, port.VFIndex, port.VlanId, port.VlanQos, int(netlink.VLAN_PROTOCOL_8021AD))
+		if err == syscall.EPROTONOSUPPORT || err == syscall.EOPNOTSUPP {
+			return fmt.Errorf("driver of %s does not support 802.1ad VLAN on VF %d: %v", parentIfName, port.VFIndex, err)
+		}
+	case port.VlanQos > 0:
+		err = netlink.LinkSetVfVlanQos(parentLink, port.VFIndex, port.VlanId, port.VlanQos)
+	default:
+		err = netlink.LinkSetVfVlan(parentLink, port.VFIndex, port.VlanId)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to set VF VLAN: %v", err)
 	}
 
-	logger.Println(fmt.Sprintf("Set VF %s VLAN ID: %d Success!", fmt.Sprintf("%sv%d", parentIfName, vfIndex), vlanID))
+	logger.Printf("Set VF %sv%d VLAN %s Success!", parentIfName, port.VFIndex, vlanTagStack(port))
+
+	return nil
+}
+
+// vlanTagStack 描述VF上设置的VLAN标签及QoS优先级，用于日志。QinQ内层标签不由VF添加，不包含在内
+func vlanTagStack(port Port) string {
+	if port.VlanQos > 0 {
+		p := port
+		p.VlanQos = 0
+		return fmt.Sprintf("%s qos %d", vlanTagStack(p), port.VlanQos)
+	}
+	switch {
+	case port.VlanId == 0:
+		return "untagged"
+	case port.VlanProto != vlanProto8021AD:
+		return fmt.Sprintf("802.1q %d", port.VlanId)
+	}
+	return fmt.Sprintf("802.1ad %d", port.VlanId)
+}
+
+// vfLinkStates 端口LinkState对应的VF管理链路状态
+var vfLinkStates = map[string]uint32{
+	"auto":    netlink.VF_LINK_STATE_AUTO,
+	"enable":  netlink.VF_LINK_STATE_ENABLE,
+	"disable": netlink.VF_LINK_STATE_DISABLE,
+}
 
+// setVFLinkState 设置VF的管理链路状态：auto跟随PF，enable强制up（VF绑定DPDK后PF仍看到链路up），disable强制down
+func setVFLinkState(parentIfName string, port Port) error {
+	parentIfName, err := resolvePF(parentIfName, port.VFIndex)
+	if err != nil {
+		return err
+	}
+	parentLink, err := netlink.LinkByName(parentIfName)
+	if err != nil {
+		return fmt.Errorf("failed to get parent link: %v", err)
+	}
+	if err := netlink.LinkSetVfState(parentLink, port.VFIndex, vfLinkStates[port.LinkState]); err != nil {
+		return fmt.Errorf("driver of %s rejected link state %s on VF %d: %v", parentIfName, port.LinkState, port.VFIndex, err)
+	}
 	return nil
 }
+
+// portVlanProto 返回端口配置的VLAN协议，未设置时为802.1q
+func portVlanProto(port Port) string {
+	if port.VlanProto == "" {
+		return vlanProto8021Q
+	}
+	return port.VlanProto
+}
+
+// vfVlanProto 返回内核上报的VF VLAN协议，netlink按网络字节序返回，未上报时视为802.1q
+func vfVlanProto(vf netlink.VfInfo) string {
+	proto := (vf.VlanProto>>8)&0xFF | (vf.VlanProto&0xFF)<<8
+	if netlink.VlanProtocol(proto) == netlink.VLAN_PROTOCOL_8021AD {
+		return vlanProto8021AD
+	}
+	return vlanProto8021Q
+}
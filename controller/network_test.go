@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+// addTestLink 在当前命名空间中创建并启用veth接口（对端为<name>p），按CIDR添加地址
+func addTestLink(name string, cidrs ...string) (netlink.Link, []*netlink.Addr, error) {
+	veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: name}, PeerName: name + "p"}
+	if err := netlink.LinkAdd(veth); err != nil {
+		return nil, nil, fmt.Errorf("failed to add veth %s: %w", name, err)
+	}
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return nil, nil, err
+	}
+	var addrs []*netlink.Addr
+	for _, cidr := range cidrs {
+		addr, err := netlink.ParseAddr(cidr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := netlink.AddrAdd(link, addr); err != nil {
+			return nil, nil, fmt.Errorf("failed to add %s to %s: %w", cidr, name, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return link, addrs, nil
+}
+
+func TestAddMgmtRoutesWithoutGateway(t *testing.T) {
+	withTestNetns(t, func() error {
+		link, addrs, err := addTestLink("mgmt0", "192.168.100.10/24")
+		if err != nil {
+			return err
+		}
+		spec := Spec{Routes: []Route{{Dst: "10.10.0.0/16", Gw: "192.168.100.254"}}}
+		if err := addMgmtRoutes(link, addrs, spec); err != nil {
+			return fmt.Errorf("addMgmtRoutes: %v", err)
+		}
+
+		routes, err := netlink.RouteList(link, netlink.FAMILY_V4)
+		if err != nil {
+			return err
+		}
+		var static bool
+		for _, r := range routes {
+			if r.Dst == nil || r.Dst.String() == "0.0.0.0/0" {
+				t.Errorf("unexpected default route %v without MgmtGateway", r)
+			}
+			if r.Dst != nil && r.Dst.String() == "10.10.0.0/16" && r.Gw.Equal(net.ParseIP("192.168.100.254")) {
+				static = true
+			}
+		}
+		if !static {
+			t.Errorf("static route 10.10.0.0/16 via 192.168.100.254 not found in %v", routes)
+		}
+		return nil
+	})
+}
+
+// requireVlanSupport 内核不支持VLAN接口时返回包装了EOPNOTSUPP的错误，使withTestNetns跳过测试
+func requireVlanSupport(parent netlink.Link) error {
+	probe := &netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Name: "vlanprobe", ParentIndex: parent.Attrs().Index}, VlanId: 1}
+	if err := netlink.LinkAdd(probe); err != nil {
+		return fmt.Errorf("failed to add VLAN link: %w", err)
+	}
+	return netlink.LinkDel(probe)
+}
+
+func TestCreateVlanLink(t *testing.T) {
+	withTestNetns(t, func() error {
+		// 父接口保持down，createVlanLink应将其启用
+		veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "ens1f0"}, PeerName: "ens1f0p"}
+		if err := netlink.LinkAdd(veth); err != nil {
+			return fmt.Errorf("failed to add veth: %w", err)
+		}
+		if err := netlink.LinkAdd(&netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: "br-test"}}); err != nil {
+			return fmt.Errorf("failed to add bridge: %w", err)
+		}
+		parent, err := netlink.LinkByName("ens1f0")
+		if err != nil {
+			return err
+		}
+		br, err := netlink.LinkByName("br-test")
+		if err != nil {
+			return err
+		}
+		if err := requireVlanSupport(parent); err != nil {
+			return err
+		}
+
+		tests := []struct {
+			name       string
+			parentName string // Spec.ParentInterface，为空时VLAN建在网桥上
+			vlanID     int
+			wantParent netlink.Link
+		}{
+			{name: "data0.100", parentName: "ens1f0", vlanID: 100, wantParent: parent},
+			{name: "data1.200", vlanID: 200, wantParent: br},
+		}
+		for _, tt := range tests {
+			config := TRExConfig{Spec: Spec{ParentInterface: tt.parentName, MTU: 1400}}
+			link, err := createVlanLink(config, tt.name, tt.vlanID, br)
+			if err != nil {
+				return fmt.Errorf("createVlanLink(%s): %v", tt.name, err)
+			}
+			vlan, ok := link.(*netlink.Vlan)
+			if !ok {
+				t.Errorf("%s is a %s link, want vlan", tt.name, link.Type())
+				continue
+			}
+			if vlan.VlanId != tt.vlanID {
+				t.Errorf("%s VLAN id = %d, want %d", tt.name, vlan.VlanId, tt.vlanID)
+			}
+			if vlan.ParentIndex != tt.wantParent.Attrs().Index {
+				t.Errorf("%s parent index = %d, want %d (%s)", tt.name, vlan.ParentIndex, tt.wantParent.Attrs().Index, tt.wantParent.Attrs().Name)
+			}
+			if vlan.MTU != 1400 {
+				t.Errorf("%s MTU = %d, want 1400", tt.name, vlan.MTU)
+			}
+
+			up, err := netlink.LinkByIndex(tt.wantParent.Attrs().Index)
+			if err != nil {
+				return err
+			}
+			if up.Attrs().Flags&net.FlagUp == 0 {
+				t.Errorf("VLAN parent %s was not brought up", up.Attrs().Name)
+			}
+		}
+
+		// 重新创建时清理同名的残留接口
+		if _, err := createVlanLink(TRExConfig{Spec: Spec{ParentInterface: "ens1f0"}}, "data0.100", 300, br); err != nil {
+			return fmt.Errorf("recreating data0.100: %v", err)
+		}
+		link, err := netlink.LinkByName("data0.100")
+		if err != nil {
+			return err
+		}
+		if vlan, ok := link.(*netlink.Vlan); !ok || vlan.VlanId != 300 {
+			t.Errorf("recreated data0.100 = %+v, want VLAN 300", link)
+		}
+		return nil
+	})
+}
+
+func TestSetLinkMAC(t *testing.T) {
+	withTestNetns(t, func() error {
+		link, _, err := addTestLink("mgmt0")
+		if err != nil {
+			return err
+		}
+		if err := netlink.LinkSetDown(link); err != nil {
+			return err
+		}
+		if err := setLinkMAC(link, "02:42:ac:11:00:10"); err != nil {
+			return fmt.Errorf("setLinkMAC: %v", err)
+		}
+		link, err = netlink.LinkByName("mgmt0")
+		if err != nil {
+			return err
+		}
+		if got := link.Attrs().HardwareAddr.String(); got != "02:42:ac:11:00:10" {
+			t.Errorf("MAC of mgmt0 = %s, want 02:42:ac:11:00:10", got)
+		}
+
+		// 内核拒绝多播地址，错误中应包含地址和接口名
+		err = setLinkMAC(link, "01:00:5e:00:00:01")
+		if err == nil || !strings.Contains(err.Error(), "01:00:5e:00:00:01 on mgmt0") {
+			t.Errorf("setLinkMAC(multicast) = %v, want an error naming the address and mgmt0", err)
+		}
+		if err := setLinkMAC(link, "02:42:ac"); err == nil || !strings.Contains(err.Error(), "invalid MAC address") {
+			t.Errorf("setLinkMAC(malformed) = %v, want invalid MAC address", err)
+		}
+		return nil
+	})
+}
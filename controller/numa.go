@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// pciNumaNode 读取PCI设备所在的NUMA节点，内核未提供（单节点系统为-1）时返回-1
+func pciNumaNode(pciAddr string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(pciDevicesDir, pciAddr, "numa_node"))
+	if err != nil {
+		return -1, fmt.Errorf("failed to read NUMA node of %s: %v", pciAddr, err)
+	}
+	node, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return -1, fmt.Errorf("invalid NUMA node of %s: %v", pciAddr, err)
+	}
+	return node, nil
+}
+
+// vfNumaNodes 返回每个端口VF（按VF接口名）所在的NUMA节点，通过PF的virtfn链接定位，VF绑定到vfio-pci后同样可用
+func vfNumaNodes(config TRExConfig) (map[string]int, error) {
+	parent := config.Spec.ParentInterface
+	nodes := make(map[string]int, len(config.Spec.Port))
+	for _, port := range config.Spec.Port {
+		pciAddr, err := vfPCIAddressByIndex(parent, port.VFIndex)
+		if err != nil {
+			return nil, err
+		}
+		node, err := pciNumaNode(pciAddr)
+		if err != nil {
+			return nil, err
+		}
+		nodes[vfIFName(parent, port.VFIndex)] = node
+	}
+	return nodes, nil
+}
+
+// commonNumaNode 返回所有VF共同所在的NUMA节点，节点未知时返回-1；spans表示VF位于多个节点
+func commonNumaNode(nodes map[string]int) (node int, spans bool) {
+	node = -1
+	for _, n := range nodes {
+		if n < 0 {
+			continue
+		}
+		if node >= 0 && n != node {
+			return -1, true
+		}
+		node = n
+	}
+	return node, false
+}
+
+// checkVFNumaNodes 检查VF所在的NUMA节点：开启AutoNuma时将NumaSocket设置为VF所在节点，
+// 否则在VF跨节点或与NumaSocket不一致时输出警告
+func checkVFNumaNodes(ctx context.Context, config *TRExConfig) (map[string]int, error) {
+	nodes, err := vfNumaNodes(*config)
+	if err != nil {
+		return nil, err
+	}
+
+	node, spans := commonNumaNode(nodes)
+	switch {
+	case spans:
+		logf(ctx, "Warning: VFs of %s span NUMA nodes %v, TREx performance will suffer", config.Metadata.Name, nodes)
+	case node < 0:
+		// 单节点系统或内核未提供NUMA信息
+	case config.Spec.AutoNuma:
+		if node != config.Spec.NumaSocket {
+			logf(ctx, "Selecting NUMA node %d from the VFs of %s", node, config.Metadata.Name)
+		}
+		config.Spec.NumaSocket = node
+	case node != config.Spec.NumaSocket:
+		logf(ctx, "Warning: VFs of %s are on NUMA node %d but spec.numaSocket is %d; set numaSocket or autoNuma", config.Metadata.Name, node, config.Spec.NumaSocket)
+	}
+	return nodes, nil
+}
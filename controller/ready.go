@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// capNetAdmin CAP_NET_ADMIN在能力位图中的位置
+const capNetAdmin = 12
+
+// startupReconciled 启动时的修复是否已完成，未开启后台修复时启动后立即置为true
+var startupReconciled atomic.Bool
+
+// readyHandler 返回控制器是否可以处理部署请求：启动修复已完成、Docker API版本已协商、进程拥有CAP_NET_ADMIN可以管理网桥
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	checks := make(map[string]string)
+	ready := true
+
+	if startupReconciled.Load() {
+		checks["startup"] = "ok"
+	} else {
+		checks["startup"] = "reconciling"
+		ready = false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if _, err := dockerClient.Ping(ctx); err != nil {
+		checks["docker"] = err.Error()
+		ready = false
+	} else {
+		// 开启版本协商时Ping会同时完成协商
+		checks["docker"] = "ok (API " + dockerClient.ClientVersion() + ")"
+	}
+
+	if ok, err := hasCapability(capNetAdmin); err != nil {
+		checks["netAdmin"] = err.Error()
+		ready = false
+	} else if !ok {
+		checks["netAdmin"] = "CAP_NET_ADMIN is missing, bridges and VFs cannot be managed"
+		ready = false
+	} else {
+		checks["netAdmin"] = "ok"
+	}
+
+	status := http.StatusOK
+	body := map[string]interface{}{"status": "ready", "checks": checks}
+	if !ready {
+		status = http.StatusServiceUnavailable
+		body["status"] = "not ready"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// hasCapability 读取/proc/self/status的CapEff判断进程是否拥有指定能力
+func hasCapability(bit uint) (bool, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return false, fmt.Errorf("failed to read process capabilities: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid CapEff %q: %v", strings.TrimSpace(value), err)
+		}
+		return caps&(1<<bit) != 0, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read process capabilities: %v", err)
+	}
+	return false, fmt.Errorf("CapEff not found in /proc/self/status")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/vishvananda/netlink"
+)
+
+// runReconcileLoop 按-reconcile-interval周期性修复部署的漂移
+func runReconcileLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reconcileAll(ctx)
+		}
+	}
+}
+
+// reconcileAll 对比状态记录与实际的Docker/netlink状态并修复漂移
+func reconcileAll(ctx context.Context) {
+	reconcileRunsTotal.Inc()
+
+	for name, record := range stateStore.List() {
+		// 正在被用户操作的部署跳过，下一轮再检查
+		lock := containerLocks.GetLock(name)
+		if !lock.TryLock() {
+			continue
+		}
+		replicas, err := replicaConfigs(record.Config)
+		if err != nil {
+			logger.Printf("Reconcile %s failed: %v", name, err)
+		}
+		for _, replica := range replicas {
+			if err := reconcileDeployment(ctx, replica); err != nil {
+				logger.Printf("Reconcile %s failed: %v", replica.Metadata.Name, err)
+			}
+		}
+		lock.Unlock()
+	}
+}
+
+// reconcileDeployment 修复单个部署：重启退出的工作容器、重建丢失的veth、重新设置被清除的VF VLAN。
+// NoPause部署的工作容器持有网络命名空间，退出后原地重启会丢失数据端口，只报告需要重新apply
+func reconcileDeployment(ctx context.Context, config TRExConfig) error {
+	name := config.Metadata.Name
+
+	workerID, pauseID, err := findDeploymentContainers(ctx, name)
+	if err != nil {
+		return err
+	}
+	netnsID := netnsContainerID(config, workerID, pauseID)
+	if workerID == "" || netnsID == "" {
+		return fmt.Errorf("containers of %s are missing, re-apply the deployment to recreate them", name)
+	}
+
+	netnsJSON, err := dockerClient.ContainerInspect(ctx, netnsID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %v", netnsID, err)
+	}
+	if !netnsJSON.State.Running && config.Spec.NoPause {
+		return fmt.Errorf("worker container of %s is %s and owned the network namespace, re-apply the deployment to recreate it", name, netnsJSON.State.Status)
+	}
+	if !netnsJSON.State.Running {
+		return fmt.Errorf("pause container of %s is not running, re-apply the deployment to recreate it", name)
+	}
+
+	// 重建丢失的host端veth
+	vethHost, _ := getPairName(name, netnsID)
+	if _, err := netlink.LinkByName(vethHost); err != nil {
+		logger.Printf("Reconcile %s: host veth %s missing, recreating", name, vethHost)
+		br, err := bridgeBackendFor(config.Spec.BridgeType).EnsureBridge(config.Spec.BrName, linkMTU(config.Spec), config.Spec.PromiscMode, config.Spec.VlanFiltering)
+		if err != nil {
+			return fmt.Errorf("failed to ensure bridge: %v", err)
+		}
+		if err := configureMgmtNetwork(ctx, config, netnsJSON.State.Pid, br, netnsID); err != nil {
+			return fmt.Errorf("failed to recreate mgmt network: %v", err)
+		}
+		publishEvent(ctx, eventRepaired, "reconcile", name, fmt.Sprintf("recreated host veth %s", vethHost))
+	}
+
+	// 重新设置被清除的VF VLAN
+	if config.Spec.NetworkType == "SRIOV" {
+		if err := reconcileVFVlans(config); err != nil {
+			return err
+		}
+	}
+
+	// pause容器存活时重启退出的工作容器
+	if !config.Spec.NoPause {
+		return restartExitedWorker(ctx, name, workerID)
+	}
+	return nil
+}
+
+// restartExitedWorker 重启已退出的工作容器
+func restartExitedWorker(ctx context.Context, name, workerID string) error {
+	workerJSON, err := dockerClient.ContainerInspect(ctx, workerID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect worker container: %v", err)
+	}
+	if !workerJSON.State.Running {
+		logger.Printf("Reconcile %s: worker container is %s, restarting", name, workerJSON.State.Status)
+		if err := dockerClient.ContainerStart(ctx, workerID, types.ContainerStartOptions{}); err != nil {
+			return fmt.Errorf("failed to restart worker container: %v", err)
+		}
+		publishEvent(ctx, eventRepaired, "reconcile", name, fmt.Sprintf("restarted worker container (was %s)", workerJSON.State.Status))
+	}
+	return nil
+}
+
+// reconcileVFVlans 检查每个VF的VLAN是否与配置一致，不一致时重新设置
+func reconcileVFVlans(config TRExConfig) error {
+	// 父接口为bond时各端口的VF可能属于不同的成员PF，按PF缓存VF信息
+	pfVFs := make(map[string]map[int]netlink.VfInfo)
+	for _, port := range config.Spec.Port {
+		pf, err := resolvePF(config.Spec.ParentInterface, port.VFIndex)
+		if err != nil {
+			return err
+		}
+		vfs, ok := pfVFs[pf]
+		if !ok {
+			parentLink, err := netlink.LinkByName(pf)
+			if err != nil {
+				return fmt.Errorf("failed to get parent link: %v", err)
+			}
+			vfs = make(map[int]netlink.VfInfo)
+			for _, vf := range parentLink.Attrs().Vfs {
+				vfs[vf.ID] = vf
+			}
+			pfVFs[pf] = vfs
+		}
+
+		if vf, ok := vfs[port.VFIndex]; ok && vf.Vlan == port.VlanId && vf.Qos == port.VlanQos && (port.VlanId == 0 || vfVlanProto(vf) == portVlanProto(port)) {
+			continue
+		}
+		logger.Printf("Reconcile %s: VF %d VLAN drifted, setting VLAN %s", config.Metadata.Name, port.VFIndex, vlanTagStack(port))
+		if err := setVFVlan(config.Spec.ParentInterface, port); err != nil {
+			return err
+		}
+		events.Publish(Event{Type: eventRepaired, Action: "reconcile", Name: config.Metadata.Name, Message: fmt.Sprintf("reset VF %d to VLAN %s", port.VFIndex, vlanTagStack(port))})
+	}
+	return nil
+}
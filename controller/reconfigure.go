@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// networkOnlyChange 判断新配置相对old是否只修改了可在线调整的网络字段：
+// 管理地址、MgmtGateway、Routes，SRIOV模式下端口的VlanId、VlanProto、VlanQos和LinkState（改为未设置除外），以及端口和Peer的IP/Gateway/DestMAC。
+// 使用ConfigTemplate时管理地址和端口地址会进入模板输出，只有VlanId和Routes可以在线调整
+func networkOnlyChange(old, desired TRExConfig) (bool, error) {
+	if len(old.Spec.Port) != len(desired.Spec.Port) {
+		return false, nil
+	}
+
+	masked := desired
+	masked.Spec.Routes = old.Spec.Routes
+	masked.Spec.Port = append([]Port(nil), desired.Spec.Port...)
+	for i := range masked.Spec.Port {
+		if desired.Spec.NetworkType == "SRIOV" {
+			masked.Spec.Port[i].VlanId = old.Spec.Port[i].VlanId
+			masked.Spec.Port[i].VlanProto = old.Spec.Port[i].VlanProto
+			masked.Spec.Port[i].VlanQos = old.Spec.Port[i].VlanQos
+			if desired.Spec.Port[i].LinkState != "" {
+				masked.Spec.Port[i].LinkState = old.Spec.Port[i].LinkState
+			}
+		}
+		// RawTrexConfig原样使用，端口地址不进入配置文件，不在线改写
+		if desired.Spec.ConfigTemplate == "" && desired.Spec.RawTrexConfig == "" {
+			masked.Spec.Port[i].IP = old.Spec.Port[i].IP
+			masked.Spec.Port[i].Gateway = old.Spec.Port[i].Gateway
+			masked.Spec.Port[i].DestMAC = old.Spec.Port[i].DestMAC
+			if peer, oldPeer := desired.Spec.Port[i].Peer, old.Spec.Port[i].Peer; peer != nil && oldPeer != nil {
+				masked.Spec.Port[i].Peer = &Peer{VFIndex: peer.VFIndex, IP: oldPeer.IP, Gateway: oldPeer.Gateway, DestMAC: oldPeer.DestMAC}
+			}
+		}
+	}
+	if desired.Spec.ConfigTemplate == "" {
+		masked.Spec.MgmtIP = old.Spec.MgmtIP
+		masked.Spec.MgmtIPs = old.Spec.MgmtIPs
+		masked.Spec.MgmtGateway = old.Spec.MgmtGateway
+	}
+	return configsEqual(old, masked)
+}
+
+// reconfigureTRExNetwork 在不重建容器的情况下将部署的网络配置从old调整为desired，调用方需持有该名称的锁
+func reconfigureTRExNetwork(ctx context.Context, old, desired TRExConfig) (*ActionResult, error) {
+	oldReplicas, err := replicaConfigs(old)
+	if err != nil {
+		return nil, err
+	}
+	replicas, err := replicaConfigs(desired)
+	if err != nil {
+		return nil, err
+	}
+	if len(oldReplicas) != len(replicas) {
+		return nil, fmt.Errorf("replica count changed from %d to %d", len(oldReplicas), len(replicas))
+	}
+
+	var lines []string
+	for i, replica := range replicas {
+		changes, err := reconfigureReplica(ctx, oldReplicas[i], replica)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reconfigure %s: %v", replica.Metadata.Name, err)
+		}
+		lines = append(lines, fmt.Sprintf("Container %s reconfigured in place: %s", replica.Metadata.Name, strings.Join(changes, ", ")))
+	}
+	return &ActionResult{Message: strings.Join(lines, "\n")}, nil
+}
+
+// reconfigureReplica 调整单个副本的VF VLAN、管理网地址和路由、端口转发及trex_cfg.yaml中的port_info，返回所做的修改
+func reconfigureReplica(ctx context.Context, old, desired TRExConfig) ([]string, error) {
+	name := desired.Metadata.Name
+	workerID, pauseID, err := findDeploymentContainers(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	netnsID := netnsContainerID(desired, workerID, pauseID)
+	if netnsID == "" {
+		return nil, fmt.Errorf("containers of %s not exist", name)
+	}
+	pid, err := getValidContainerPID(ctx, netnsID)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	var portInfoChanged []int
+	for i, port := range desired.Spec.Port {
+		prev := old.Spec.Port[i]
+		if port.VlanId != prev.VlanId || port.VlanProto != prev.VlanProto || port.VlanQos != prev.VlanQos {
+			if err := setVFVlan(desired.Spec.ParentInterface, port); err != nil {
+				return nil, fmt.Errorf("failed to set VLAN %s on VF %d: %v", vlanTagStack(port), port.VFIndex, err)
+			}
+			changes = append(changes, fmt.Sprintf("VF %d VLAN %s -> %s", port.VFIndex, vlanTagStack(prev), vlanTagStack(port)))
+		}
+		if port.LinkState != prev.LinkState && port.LinkState != "" {
+			if err := setVFLinkState(desired.Spec.ParentInterface, port); err != nil {
+				return nil, err
+			}
+			changes = append(changes, fmt.Sprintf("VF %d link state %s", port.VFIndex, port.LinkState))
+		}
+		if port.IP != prev.IP || port.Gateway != prev.Gateway || port.DestMAC != prev.DestMAC {
+			portInfoChanged = append(portInfoChanged, i)
+		}
+	}
+
+	addrsChanged := !reflect.DeepEqual(mgmtAddresses(old.Spec), mgmtAddresses(desired.Spec))
+	routesChanged := old.Spec.MgmtGateway != desired.Spec.MgmtGateway || !reflect.DeepEqual(old.Spec.Routes, desired.Spec.Routes)
+	if addrsChanged || routesChanged {
+		if err := reconfigureMgmtNetwork(old.Spec, desired.Spec, pid); err != nil {
+			return nil, err
+		}
+		gateway := desired.Spec.MgmtGateway
+		if gateway == "" {
+			gateway = "no default route"
+		}
+		changes = append(changes, fmt.Sprintf("mgmt %s via %s, %d routes", strings.Join(mgmtAddresses(desired.Spec), ","), gateway, len(desired.Spec.Routes)))
+	}
+
+	// 转发规则指向管理地址，地址变化后需要重建
+	if addrsChanged && len(desired.Spec.PublishPorts) > 0 {
+		removePortForwards(old)
+		if err := setupPortForwards(ctx, desired); err != nil {
+			return nil, err
+		}
+		changes = append(changes, "port forwards")
+	}
+
+	if len(portInfoChanged) > 0 {
+		if err := updatePortInfo(desired, portInfoChanged); err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("port_info of ports %v (effective on next TRex start)", portInfoChanged))
+	}
+
+	if len(changes) == 0 {
+		changes = append(changes, "no network changes")
+	}
+	logf(ctx, "Reconfigured %s in place: %s", name, strings.Join(changes, ", "))
+	return changes, nil
+}
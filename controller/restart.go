@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"trex-controller/pkg/api"
+)
+
+// RestartResult 描述一个副本重启后的工作容器，定义在pkg/api中
+type RestartResult = api.RestartResult
+
+// restartHandler 处理/restart/{name}：使用保存的配置重启部署各副本的工作容器，force=true时重建工作容器
+func restartHandler(w http.ResponseWriter, r *http.Request) {
+	const action = "restart"
+	requestsTotal.WithLabelValues(action).Inc()
+
+	if r.Method != "POST" {
+		recordFailure(action, http.StatusMethodNotAllowed)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/restart/")
+	if name == "" || strings.Contains(name, "/") {
+		recordFailure(action, http.StatusBadRequest)
+		http.Error(w, "Invalid deployment name", http.StatusBadRequest)
+		return
+	}
+	force := r.URL.Query().Get("force") == "true"
+
+	reqCtx, cancel := requestContext(r)
+	defer cancel()
+	ctx := withLogFields(reqCtx, action, name)
+
+	// 重建工作容器与部署一样占用并发部署名额
+	if force {
+		release, err := acquireDeploySlot()
+		if err != nil {
+			status := httpStatusFor(err)
+			recordFailure(action, status)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		defer release()
+	}
+
+	lock := containerLocks.GetLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	record, ok := stateStore.Get(name)
+	if !ok {
+		recordFailure(action, http.StatusNotFound)
+		http.Error(w, fmt.Sprintf("Deployment %s not exist", name), http.StatusNotFound)
+		return
+	}
+	// 原地重启持有网络命名空间的工作容器会丢失网络配置（见netnsContainerID）
+	if record.Config.Spec.NoPause && !force {
+		recordFailure(action, http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("Deployment %s has no pause container, restarting its worker in place would lose the network; use force=true to recreate it", name), http.StatusBadRequest)
+		return
+	}
+
+	logf(ctx, "Received %s request for container: %s (force: %v)", action, name, force)
+	publishEvent(ctx, eventStarted, action, name, "")
+	results, err := restartDeployment(ctx, record, force)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w after %s: %v", errDockerTimeout, *dockerTimeout, err)
+	}
+	if err != nil {
+		logf(ctx, "%s failed for %s: %v", action, name, err)
+		publishEvent(ctx, eventFailed, action, name, err.Error())
+		status := httpStatusFor(err)
+		recordFailure(action, status)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	var lines []string
+	for _, result := range results {
+		lines = append(lines, fmt.Sprintf("Container %s restarted with ID: %s (PID %d)", result.Name, result.ContainerID, result.PID))
+	}
+	publishEvent(ctx, eventSucceeded, action, name, strings.Join(lines, "\n"))
+	if force {
+		writeSummary(ctx, name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// restartDeployment 重启部署的全部副本，调用方需持有该名称的锁：
+// 默认对工作容器执行ContainerRestart；force时复用pause容器重建工作容器，NoPause部署则按保存的配置整体重建
+func restartDeployment(ctx context.Context, record DeploymentRecord, force bool) ([]RestartResult, error) {
+	config := record.Config
+	name := config.Metadata.Name
+
+	if force && config.Spec.NoPause {
+		if _, err := deleteTRExContainerLocked(ctx, config); err != nil {
+			return nil, err
+		}
+		created, err := createTRExContainerLocked(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		var results []RestartResult
+		for _, replica := range created.Replicas {
+			results = append(results, RestartResult{Name: replica.Name, ContainerID: replica.ContainerID, PID: replica.NetnsPID, Recreated: true})
+		}
+		return results, nil
+	}
+
+	replicas, err := replicaConfigs(config)
+	if err != nil {
+		return nil, err
+	}
+	var results []RestartResult
+	var ids []string
+	for _, replica := range replicas {
+		var result RestartResult
+		if force {
+			created, err := redeployWorker(ctx, replica)
+			if err != nil {
+				return nil, fmt.Errorf("failed to recreate worker of %s: %v", replica.Metadata.Name, err)
+			}
+			result = RestartResult{Name: replica.Metadata.Name, ContainerID: created.ContainerID, Recreated: true}
+		} else {
+			workerID, _, err := findDeploymentContainers(ctx, replica.Metadata.Name)
+			if err != nil {
+				return nil, err
+			}
+			if workerID == "" {
+				return nil, fmt.Errorf("worker container of %s not exist, use force=true to recreate it", replica.Metadata.Name)
+			}
+			logf(ctx, "Restarting worker container %s (ID: %s, timeout: %s)", replica.Metadata.Name, workerID, formatStopTimeout(stopTimeout(replica)))
+			if err := dockerClient.ContainerRestart(ctx, workerID, container.StopOptions{Timeout: stopTimeout(replica)}); err != nil {
+				return nil, fmt.Errorf("failed to restart worker container of %s: %v", replica.Metadata.Name, err)
+			}
+			result = RestartResult{Name: replica.Metadata.Name, ContainerID: workerID}
+		}
+
+		info, err := dockerClient.ContainerInspect(ctx, result.ContainerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect worker container of %s: %v", replica.Metadata.Name, err)
+		}
+		result.PID = info.State.Pid
+		results = append(results, result)
+		ids = append(ids, result.ContainerID)
+	}
+
+	if force {
+		record.WorkerContainerID = strings.Join(ids, ",")
+		if err := stateStore.Put(name, record); err != nil {
+			logf(ctx, "Warning: failed to save state for %s: %v", name, err)
+		}
+	}
+	return results, nil
+}
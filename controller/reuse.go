@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/vishvananda/netlink"
+)
+
+// isRecreate 判断请求是否要求完整重建部署，不复用pause容器也不在线调整网络
+func isRecreate(r *http.Request) bool {
+	return r.URL.Query().Get("recreate") == "true" || strings.EqualFold(r.Header.Get("X-Recreate"), "true")
+}
+
+// workerOnlyChange 判断新配置相对old是否只修改了只影响工作容器和trex_cfg.yaml的字段：
+// 镜像、挂载、环境变量、命令、大页、DNS、特权模式、核心数和NUMA、模板、Profile等。
+// 这些修改可以复用pause容器的网络命名空间，只重建工作容器；NoPause部署没有可复用的pause容器
+func workerOnlyChange(old, desired TRExConfig) (bool, error) {
+	if old.Spec.NoPause || desired.Spec.NoPause {
+		return false, nil
+	}
+
+	masked := desired
+	masked.Metadata.Image = old.Metadata.Image
+	spec, prev := &masked.Spec, old.Spec
+	spec.StopTimeout = prev.StopTimeout
+	spec.HugepagesPath = prev.HugepagesPath
+	spec.HugepagesTarget = prev.HugepagesTarget
+	spec.DisableHugepages = prev.DisableHugepages
+	spec.ConfigTarget = prev.ConfigTarget
+	spec.Mounts = prev.Mounts
+	spec.Env = prev.Env
+	spec.Command = prev.Command
+	spec.WorkingDir = prev.WorkingDir
+	spec.Cores = prev.Cores
+	spec.NumaSocket = prev.NumaSocket
+	spec.AutoNuma = prev.AutoNuma
+	spec.Privileged = prev.Privileged
+	spec.DNS = prev.DNS
+	spec.DNSSearch = prev.DNSSearch
+	spec.PullPolicy = prev.PullPolicy
+	spec.PortLimit = prev.PortLimit
+	spec.ConfigTemplate = prev.ConfigTemplate
+	spec.RawTrexConfig = prev.RawTrexConfig
+	spec.Profile = prev.Profile
+	spec.StartCommand = prev.StartCommand
+	spec.StopCommand = prev.StopCommand
+	return configsEqual(old, masked)
+}
+
+// reusablePause 检查副本现有的pause容器是否可以复用：容器在运行、网络命名空间可访问且管理网veth仍然存在。
+// 返回pause容器ID和PID
+func reusablePause(ctx context.Context, name string) (string, int, error) {
+	_, pauseID, err := findDeploymentContainers(ctx, name)
+	if err != nil {
+		return "", 0, err
+	}
+	if pauseID == "" {
+		return "", 0, fmt.Errorf("pause container of %s not exist", name)
+	}
+
+	pauseJSON, err := dockerClient.ContainerInspect(ctx, pauseID)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to inspect pause container of %s: %v", name, err)
+	}
+	if !pauseJSON.State.Running || pauseJSON.State.Restarting || pauseJSON.State.Pid == 0 {
+		return "", 0, fmt.Errorf("pause container of %s is %s", name, pauseJSON.State.Status)
+	}
+	pid := pauseJSON.State.Pid
+	if _, err := os.Stat(fmt.Sprintf("/proc/%d/ns/net", pid)); err != nil {
+		return "", 0, fmt.Errorf("network namespace of %s is not accessible: %v", name, err)
+	}
+
+	vethHost, _ := getPairName(name, pauseID)
+	if _, err := netlink.LinkByName(vethHost); err != nil {
+		return "", 0, fmt.Errorf("host veth %s of %s is missing", vethHost, name)
+	}
+	return pauseID, pid, nil
+}
+
+// netnsPortMap 返回已配置网络的副本中各数据端口的TRex接口，与首次配置网络时的结果一致：
+// SRIOV模式通过PF的virtfn链接获取VF的PCI地址（VF已移入网络命名空间），VETH模式为af_packet vdev
+func netnsPortMap(config TRExConfig) (map[string]string, error) {
+	ports := make(map[string]string)
+	for i, port := range config.Spec.Port {
+		if config.Spec.NetworkType == "VETH" {
+			ifName := dataPortIFName(port, i)
+			ports[ifName] = afPacketVdev(i, ifName)
+			continue
+		}
+		pciAddr, err := vfPCIAddressByIndex(config.Spec.ParentInterface, port.VFIndex)
+		if err != nil {
+			return nil, err
+		}
+		ports[vfIFName(config.Spec.ParentInterface, port.VFIndex)] = pciAddr
+	}
+	return ports, nil
+}
+
+// redeployWorker 复用副本现有的pause容器及其网络，重新生成trex_cfg.yaml并只重建工作容器
+func redeployWorker(ctx context.Context, config TRExConfig) (*ReplicaResult, error) {
+	name := config.Metadata.Name
+	pauseID, pid, err := reusablePause(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureImageExists(ctx, dockerClient, config.Metadata.Image, config.Spec.PullPolicy); err != nil {
+		return nil, fmt.Errorf("failed to ensure TREx image exists: %v", err)
+	}
+	var numaNodes map[string]int
+	if config.Spec.NetworkType == "SRIOV" {
+		if numaNodes, err = checkVFNumaNodes(ctx, &config); err != nil {
+			return nil, err
+		}
+	}
+	vfPCIMap, err := netnsPortMap(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// 删除旧的工作容器，pause容器持有的网络命名空间不受影响
+	workerID, _, err := findDeploymentContainers(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if workerID != "" {
+		logf(ctx, "Removing worker container %s of %s, keeping pause container %.12s", workerID, name, pauseID)
+		if err := dockerClient.ContainerStop(ctx, workerID, container.StopOptions{Timeout: stopTimeout(config)}); err != nil {
+			logf(ctx, "Warning: failed to stop container %s: %v", workerID, err)
+		}
+		if err := dockerClient.ContainerRemove(ctx, workerID, types.ContainerRemoveOptions{Force: true}); err != nil {
+			return nil, fmt.Errorf("failed to remove worker container of %s: %v", name, err)
+		}
+	}
+
+	configFilePath, err := createVFConfigFile(name, vfPCIMap, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create VF config file: %v", err)
+	}
+	newID, err := createWorkerContainer(ctx, config, pauseID, configFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	hostVeth, _ := getPairName(name, pauseID)
+	return &ReplicaResult{
+		Name:             name,
+		ContainerID:      newID,
+		PauseContainerID: pauseID,
+		NetnsPID:         pid,
+		BrName:           config.Spec.BrName,
+		HostVeth:         hostVeth,
+		ConfigFile:       configFilePath,
+		NetnsName:        netnsName(config),
+		VFPCIMap:         vfPCIMap,
+		VFNumaNodes:      numaNodes,
+	}, nil
+}
+
+// redeployTRExWorkers 对全部副本复用pause容器只重建工作容器，调用方需持有该名称的锁。
+// 任一副本的pause容器不可复用时不做任何修改并返回错误，由调用方回退到完整重建
+func redeployTRExWorkers(ctx context.Context, previous DeploymentRecord, config TRExConfig) (*ActionResult, error) {
+	name := config.Metadata.Name
+	replicas, err := replicaConfigs(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, replica := range replicas {
+		if _, _, err := reusablePause(ctx, replica.Metadata.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []ReplicaResult
+	var ids, lines []string
+	for _, replica := range replicas {
+		result, err := redeployWorker(ctx, replica)
+		if err != nil {
+			return nil, fmt.Errorf("failed to recreate worker of %s: %v", replica.Metadata.Name, err)
+		}
+		results = append(results, *result)
+		ids = append(ids, result.ContainerID)
+		lines = append(lines, fmt.Sprintf("Container %s recreated with ID: %s (reused pause container %.12s)", result.Name, result.ContainerID, result.PauseContainerID))
+	}
+
+	previous.Config = config
+	previous.WorkerContainerID = strings.Join(ids, ",")
+	if err := stateStore.Put(name, previous); err != nil {
+		logf(ctx, "Warning: failed to save state for %s: %v", name, err)
+	}
+	return &ActionResult{Message: strings.Join(lines, "\n"), Replicas: results}, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DeploymentRecord 记录一个已部署TREx实例的配置及运行状态
+type DeploymentRecord struct {
+	Config            TRExConfig `json:"config"`
+	WorkerContainerID string     `json:"workerContainerID"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
+}
+
+// StateStore 将部署记录持久化到本地文件
+type StateStore struct {
+	mu          sync.Mutex
+	path        string
+	Deployments map[string]DeploymentRecord `json:"deployments"`
+	Bridges     map[string]int              `json:"bridges"`
+	Drivers     map[string]string           `json:"drivers,omitempty"` // 重新绑定过驱动的VF PCI地址 -> 原驱动
+	Uplinks     map[string]string           `json:"uplinks,omitempty"` // 由控制器接入网桥的上联接口 -> 网桥
+}
+
+// NewStateStore 从指定路径加载状态，文件不存在时返回空状态
+func NewStateStore(path string) (*StateStore, error) {
+	s := &StateStore{
+		path:        path,
+		Deployments: make(map[string]DeploymentRecord),
+		Bridges:     make(map[string]int),
+		Drivers:     make(map[string]string),
+		Uplinks:     make(map[string]string),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %v", path, err)
+	}
+	if s.Deployments == nil {
+		s.Deployments = make(map[string]DeploymentRecord)
+	}
+	if s.Bridges == nil {
+		s.Bridges = make(map[string]int)
+	}
+	if s.Drivers == nil {
+		s.Drivers = make(map[string]string)
+	}
+	if s.Uplinks == nil {
+		s.Uplinks = make(map[string]string)
+	}
+	return s, nil
+}
+
+// Get 返回指定名称的部署记录
+func (s *StateStore) Get(name string) (DeploymentRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.Deployments[name]
+	return record, ok
+}
+
+// List 返回全部部署记录的副本
+func (s *StateStore) List() map[string]DeploymentRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make(map[string]DeploymentRecord, len(s.Deployments))
+	for name, record := range s.Deployments {
+		records[name] = record
+	}
+	return records
+}
+
+// Put 保存部署记录并持久化
+func (s *StateStore) Put(name string, record DeploymentRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record.UpdatedAt = time.Now()
+	s.Deployments[name] = record
+	return s.save()
+}
+
+// Delete 删除部署记录并持久化
+func (s *StateStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Deployments[name]; !ok {
+		return nil
+	}
+	delete(s.Deployments, name)
+	return s.save()
+}
+
+// AcquireBridge 增加网桥的引用计数并返回新的计数
+func (s *StateStore) AcquireBridge(name string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Bridges[name]++
+	return s.Bridges[name], s.save()
+}
+
+// ReleaseBridge 减少网桥的引用计数并返回剩余的计数
+func (s *StateStore) ReleaseBridge(name string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Bridges[name] <= 1 {
+		delete(s.Bridges, name)
+		return 0, s.save()
+	}
+	s.Bridges[name]--
+	return s.Bridges[name], s.save()
+}
+
+// RecordDriver 记录VF的原驱动，已有记录时保留最初的驱动
+func (s *StateStore) RecordDriver(pciAddr, driver string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Drivers[pciAddr]; ok {
+		return nil
+	}
+	s.Drivers[pciAddr] = driver
+	return s.save()
+}
+
+// Driver 返回VF记录的原驱动
+func (s *StateStore) Driver(pciAddr string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	driver, ok := s.Drivers[pciAddr]
+	return driver, ok
+}
+
+// ForgetDriver 删除VF的原驱动记录
+func (s *StateStore) ForgetDriver(pciAddr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Drivers[pciAddr]; !ok {
+		return nil
+	}
+	delete(s.Drivers, pciAddr)
+	return s.save()
+}
+
+// RecordUplink 记录由控制器接入网桥的上联接口
+func (s *StateStore) RecordUplink(uplink, brName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Uplinks[uplink] = brName
+	return s.save()
+}
+
+// UplinksOf 返回由控制器接入指定网桥的上联接口
+func (s *StateStore) UplinksOf(brName string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var uplinks []string
+	for uplink, br := range s.Uplinks {
+		if br == brName {
+			uplinks = append(uplinks, uplink)
+		}
+	}
+	return uplinks
+}
+
+// ForgetUplink 删除上联接口的记录
+func (s *StateStore) ForgetUplink(uplink string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Uplinks[uplink]; !ok {
+		return nil
+	}
+	delete(s.Uplinks, uplink)
+	return s.save()
+}
+
+// save 以临时文件+重命名的方式原子写入状态文件，调用方需持有锁
+func (s *StateStore) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	tmpFile := s.path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	if err := os.Rename(tmpFile, s.path); err != nil {
+		return fmt.Errorf("failed to rename state file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"trex-controller/pkg/api"
+)
+
+// 资源使用情况的结构定义在pkg/api中
+type (
+	NetStats              = api.NetStats
+	ContainerStatsSummary = api.ContainerStats
+)
+
+// statsHandler 返回部署各副本工作容器的CPU、内存和网络使用情况
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/stats/")
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "Invalid deployment name", http.StatusBadRequest)
+		return
+	}
+
+	record, ok := stateStore.Get(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Deployment %s not exist", name), http.StatusNotFound)
+		return
+	}
+
+	results := []ContainerStatsSummary{}
+	for _, replica := range replicaNames(record.Config) {
+		summary, err := containerStats(r.Context(), record.Config, replica)
+		if err != nil {
+			logger.Printf("Error getting stats of %s: %v", replica, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		results = append(results, *summary)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// containerStats 获取副本工作容器的资源使用情况。
+// 使用stream=false时Docker会等待第二次采样并填充precpu_stats，CPU使用率按两次采样的差值计算；
+// 工作容器共享pause容器的网络命名空间，网络计数从pause进程（NoPause时为工作容器）的/proc/<pid>/net/dev读取
+func containerStats(ctx context.Context, config TRExConfig, name string) (*ContainerStatsSummary, error) {
+	workerID, pauseID, err := findDeploymentContainers(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if workerID == "" {
+		return nil, fmt.Errorf("worker container of %s not exist", name)
+	}
+
+	resp, err := dockerClient.ContainerStats(ctx, workerID, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats of %s: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	var stats types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, fmt.Errorf("failed to decode stats of %s: %v", name, err)
+	}
+
+	summary := &ContainerStatsSummary{
+		Name:        name,
+		OnlineCPUs:  onlineCPUs(stats),
+		MemoryUsage: memoryUsage(stats.MemoryStats),
+		MemoryLimit: stats.MemoryStats.Limit,
+		Networks:    map[string]NetStats{},
+	}
+	summary.CPUPercent = cpuPercent(stats, summary.OnlineCPUs)
+	if summary.MemoryLimit > 0 {
+		summary.MemoryPercent = float64(summary.MemoryUsage) / float64(summary.MemoryLimit) * 100
+	}
+
+	netnsID := netnsContainerID(config, workerID, pauseID)
+	netnsJSON, err := dockerClient.ContainerInspect(ctx, netnsID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container %s: %v", netnsID, err)
+	}
+	if netnsJSON.State.Running {
+		if summary.Networks, err = netnsStats(netnsJSON.State.Pid); err != nil {
+			return nil, err
+		}
+	}
+	return summary, nil
+}
+
+// onlineCPUs 返回容器可用的CPU数，旧版本内核不提供online_cpus时使用percpu_usage的长度
+func onlineCPUs(stats types.StatsJSON) uint32 {
+	if stats.CPUStats.OnlineCPUs > 0 {
+		return stats.CPUStats.OnlineCPUs
+	}
+	return uint32(len(stats.CPUStats.CPUUsage.PercpuUsage))
+}
+
+// cpuPercent 按两次采样间容器CPU时间与系统CPU时间的增量计算使用率，与docker stats一致（单核满载为100%）
+func cpuPercent(stats types.StatsJSON, cpus uint32) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	return cpuDelta / systemDelta * float64(cpus) * 100
+}
+
+// memoryUsage 返回扣除页缓存后的内存使用量，与docker stats一致（cgroup v1为total_inactive_file，v2为inactive_file）
+func memoryUsage(mem types.MemoryStats) uint64 {
+	cache, ok := mem.Stats["total_inactive_file"]
+	if !ok {
+		cache = mem.Stats["inactive_file"]
+	}
+	if cache < mem.Usage {
+		return mem.Usage - cache
+	}
+	return mem.Usage
+}
+
+// netnsStats 读取进程所在网络命名空间中各接口（lo除外）的收发计数
+func netnsStats(pid int) (map[string]NetStats, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/net/dev", pid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read network stats: %v", err)
+	}
+	defer f.Close()
+
+	result := make(map[string]NetStats)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 格式为 "iface: rx_bytes rx_packets ... (8列) tx_bytes tx_packets ..."，前两行为表头
+		ifName, counters, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		ifName = strings.TrimSpace(ifName)
+		fields := strings.Fields(counters)
+		if ifName == "lo" || len(fields) < 10 {
+			continue
+		}
+		values := make([]uint64, 10)
+		for i := range values {
+			values[i], _ = strconv.ParseUint(fields[i], 10, 64)
+		}
+		result[ifName] = NetStats{
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			TxBytes:   values[8],
+			TxPackets: values[9],
+		}
+	}
+	return result, scanner.Err()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"trex-controller/pkg/api"
+)
+
+// 状态结构定义在pkg/api中
+type (
+	ContainerStatus  = api.ContainerStatus
+	PortStatus       = api.PortStatus
+	ReplicaStatus    = api.ReplicaStatus
+	DeploymentStatus = api.DeploymentStatus
+)
+
+// statusHandler 返回部署的详细状态，用于排查问题
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/status/")
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "Invalid deployment name", http.StatusBadRequest)
+		return
+	}
+
+	record, ok := stateStore.Get(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Deployment %s not exist", name), http.StatusNotFound)
+		return
+	}
+	replicas, err := replicaConfigs(record.Config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := DeploymentStatus{
+		Name:      name,
+		Image:     record.Config.Metadata.Image,
+		UpdatedAt: record.UpdatedAt,
+	}
+	for _, replica := range replicas {
+		rs, err := replicaStatus(r.Context(), replica)
+		if err != nil {
+			logger.Printf("Error getting status of %s: %v", replica.Metadata.Name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(rs.Problems) > 0 {
+			status.Degraded = true
+		}
+		status.Replicas = append(status.Replicas, *rs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// replicaStatus 检查副本的容器、管理网veth、配置文件及数据端口
+func replicaStatus(ctx context.Context, config TRExConfig) (*ReplicaStatus, error) {
+	name := config.Metadata.Name
+	workerID, pauseID, err := findDeploymentContainers(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := &ReplicaStatus{
+		Name:       name,
+		BrName:     config.Spec.BrName,
+		ConfigFile: trexConfigPath(name),
+	}
+
+	rs.Worker = containerStatus(ctx, workerID)
+	if rs.Worker.State != "running" {
+		rs.Problems = append(rs.Problems, fmt.Sprintf("worker container is %s%s", rs.Worker.State, exitCodeSuffix(rs.Worker)))
+	}
+	netnsID := netnsContainerID(config, workerID, pauseID)
+	if !config.Spec.NoPause {
+		pause := containerStatus(ctx, pauseID)
+		rs.Pause = &pause
+		if pause.State != "running" {
+			rs.Problems = append(rs.Problems, fmt.Sprintf("pause container is %s%s", pause.State, exitCodeSuffix(pause)))
+		}
+	}
+
+	if _, err := netlink.LinkByName(config.Spec.BrName); err != nil && config.Spec.BridgeType != bridgeTypeOVS {
+		rs.Problems = append(rs.Problems, fmt.Sprintf("bridge %s is missing", config.Spec.BrName))
+	}
+	for _, brName := range dataPortBridges(config.Spec) {
+		if _, err := netlink.LinkByName(brName); err != nil && config.Spec.BridgeType != bridgeTypeOVS {
+			rs.Problems = append(rs.Problems, fmt.Sprintf("data port bridge %s is missing", brName))
+		}
+	}
+	if netnsID != "" {
+		rs.HostVeth, _ = getPairName(name, netnsID)
+		if _, err := netlink.LinkByName(rs.HostVeth); err == nil {
+			rs.HostVethPresent = true
+		} else {
+			rs.Problems = append(rs.Problems, fmt.Sprintf("host veth %s is missing", rs.HostVeth))
+		}
+	}
+	if _, err := os.Stat(rs.ConfigFile); err != nil {
+		rs.Problems = append(rs.Problems, fmt.Sprintf("config file %s is missing", rs.ConfigFile))
+	}
+
+	for i, port := range config.Spec.Port {
+		ps := PortStatus{
+			VLAN:    vlanTagStack(port),
+			IP:      port.IP,
+			Gateway: port.Gateway,
+			DestMAC: port.DestMAC,
+		}
+		if config.Spec.NetworkType == "VETH" {
+			ps.Interface = dataPortIFName(port, i)
+			ps.Bridge = portBridgeName(config.Spec, port)
+			// VLAN子接口没有host端veth
+			if port.VlanId == 0 || config.Spec.VlanFiltering {
+				hostName, _ := getDataPortNames(name, i)
+				if _, err := netlink.LinkByName(hostName); err != nil {
+					rs.Problems = append(rs.Problems, fmt.Sprintf("data port veth %s is missing", hostName))
+				}
+			}
+		} else {
+			ps.Interface = vfIFName(config.Spec.ParentInterface, port.VFIndex)
+			if ps.PCI, err = vfPCIAddressByIndex(config.Spec.ParentInterface, port.VFIndex); err != nil {
+				rs.Problems = append(rs.Problems, fmt.Sprintf("VF %s: %v", ps.Interface, err))
+			}
+		}
+		rs.Ports = append(rs.Ports, ps)
+	}
+	return rs, nil
+}
+
+// containerStatus 返回容器的状态，ID为空或容器已被删除时为missing
+func containerStatus(ctx context.Context, id string) ContainerStatus {
+	if id == "" {
+		return ContainerStatus{State: "missing"}
+	}
+	info, err := dockerClient.ContainerInspect(ctx, id)
+	if err != nil {
+		return ContainerStatus{ID: id, State: "missing"}
+	}
+	return ContainerStatus{ID: id, State: info.State.Status, ExitCode: info.State.ExitCode}
+}
+
+func exitCodeSuffix(s ContainerStatus) string {
+	if s.State == "exited" {
+		return fmt.Sprintf(" (exit code %d)", s.ExitCode)
+	}
+	return ""
+}
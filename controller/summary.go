@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// summaryVersion 摘要文件格式的版本，字段只增不改，不兼容的修改需要增加版本
+const summaryVersion = 1
+
+// SummaryFile 开启-summary-dir时每次成功apply/update后写入<name>.json的部署摘要，
+// 是供资产管理等外部工具读取的稳定输出格式，与内部的状态文件相互独立
+type SummaryFile struct {
+	Version   int              `json:"version"`
+	Name      string           `json:"name"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+	Config    TRExConfig       `json:"config"` // 补全默认值后的部署配置
+	Replicas  []SummaryReplica `json:"replicas"`
+}
+
+// SummaryReplica 摘要中单个副本的容器和网络信息
+type SummaryReplica struct {
+	Name             string            `json:"name"`
+	ContainerID      string            `json:"containerID"`
+	PauseContainerID string            `json:"pauseContainerID,omitempty"` // NoPause时为空
+	BrName           string            `json:"brName"`
+	HostVeth         string            `json:"hostVeth"` // 管理网veth的host端接口名
+	MgmtIFName       string            `json:"mgmtIFName"`
+	ConfigFile       string            `json:"configFile"`
+	DataPorts        map[string]string `json:"dataPorts"` // 数据端口接口名到TRex接口（SRIOV为VF的PCI地址，VETH为af_packet vdev）
+}
+
+// summaryPath 返回部署摘要文件的路径
+func summaryPath(name string) string {
+	return filepath.Join(*summaryDir, name+".json")
+}
+
+// writeSummary 根据保存的配置和当前容器生成部署摘要并原子地写入-summary-dir，未开启时不做任何事
+func writeSummary(ctx context.Context, name string) {
+	if *summaryDir == "" {
+		return
+	}
+	record, ok := stateStore.Get(name)
+	if !ok {
+		return
+	}
+	summary, err := buildSummary(ctx, record)
+	if err == nil {
+		err = writeFileAtomic(summaryPath(name), summary)
+	}
+	if err != nil {
+		logf(ctx, "Warning: failed to write deploy summary of %s: %v", name, err)
+	}
+}
+
+// buildSummary 生成部署摘要的JSON内容
+func buildSummary(ctx context.Context, record DeploymentRecord) ([]byte, error) {
+	config := record.Config
+	replicas, err := replicaConfigs(config)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := SummaryFile{
+		Version:   summaryVersion,
+		Name:      config.Metadata.Name,
+		UpdatedAt: record.UpdatedAt,
+		Config:    config,
+		Replicas:  make([]SummaryReplica, 0, len(replicas)),
+	}
+	for _, replica := range replicas {
+		workerID, pauseID, err := findDeploymentContainers(ctx, replica.Metadata.Name)
+		if err != nil {
+			return nil, err
+		}
+		hostVeth, _ := getPairName(replica.Metadata.Name, pauseID)
+		ports, err := netnsPortMap(replica)
+		if err != nil {
+			return nil, err
+		}
+		summary.Replicas = append(summary.Replicas, SummaryReplica{
+			Name:             replica.Metadata.Name,
+			ContainerID:      workerID,
+			PauseContainerID: pauseID,
+			BrName:           replica.Spec.BrName,
+			HostVeth:         hostVeth,
+			MgmtIFName:       replica.Spec.MgmtIFName,
+			ConfigFile:       trexConfigPath(replica.Metadata.Name),
+			DataPorts:        ports,
+		})
+	}
+	return json.MarshalIndent(summary, "", "  ")
+}
+
+// removeSummary 删除部署的摘要文件，未开启-summary-dir或文件不存在时不做任何事
+func removeSummary(ctx context.Context, name string) {
+	if *summaryDir == "" {
+		return
+	}
+	if err := os.Remove(summaryPath(name)); err != nil && !os.IsNotExist(err) {
+		logf(ctx, "Warning: failed to remove deploy summary of %s: %v", name, err)
+	}
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再重命名，读取方不会看到写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", tmp.Name(), err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to chmod %s: %v", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %v", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename %s: %v", tmp.Name(), err)
+	}
+	return nil
+}
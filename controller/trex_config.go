@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/binary"
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
@@ -8,68 +9,122 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
+
+	"trex-controller/pkg/api"
 )
 
-type TrexPortConfig struct {
-	PortLimit  int      `yaml:"port_limit"`
-	Version    int      `yaml:"version"`
-	Interfaces []string `yaml:"interfaces"`
-	PortInfo   []struct {
-		ip             string `yaml:"ip"`
-		defaultGateway string `yaml:"default_gateway"`
-	} `yaml:"port_info"`
+type TrexPortInfo struct {
+	IP             string `yaml:"ip,omitempty"`
+	DefaultGateway string `yaml:"default_gw,omitempty"`
+	DestMAC        string `yaml:"dest_mac,omitempty"`
 }
 
-type TrexConfigFile struct {
-	TrexPortConfig []TrexPortConfig
+type TrexDualIf struct {
+	Socket  int   `yaml:"socket"`
+	Threads []int `yaml:"threads"`
 }
 
+type TrexPlatform struct {
+	MasterThreadID  int          `yaml:"master_thread_id"`
+	LatencyThreadID int          `yaml:"latency_thread_id"`
+	DualIf          []TrexDualIf `yaml:"dual_if"`
+}
+
+type TrexPortConfig struct {
+	PortLimit  int            `yaml:"port_limit"`
+	Version    int            `yaml:"version"`
+	Cores      int            `yaml:"c"`
+	Interfaces []string       `yaml:"interfaces"`
+	PortInfo   []TrexPortInfo `yaml:"port_info"`
+	Platform   TrexPlatform   `yaml:"platform"`
+}
+
+// TrexConfigFile trex_cfg.yaml的顶层为端口配置列表
+type TrexConfigFile []TrexPortConfig
+
 func createVFConfigFile(name string, vfPCIMap map[string]string, config TRExConfig) (string, error) {
 	// 转换映射格式
+	interfaces := len(portPairs(config.Spec)) * 2
+	portLimit := config.Spec.PortLimit
+	if portLimit == 0 {
+		portLimit = interfaces
+	}
 	trexPortConfig := TrexPortConfig{
-		PortLimit:  len(vfPCIMap) * 2,
+		PortLimit:  portLimit,
 		Version:    2,
-		Interfaces: make([]string, len(vfPCIMap)*2),
-		PortInfo: make([]struct {
-			ip             string `yaml:"ip"`
-			defaultGateway string `yaml:"default_gateway"`
-		}, len(vfPCIMap)*2),
+		Cores:      config.Spec.Cores,
+		Interfaces: make([]string, 0, interfaces),
+		PortInfo:   make([]TrexPortInfo, 0, interfaces),
+		Platform: TrexPlatform{
+			MasterThreadID:  0,
+			LatencyThreadID: 1,
+		},
 	}
 
+	var templatePorts []TrexTemplatePort
 	pName := config.Spec.ParentInterface
-	for i, port := range config.Spec.Port {
-		vfName := fmt.Sprintf("%sv%d", pName, port.VFIndex)
-		if pci, ok := vfPCIMap[vfName]; ok {
-			trexPortConfig.Interfaces = append(trexPortConfig.Interfaces, pci, "dummy")
-		} else {
-			return "", fmt.Errorf("failed to find VF PCI address for %s", vfName)
+	for k, pair := range portPairs(config.Spec) {
+		// 每个端口对使用独立的线程，0和1保留给master和latency线程
+		threads := make([]int, config.Spec.Cores)
+		for j := range threads {
+			threads[j] = 2 + k*config.Spec.Cores + j
 		}
 
-		var ip string
-		var gateway string
+		var pairPorts []TrexTemplatePort
+		var dummyInfo TrexPortInfo
+		for _, i := range pair {
+			if i < 0 {
+				// 未配对的端口与dummy组成端口对，使用该端口生成的dummy port_info
+				trexPortConfig.Interfaces = append(trexPortConfig.Interfaces, "dummy")
+				trexPortConfig.PortInfo = append(trexPortConfig.PortInfo, dummyInfo)
+				continue
+			}
+			port := config.Spec.Port[i]
+			vfName := vfIFName(pName, port.VFIndex)
+			if config.Spec.NetworkType == "VETH" {
+				vfName = dataPortIFName(port, i)
+			}
+			pci, ok := vfPCIMap[vfName]
+			if !ok {
+				return "", fmt.Errorf("failed to find VF PCI address for %s", vfName)
+			}
+			trexPortConfig.Interfaces = append(trexPortConfig.Interfaces, pci)
 
-		if port.IP != "" && port.Gateway != "" {
-			ip = port.IP
-			gateway = port.Gateway
-		} else {
-			ip, gateway = generateRandomIPWithGateway(i)
+			ip, gateway, dummyIP, infos, err := portInfos(port, i)
+			if err != nil {
+				return "", err
+			}
+			trexPortConfig.PortInfo = append(trexPortConfig.PortInfo, infos[0])
+			dummyInfo = infos[1]
+
+			pairPorts = append(pairPorts, TrexTemplatePort{
+				VFName:    vfName,
+				PCI:       pci,
+				IP:        ip,
+				Gateway:   gateway,
+				DummyIP:   dummyIP,
+				DestMAC:   port.DestMAC,
+				VlanId:    port.VlanId,
+				VlanProto: portVlanProto(port),
+				QinQ:      port.QinQ,
+				Threads:   threads,
+				PortIndex: i,
+			})
 		}
 
-		trexPortConfig.PortInfo = append(trexPortConfig.PortInfo, struct {
-			ip             string `yaml:"ip"`
-			defaultGateway string `yaml:"default_gateway"`
-		}{ip, gateway})
-
-		// this for dummy port
-		tmpIP := strings.Split(ip, "/")[0]
-		excludeIP := []net.IP{net.ParseIP(tmpIP), net.ParseIP(gateway)}
-		dummyIP, _ := generateRandomIP(ip, excludeIP)
-		trexPortConfig.PortInfo = append(trexPortConfig.PortInfo, struct {
-			ip             string `yaml:"ip"`
-			defaultGateway string `yaml:"default_gateway"`
-		}{dummyIP.String(), gateway})
+		socket := pairSocket(config, pairPorts)
+		trexPortConfig.Platform.DualIf = append(trexPortConfig.Platform.DualIf, TrexDualIf{
+			Socket:  socket,
+			Threads: threads,
+		})
+		for _, tp := range pairPorts {
+			tp.Socket = socket
+			templatePorts = append(templatePorts, tp)
+		}
 	}
 
 	//for vfName, pciAddr := range vfPCIMap {
@@ -82,25 +137,54 @@ func createVFConfigFile(name string, vfPCIMap map[string]string, config TRExConf
 	//	}{ip, gateway})
 	//}
 
-	vfConfigs := TrexConfigFile{
-		TrexPortConfig: []TrexPortConfig{trexPortConfig},
-	}
+	vfConfigs := TrexConfigFile{trexPortConfig}
 
-	logger.Println("Create trex_cfg.yaml for %s:%v", name, trexPortConfig)
+	logger.Printf("Create trex_cfg.yaml for %s: %+v", name, trexPortConfig)
 
-	// 转换为YAML格式
-	yamlData, err := yaml.Marshal(vfConfigs)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal VF config to YAML: %v", err)
+	var yamlData []byte
+	var err error
+	if config.Spec.RawTrexConfig != "" {
+		// 使用用户提供的配置原样挂载，仍记录解析出的接口以便核对
+		if yamlData, err = readRawTrexConfig(config.Spec.RawTrexConfig); err != nil {
+			return "", err
+		}
+		logger.Printf("Using raw trex_cfg.yaml for %s, resolved interfaces: %v", name, trexPortConfig.Interfaces)
+		if n, err := rawInterfaceCount(yamlData); err != nil {
+			logger.Printf("Warning: %v", err)
+		} else if n != len(trexPortConfig.Interfaces) {
+			logger.Printf("Warning: raw trex_cfg.yaml of %s lists %d interfaces but the deployment has %d (%d data ports)", name, n, len(trexPortConfig.Interfaces), len(config.Spec.Port))
+		}
+	} else if config.Spec.ConfigTemplate != "" {
+		// 使用用户提供的模板生成
+		yamlData, err = renderConfigTemplate(config.Spec.ConfigTemplate, TrexTemplateData{
+			Name:            name,
+			Ports:           templatePorts,
+			Cores:           config.Spec.Cores,
+			NumaSocket:      config.Spec.NumaSocket,
+			PortLimit:       trexPortConfig.PortLimit,
+			MasterThreadID:  trexPortConfig.Platform.MasterThreadID,
+			LatencyThreadID: trexPortConfig.Platform.LatencyThreadID,
+			MgmtIP:          config.Spec.MgmtIP,
+			MgmtGateway:     config.Spec.MgmtGateway,
+			Generated:       trexPortConfig,
+		})
+		if err != nil {
+			return "", err
+		}
+	} else {
+		// 转换为YAML格式
+		yamlData, err = yaml.Marshal(vfConfigs)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal VF config to YAML: %v", err)
+		}
 	}
 
-	// 创建临时文件
-	tmpDir := "/tmp/trex"
-	if err := os.MkdirAll(tmpDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %v", err)
+	// 创建配置文件
+	if err := os.MkdirAll(*configDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %v", err)
 	}
 
-	tmpFile := filepath.Join(tmpDir, fmt.Sprintf("%s_trex_cfg.yaml", name))
+	tmpFile := trexConfigPath(name)
 	if err := ioutil.WriteFile(tmpFile, yamlData, 0644); err != nil {
 		return "", fmt.Errorf("failed to write config file: %v", err)
 	}
@@ -108,68 +192,231 @@ func createVFConfigFile(name string, vfPCIMap map[string]string, config TRExConf
 	return tmpFile, nil
 }
 
+// dataPorts 返回部署实际使用的数据端口：设置了Peer的端口之后紧跟由Peer展开的端口，
+// 展开的端口继承所属端口的VLAN设置。结果中不再包含Peer，重复调用结果不变
+func dataPorts(spec Spec) []Port {
+	ports := make([]Port, 0, len(spec.Port))
+	for _, port := range spec.Port {
+		peer := port.Peer
+		port.Peer = nil
+		ports = append(ports, port)
+		if peer != nil {
+			ports = append(ports, Port{
+				VFIndex:   peer.VFIndex,
+				IP:        peer.IP,
+				Gateway:   peer.Gateway,
+				DestMAC:   peer.DestMAC,
+				VlanId:    port.VlanId,
+				VlanProto: port.VlanProto,
+				QinQ:      port.QinQ,
+				VlanQos:   port.VlanQos,
+				LinkState: port.LinkState,
+				Peered:    true,
+			})
+		}
+	}
+	return ports
+}
+
+// portPairs 返回trex_cfg.yaml中按顺序排列的端口对（dual_if），元素为端口下标，-1表示dummy端口：
+// 端口之后是由Peer展开的端口时二者配对，其余相邻的两个端口按顺序配对；
+// 只有一个端口、最后剩下一个端口或下一个端口已与其Peer配对时，该端口与dummy配对
+func portPairs(spec Spec) [][2]int {
+	var pairs [][2]int
+	peered := func(i int) bool { return i < len(spec.Port) && spec.Port[i].Peered }
+	for i := 0; i < len(spec.Port); i++ {
+		if i+1 < len(spec.Port) && (peered(i+1) || !peered(i+2)) {
+			pairs = append(pairs, [2]int{i, i + 1})
+			i++
+			continue
+		}
+		pairs = append(pairs, [2]int{i, -1})
+	}
+	return pairs
+}
+
+// pairSocket 返回端口对（dual_if）的socket：SRIOV部署取端口对中第一个VF的numa_node，
+// 同一端口对的VF位于不同节点时告警；非SRIOV部署或节点未知时使用NumaSocket
+func pairSocket(config TRExConfig, ports []TrexTemplatePort) int {
+	if config.Spec.NetworkType != "SRIOV" || len(ports) == 0 {
+		return config.Spec.NumaSocket
+	}
+	socket := -1
+	for _, port := range ports {
+		node, err := pciNumaNode(port.PCI)
+		if err != nil || node < 0 {
+			continue
+		}
+		if socket < 0 {
+			socket = node
+		} else if node != socket {
+			logger.Printf("Warning: VFs of a dual_if pair of %s are on NUMA nodes %d and %d, using socket %d", config.Metadata.Name, socket, node, socket)
+		}
+	}
+	if socket < 0 {
+		return config.Spec.NumaSocket
+	}
+	return socket
+}
+
+// portInfos 返回端口及其dummy端口的port_info：L2模式只设置dest_mac，dummy端口同样使用L2模式，避免混入伪造的IP；
+// 未配置IP/Gateway时使用生成的地址，dummy端口在同一子网内随机选择地址。port_info中的ip不带掩码，返回的ip保留掩码
+func portInfos(port Port, i int) (ip, gateway, dummyIP string, infos [2]TrexPortInfo, err error) {
+	if port.DestMAC != "" {
+		return "", "", "", [2]TrexPortInfo{{DestMAC: port.DestMAC}, {DestMAC: port.DestMAC}}, nil
+	}
+
+	if port.IP != "" && port.Gateway != "" {
+		ip = port.IP
+		gateway = port.Gateway
+	} else {
+		ip, gateway = generateRandomIPWithGateway(i)
+	}
+
+	// this for dummy port
+	tmpIP := strings.Split(ip, "/")[0]
+	excludeIP := []net.IP{net.ParseIP(tmpIP), net.ParseIP(gateway)}
+	dummy, err := generateRandomIP(ip, excludeIP)
+	if err != nil {
+		return "", "", "", infos, fmt.Errorf("port %d: %v", i, err)
+	}
+	dummyIP = dummy.String()
+	return ip, gateway, dummyIP, [2]TrexPortInfo{{IP: tmpIP, DefaultGateway: gateway}, {IP: dummyIP, DefaultGateway: gateway}}, nil
+}
+
+// updatePortInfo 原地改写trex_cfg.yaml中指定端口的port_info，其余内容保持不变；
+// 仅适用于未使用ConfigTemplate或RawTrexConfig生成的配置文件
+func updatePortInfo(config TRExConfig, ports []int) error {
+	path := trexConfigPath(config.Metadata.Name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	var file TrexConfigFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	pairs := portPairs(config.Spec)
+	if len(file) != 1 || len(file[0].PortInfo) != 2*len(pairs) {
+		return fmt.Errorf("config file %s does not match the deployment ports", path)
+	}
+
+	// port_info按端口对排列，与dummy配对的端口同时更新dummy的port_info
+	changed := make(map[int]bool)
+	for _, i := range ports {
+		changed[i] = true
+	}
+	for k, pair := range pairs {
+		for slot, i := range pair {
+			if i < 0 || !changed[i] {
+				continue
+			}
+			_, _, _, infos, err := portInfos(config.Spec.Port[i], i)
+			if err != nil {
+				return err
+			}
+			file[0].PortInfo[2*k+slot] = infos[0]
+			if pair[1] < 0 {
+				file[0].PortInfo[2*k+1] = infos[1]
+			}
+		}
+	}
+	yamlData, err := yaml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal VF config to YAML: %v", err)
+	}
+	// 原地写入，绑定挂载到容器内的文件随之更新
+	if err := os.WriteFile(path, yamlData, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
+
+// trexConfigPath 返回部署对应的trex_cfg.yaml路径
+func trexConfigPath(name string) string {
+	return filepath.Join(*configDir, name+configFileSuffix)
+}
+
 // generateRandomIPWithGateway 随机生成一个IP地址和对应的网关
 func generateRandomIPWithGateway(i int) (string, string) {
 	// 设置随机种子
 	return fmt.Sprintf("192.168.%d.%d/24", i, 10+i), fmt.Sprintf("192.168.%d.1", i)
 }
 
+// 包级随机数生成器，只初始化一次；rand.Rand不是并发安全的，需要加锁
+var (
+	rng   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	rngMu sync.Mutex
+)
+
+func randomUint32() uint32 {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	return rng.Uint32()
+}
+
+// generateRandomIP 在cidr所在的IPv4子网内随机选择一个主机地址，跳过网络地址、广播地址和excludeIP；
+// 从随机位置开始顺序查找，最多遍历一次子网，没有可用地址时返回错误（如/30中两个主机地址都已被占用，或/31、/32）
 func generateRandomIP(cidr string, excludeIP []net.IP) (net.IP, error) {
-	// 解析CIDR
 	_, ipNet, err := net.ParseCIDR(cidr)
 	if err != nil {
 		return nil, err
 	}
-
-	// 将IP转换为4字节格式
-
-	// 计算网络大小
-	ones, bits := ipNet.Mask.Size()
-	totalIPs := 1 << (bits - ones)
-	if totalIPs <= 1 {
-		return nil, fmt.Errorf("network too small to generate random IP")
+	base := ipNet.IP.To4()
+	if base == nil {
+		return nil, fmt.Errorf("%s is not an IPv4 network", cidr)
+	}
+	excluded := make([]net.IP, 0, len(excludeIP))
+	for _, eIP := range excludeIP {
+		if eIP = eIP.To4(); eIP == nil {
+			return nil, fmt.Errorf("excludeIP is not a valid IPv4 address")
+		}
+		excluded = append(excluded, eIP)
 	}
 
-	// 初始化随机数生成器
-	rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	// 生成随机IP
-	for {
-		// 随机生成一个主机地址
-		randomHost := rand.Uint32() % uint32(totalIPs)
-		ip := make(net.IP, 4)
-		ip[0] = ipNet.IP[0] + byte(randomHost>>24)
-		ip[1] = ipNet.IP[1] + byte(randomHost>>16)
-		ip[2] = ipNet.IP[2] + byte(randomHost>>8)
-		ip[3] = ipNet.IP[3] + byte(randomHost)
-
+	ones, bits := ipNet.Mask.Size()
+	totalIPs := uint32(1) << (bits - ones)
+	start := randomUint32() % totalIPs
+next:
+	for k := uint32(0); k < totalIPs; k++ {
+		host := (start + k) % totalIPs
 		// 跳过网络地址和广播地址
-		if randomHost == 0 || randomHost == uint32(totalIPs-1) {
+		if host == 0 || host == totalIPs-1 {
 			continue
 		}
-
-		// 跳过排除的IP
-		for _, eIP := range excludeIP {
-			eIP = eIP.To4()
-			if eIP == nil {
-				return nil, fmt.Errorf("excludeIP is not a valid IPv4 address")
-			}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(base)+host)
+		for _, eIP := range excluded {
 			if ip.Equal(eIP) {
-				continue
+				continue next
 			}
 		}
-
 		return ip, nil
 	}
+	return nil, fmt.Errorf("no free address left in %s for the dummy port", ipNet)
 }
 
-const brName = "trex-br0"
+const (
+	brName        = "trex-br0"
+	mgmtIFName    = "mgmt"
+	hugepagesPath = "/mnt/huge"
+	configTarget  = "/etc/trex_cfg.yaml"
+
+	defaultMTU = 1500
+	minMTU     = 68
+	maxMTU     = 65535
+)
 
 func LoadConfig(trexConfig *TRExConfig) error {
 	if trexConfig == nil {
 		return fmt.Errorf("trexConfig is nil, please configure trexConfig")
 	}
 
+	// 未设置apiVersion的配置按当前版本处理，不写回默认值，避免与已保存的配置比较时产生差异
+	if v := trexConfig.APIVersion; v != "" && !slices.Contains(api.SupportedAPIVersions, v) {
+		return fmt.Errorf("trexConfig.APIVersion %q is not supported, must be one of %s", v, strings.Join(api.SupportedAPIVersions, ", "))
+	}
+
 	if trexConfig.Metadata.Name == "" {
 		return fmt.Errorf("trexConfig.Metadata.Name is empty, please configure trexConfig.Metadata.Name")
 	}
@@ -177,26 +424,557 @@ func LoadConfig(trexConfig *TRExConfig) error {
 	if trexConfig.Metadata.Image == "" {
 		return fmt.Errorf("trexConfig.Metadata.Image is empty, please configure trexConfig.Metadata.Image")
 	}
+	image, err := normalizeImageRef(trexConfig.Metadata.Image)
+	if err != nil {
+		return fmt.Errorf("trexConfig.Metadata.Image: %v", err)
+	}
+	trexConfig.Metadata.Image = image
+
+	switch trexConfig.Spec.PullPolicy {
+	case "":
+		trexConfig.Spec.PullPolicy = pullIfNotPresent
+	case pullAlways, pullIfNotPresent, pullNever:
+	default:
+		return fmt.Errorf("trexConfig.Spec.PullPolicy %q is not supported, must be Always, IfNotPresent or Never", trexConfig.Spec.PullPolicy)
+	}
 
-	if trexConfig.Spec.MgmtIP == "" {
-		return fmt.Errorf("trexConfig.Spec.MgmtIP is empty, please configure trexConfig.Spec.MgmtIP")
+	if trexConfig.Spec.MgmtIP == "" && len(trexConfig.Spec.MgmtIPs) == 0 {
+		return fmt.Errorf("trexConfig.Spec.MgmtIP is empty, please configure trexConfig.Spec.MgmtIP or trexConfig.Spec.MgmtIPs")
 	}
 
-	if trexConfig.Spec.MgmtGateway == "" {
-		return fmt.Errorf("trexConfig.Spec.MgmtGateway is empty, please configure trexConfig.Spec.MgmtGateway")
+	// MgmtGateway为空时不添加默认路由（如没有网关的隔离实验网络），只有管理网段及Routes中的网段可达
+	if err := validateMgmtAddresses(mgmtAddresses(trexConfig.Spec), trexConfig.Spec.MgmtGateway); err != nil {
+		return err
+	}
+
+	if err := validateRoutes(trexConfig.Spec); err != nil {
+		return err
 	}
 
 	if len(trexConfig.Spec.Port) == 0 {
 		return fmt.Errorf("trexConfig.Spec.Port is empty, please configure trexConfig.Spec.Port")
 	}
 
+	if trexConfig.Spec.Replicas == 0 {
+		trexConfig.Spec.Replicas = 1
+	}
+	if trexConfig.Spec.Replicas < 0 {
+		return fmt.Errorf("trexConfig.Spec.Replicas %d must not be negative", trexConfig.Spec.Replicas)
+	}
+	if len(trexConfig.Spec.Port)%trexConfig.Spec.Replicas != 0 {
+		return fmt.Errorf("trexConfig.Spec.Port has %d ports, which cannot be split evenly across %d replicas", len(trexConfig.Spec.Port), trexConfig.Spec.Replicas)
+	}
+
 	if trexConfig.Spec.NetworkType == "" {
 		trexConfig.Spec.NetworkType = "SRIOV"
 	}
 
 	if trexConfig.Spec.BrName == "" {
-		trexConfig.Spec.BrName = brName
+		trexConfig.Spec.BrName = *defaultBridge
+	}
+	if !isValidIFName(trexConfig.Spec.BrName) {
+		return fmt.Errorf("trexConfig.Spec.BrName %q is not a valid bridge name", trexConfig.Spec.BrName)
+	}
+
+	switch trexConfig.Spec.BridgeType {
+	case "":
+		trexConfig.Spec.BridgeType = bridgeTypeLinux
+	case bridgeTypeLinux, bridgeTypeOVS:
+	default:
+		return fmt.Errorf("trexConfig.Spec.BridgeType %q is not supported, must be %s or %s", trexConfig.Spec.BridgeType, bridgeTypeLinux, bridgeTypeOVS)
+	}
+
+	if trexConfig.Spec.Uplink != "" && !isValidIFName(trexConfig.Spec.Uplink) {
+		return fmt.Errorf("trexConfig.Spec.Uplink %q is not a valid interface name", trexConfig.Spec.Uplink)
+	}
+
+	if trexConfig.Spec.MTU != 0 && (trexConfig.Spec.MTU < minMTU || trexConfig.Spec.MTU > maxMTU) {
+		return fmt.Errorf("trexConfig.Spec.MTU %d must be between %d and %d", trexConfig.Spec.MTU, minMTU, maxMTU)
+	}
+
+	if trexConfig.Spec.TxQLen != nil && *trexConfig.Spec.TxQLen < 0 {
+		return fmt.Errorf("trexConfig.Spec.TxQLen %d must not be negative", *trexConfig.Spec.TxQLen)
+	}
+
+	if trexConfig.Spec.Cores == 0 {
+		trexConfig.Spec.Cores = 1
+	}
+	if trexConfig.Spec.Cores < 0 {
+		return fmt.Errorf("trexConfig.Spec.Cores %d must not be negative", trexConfig.Spec.Cores)
+	}
+	if trexConfig.Spec.NumaSocket < 0 {
+		return fmt.Errorf("trexConfig.Spec.NumaSocket %d must not be negative", trexConfig.Spec.NumaSocket)
+	}
+	if trexConfig.Spec.NoPause && trexConfig.Spec.DriverBind != "" && trexConfig.Spec.Privileged != nil && !*trexConfig.Spec.Privileged {
+		// NoPause时工作容器先于VF绑定vfio-pci创建，此时VFIO组设备还不存在
+		return fmt.Errorf("trexConfig.Spec.NoPause with trexConfig.Spec.DriverBind requires a privileged worker")
+	}
+	if trexConfig.Spec.AutoNuma && trexConfig.Spec.NetworkType != "SRIOV" {
+		return fmt.Errorf("trexConfig.Spec.AutoNuma is only supported with the SRIOV network type")
+	}
+	replicaSpec := trexConfig.Spec
+	replicaSpec.Port = trexConfig.Spec.Port[:len(trexConfig.Spec.Port)/trexConfig.Spec.Replicas]
+	replicaSpec.Port = dataPorts(replicaSpec)
+	if interfaces := len(portPairs(replicaSpec)) * 2; trexConfig.Spec.PortLimit < 0 || trexConfig.Spec.PortLimit%2 != 0 || trexConfig.Spec.PortLimit > interfaces {
+		return fmt.Errorf("trexConfig.Spec.PortLimit %d must be an even number not greater than the %d interfaces of each replica", trexConfig.Spec.PortLimit, interfaces)
+	}
+
+	if trexConfig.Spec.HugepagesPath == "" {
+		trexConfig.Spec.HugepagesPath = hugepagesPath
+	}
+	if trexConfig.Spec.HugepagesTarget == "" {
+		trexConfig.Spec.HugepagesTarget = trexConfig.Spec.HugepagesPath
+	}
+	if trexConfig.Spec.ConfigTarget == "" {
+		trexConfig.Spec.ConfigTarget = configTarget
+	}
+	for field, path := range map[string]string{
+		"HugepagesPath":   trexConfig.Spec.HugepagesPath,
+		"HugepagesTarget": trexConfig.Spec.HugepagesTarget,
+		"ConfigTarget":    trexConfig.Spec.ConfigTarget,
+	} {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("trexConfig.Spec.%s %q must be an absolute path", field, path)
+		}
+	}
+	if !trexConfig.Spec.DisableHugepages && trexConfig.Spec.ConfigTarget == trexConfig.Spec.HugepagesTarget {
+		return fmt.Errorf("trexConfig.Spec.ConfigTarget and trexConfig.Spec.HugepagesTarget must be different paths")
+	}
+
+	if trexConfig.Spec.MgmtIFName == "" {
+		trexConfig.Spec.MgmtIFName = mgmtIFName
+	}
+	if !isValidIFName(trexConfig.Spec.MgmtIFName) {
+		return fmt.Errorf("trexConfig.Spec.MgmtIFName %q is not a valid interface name", trexConfig.Spec.MgmtIFName)
+	}
+	if mac := trexConfig.Spec.MgmtMAC; mac != "" {
+		hw, err := net.ParseMAC(mac)
+		if err != nil || len(hw) != 6 {
+			return fmt.Errorf("trexConfig.Spec.MgmtMAC %q is not a valid MAC address", mac)
+		}
+		if hw[0]&1 != 0 {
+			return fmt.Errorf("trexConfig.Spec.MgmtMAC %q is a multicast address", mac)
+		}
+		if _, err := offsetMAC(mac, trexConfig.Spec.Replicas-1); err != nil {
+			return err
+		}
+	}
+
+	if err := validatePorts(trexConfig.Spec); err != nil {
+		return err
+	}
+
+	for i, m := range trexConfig.Spec.Mounts {
+		if !filepath.IsAbs(m.Source) || !filepath.IsAbs(m.Target) {
+			return fmt.Errorf("trexConfig.Spec.Mounts[%d] source and target must be absolute paths", i)
+		}
+	}
+
+	if err := validatePublishPorts(trexConfig.Spec); err != nil {
+		return err
+	}
+	if err := validateDNS(trexConfig.Spec); err != nil {
+		return err
+	}
+	if err := validateHosts(trexConfig.Spec); err != nil {
+		return err
+	}
+
+	if p := trexConfig.Spec.Profile; p != "" {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("trexConfig.Spec.Profile %q must be an absolute path", p)
+		}
+		switch filepath.Ext(p) {
+		case ".py", ".yaml", ".yml":
+		default:
+			return fmt.Errorf("trexConfig.Spec.Profile %q must be a .py or .yaml file", p)
+		}
+	}
+
+	switch trexConfig.Spec.RestartPolicy {
+	case "":
+		trexConfig.Spec.RestartPolicy = "no"
+	case "no", "on-failure", "always", "unless-stopped":
+	default:
+		return fmt.Errorf("trexConfig.Spec.RestartPolicy %q is not supported, must be no, on-failure, always or unless-stopped", trexConfig.Spec.RestartPolicy)
+	}
+	if trexConfig.Spec.NoPause && trexConfig.Spec.RestartPolicy != "no" {
+		// 工作容器持有网络命名空间，重启后命名空间重建，管理网和数据端口都会丢失
+		return fmt.Errorf("trexConfig.Spec.NoPause requires the no restart policy, a restarted worker loses its network namespace")
+	}
+	if trexConfig.Spec.RestartMaxRetries < 0 {
+		return fmt.Errorf("trexConfig.Spec.RestartMaxRetries %d must not be negative", trexConfig.Spec.RestartMaxRetries)
+	}
+	if trexConfig.Spec.RestartMaxRetries > 0 && trexConfig.Spec.RestartPolicy != "on-failure" {
+		return fmt.Errorf("trexConfig.Spec.RestartMaxRetries is only valid with the on-failure restart policy")
+	}
+
+	if trexConfig.Spec.WorkingDir != "" && !filepath.IsAbs(trexConfig.Spec.WorkingDir) {
+		return fmt.Errorf("trexConfig.Spec.WorkingDir %q must be an absolute path", trexConfig.Spec.WorkingDir)
+	}
+
+	for i, env := range trexConfig.Spec.Env {
+		if !strings.Contains(env, "=") || strings.HasPrefix(env, "=") {
+			return fmt.Errorf("trexConfig.Spec.Env[%d] %q must be in KEY=VALUE format", i, env)
+		}
+	}
+
+	if trexConfig.Spec.ConfigTemplate != "" {
+		if _, err := parseConfigTemplate(trexConfig.Spec.ConfigTemplate); err != nil {
+			return fmt.Errorf("trexConfig.Spec.ConfigTemplate is invalid: %v", err)
+		}
+	}
+	if trexConfig.Spec.RawTrexConfig != "" {
+		if trexConfig.Spec.ConfigTemplate != "" {
+			return fmt.Errorf("trexConfig.Spec.RawTrexConfig and trexConfig.Spec.ConfigTemplate cannot be used together")
+		}
+		content, err := readRawTrexConfig(trexConfig.Spec.RawTrexConfig)
+		if err != nil {
+			return fmt.Errorf("trexConfig.Spec.RawTrexConfig is invalid: %v", err)
+		}
+		var v interface{}
+		if err := yaml.Unmarshal(content, &v); err != nil {
+			return fmt.Errorf("trexConfig.Spec.RawTrexConfig is not valid YAML: %v", err)
+		}
+	}
+
+	switch trexConfig.Spec.DriverBind {
+	case "":
+	case driverVfioPCI:
+		if trexConfig.Spec.NetworkType != "SRIOV" {
+			return fmt.Errorf("trexConfig.Spec.DriverBind is only supported with SRIOV network type")
+		}
+		if !*allowDriverBind {
+			return fmt.Errorf("trexConfig.Spec.DriverBind requires the controller to be started with -allow-driver-bind")
+		}
+	default:
+		return fmt.Errorf("trexConfig.Spec.DriverBind %q is not supported, only %s is allowed", trexConfig.Spec.DriverBind, driverVfioPCI)
 	}
 
 	return nil
 }
+
+// linkMTU 返回网桥及veth使用的MTU，未配置时为1500
+func linkMTU(spec Spec) int {
+	if spec.MTU == 0 {
+		return defaultMTU
+	}
+	return spec.MTU
+}
+
+// validatePorts 校验端口列表：VFIndex不能为负数，VlanId须在0-4094之间，SRIOV模式下VFIndex（包括Peer）不能重复，IFName不能重复，DestMAC须合法且与IP/Gateway互斥
+func validatePorts(spec Spec) error {
+	vfIndexes := make(map[int]string)
+	ifNames := make(map[string]int)
+	for i, port := range spec.Port {
+		if port.VFIndex < 0 {
+			return fmt.Errorf("trexConfig.Spec.Port[%d].VFIndex %d must not be negative", i, port.VFIndex)
+		}
+		if spec.NetworkType == "SRIOV" {
+			field := fmt.Sprintf("trexConfig.Spec.Port[%d]", i)
+			if other, ok := vfIndexes[port.VFIndex]; ok {
+				return fmt.Errorf("%s and %s use the same VFIndex %d", other, field, port.VFIndex)
+			}
+			vfIndexes[port.VFIndex] = field
+		}
+		if port.Peer != nil {
+			if err := validatePeer(spec, i, vfIndexes); err != nil {
+				return err
+			}
+		}
+		if port.VlanId < 0 || port.VlanId > 4094 {
+			return fmt.Errorf("trexConfig.Spec.Port[%d].VlanId %d must be in 1-4094 (0 means untagged)", i, port.VlanId)
+		}
+		switch port.VlanProto {
+		case "", vlanProto8021Q:
+		case vlanProto8021AD:
+			if spec.NetworkType != "SRIOV" || port.VlanId == 0 {
+				return fmt.Errorf("trexConfig.Spec.Port[%d].VlanProto 802.1ad requires a SRIOV port with VlanId set", i)
+			}
+		default:
+			return fmt.Errorf("trexConfig.Spec.Port[%d].VlanProto %q is not supported, must be 802.1q or 802.1ad", i, port.VlanProto)
+		}
+		if port.VlanQos < 0 || port.VlanQos > 7 {
+			return fmt.Errorf("trexConfig.Spec.Port[%d].VlanQos %d must be in 0-7", i, port.VlanQos)
+		}
+		if port.VlanQos > 0 && (spec.NetworkType != "SRIOV" || port.VlanId == 0) {
+			return fmt.Errorf("trexConfig.Spec.Port[%d].VlanQos requires a SRIOV port with VlanId set, the priority bits are carried in the VLAN tag", i)
+		}
+		if port.LinkState != "" {
+			if _, ok := vfLinkStates[port.LinkState]; !ok {
+				return fmt.Errorf("trexConfig.Spec.Port[%d].LinkState %q is not supported, must be auto, enable or disable", i, port.LinkState)
+			}
+			if spec.NetworkType != "SRIOV" {
+				return fmt.Errorf("trexConfig.Spec.Port[%d].LinkState requires SRIOV mode", i)
+			}
+		}
+		if port.QinQ != 0 {
+			if port.QinQ < 1 || port.QinQ > 4094 {
+				return fmt.Errorf("trexConfig.Spec.Port[%d].QinQ %d must be in 1-4094", i, port.QinQ)
+			}
+			if port.VlanProto != vlanProto8021AD {
+				return fmt.Errorf("trexConfig.Spec.Port[%d].QinQ requires VlanProto 802.1ad", i)
+			}
+			// VF只添加外层标签，内层标签只传递给配置模板，没有模板时不会生效
+			if spec.ConfigTemplate == "" {
+				return fmt.Errorf("trexConfig.Spec.Port[%d].QinQ requires ConfigTemplate, the inner tag is only passed to the template", i)
+			}
+		}
+		if port.Hairpin && (spec.NetworkType != "VETH" || (port.VlanId > 0 && !spec.VlanFiltering)) {
+			return fmt.Errorf("trexConfig.Spec.Port[%d].Hairpin requires a VETH data port attached to the bridge", i)
+		}
+		if port.Bridge != "" {
+			if spec.NetworkType != "VETH" {
+				return fmt.Errorf("trexConfig.Spec.Port[%d].Bridge requires VETH mode", i)
+			}
+			if !isValidIFName(port.Bridge) {
+				return fmt.Errorf("trexConfig.Spec.Port[%d].Bridge %q is not a valid bridge name", i, port.Bridge)
+			}
+			if port.Bridge == spec.Uplink {
+				return fmt.Errorf("trexConfig.Spec.Port[%d].Bridge %q is the uplink interface", i, port.Bridge)
+			}
+		}
+		if spec.NetworkType == "VETH" {
+			ifName := dataPortIFName(port, i)
+			if !isValidIFName(ifName) || ifName == spec.MgmtIFName {
+				return fmt.Errorf("trexConfig.Spec.Port[%d].IFName %q is not a valid data interface name", i, ifName)
+			}
+		}
+		if port.DestMAC != "" {
+			if _, err := net.ParseMAC(port.DestMAC); err != nil {
+				return fmt.Errorf("trexConfig.Spec.Port[%d].DestMAC %q is not a valid MAC address", i, port.DestMAC)
+			}
+			if port.IP != "" || port.Gateway != "" {
+				return fmt.Errorf("trexConfig.Spec.Port[%d] must set either DestMAC or IP/Gateway, not both", i)
+			}
+		}
+		if port.IFName != "" {
+			if j, ok := ifNames[port.IFName]; ok {
+				return fmt.Errorf("trexConfig.Spec.Port[%d] and trexConfig.Spec.Port[%d] use the same IFName %s", j, i, port.IFName)
+			}
+			ifNames[port.IFName] = i
+		}
+	}
+	return nil
+}
+
+// validatePeer 校验第i个端口的Peer：只支持SRIOV模式，
+// VFIndex不能为负数且不能与其他端口重复，寻址方式（DestMAC或IP/Gateway）须与所属端口一致
+func validatePeer(spec Spec, i int, vfIndexes map[int]string) error {
+	port, peer := spec.Port[i], spec.Port[i].Peer
+	field := fmt.Sprintf("trexConfig.Spec.Port[%d].Peer", i)
+	if spec.NetworkType != "SRIOV" {
+		return fmt.Errorf("%s requires SRIOV mode", field)
+	}
+	if peer.VFIndex < 0 {
+		return fmt.Errorf("%s.VFIndex %d must not be negative", field, peer.VFIndex)
+	}
+	if other, ok := vfIndexes[peer.VFIndex]; ok {
+		return fmt.Errorf("%s and %s use the same VFIndex %d", other, field, peer.VFIndex)
+	}
+	vfIndexes[peer.VFIndex] = field
+	if peer.DestMAC != "" {
+		if _, err := net.ParseMAC(peer.DestMAC); err != nil {
+			return fmt.Errorf("%s.DestMAC %q is not a valid MAC address", field, peer.DestMAC)
+		}
+		if peer.IP != "" || peer.Gateway != "" {
+			return fmt.Errorf("%s must set either DestMAC or IP/Gateway, not both", field)
+		}
+	}
+	if (port.DestMAC != "") != (peer.DestMAC != "") {
+		return fmt.Errorf("%s must use the same addressing as its port: DestMAC in L2 mode, IP/Gateway otherwise", field)
+	}
+	return nil
+}
+
+// isValidIFName 校验网卡名称：不超过15个字符，且不包含'/'、':'和空白字符
+func isValidIFName(name string) bool {
+	if len(name) == 0 || len(name) > 15 || name == "." || name == ".." {
+		return false
+	}
+	return !strings.ContainsAny(name, "/: \t\n")
+}
+
+// mgmtAddresses 返回管理接口的全部地址（MgmtIP在前），未带掩码的地址补全为主机掩码（IPv4为/32，IPv6为/128）
+func mgmtAddresses(spec Spec) []string {
+	var addrs []string
+	for _, ip := range append([]string{spec.MgmtIP}, spec.MgmtIPs...) {
+		if ip == "" {
+			continue
+		}
+		if !strings.Contains(ip, "/") {
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+				ip = fmt.Sprintf("%s/128", ip)
+			} else {
+				ip = fmt.Sprintf("%s/32", ip)
+			}
+		}
+		addrs = append(addrs, ip)
+	}
+	return addrs
+}
+
+// validateMgmtAddresses 校验管理地址及网关，网关必须位于某个同族地址的子网内，主机地址（/32、/128）通过on-link路由到达网关；
+// 网关为空时只校验地址
+func validateMgmtAddresses(addrs []string, gateway string) error {
+	for _, addr := range addrs {
+		if _, _, err := net.ParseCIDR(addr); err != nil {
+			return fmt.Errorf("management address %q is not a valid CIDR: %v", addr, err)
+		}
+	}
+	if gateway == "" {
+		return nil
+	}
+
+	gw := net.ParseIP(gateway)
+	if gw == nil {
+		return fmt.Errorf("trexConfig.Spec.MgmtGateway %q is not a valid IP address", gateway)
+	}
+	if !gatewayReachable(addrs, gw) {
+		return fmt.Errorf("trexConfig.Spec.MgmtGateway %s is not in the subnet of any management address %v", gateway, addrs)
+	}
+	return nil
+}
+
+// gatewayReachable 判断网关能否从某个同族管理地址到达
+func gatewayReachable(addrs []string, gw net.IP) bool {
+	for _, addr := range addrs {
+		_, ipNet, err := net.ParseCIDR(addr)
+		if err != nil {
+			continue
+		}
+		// 仅同一地址族的地址可以到达网关
+		if (ipNet.IP.To4() == nil) != (gw.To4() == nil) {
+			continue
+		}
+		if ones, bits := ipNet.Mask.Size(); ones == bits || ipNet.Contains(gw) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRoutes 校验静态路由：Dst须为CIDR，Gw须为与Dst同族且可从管理地址到达的IP
+func validateRoutes(spec Spec) error {
+	addrs := mgmtAddresses(spec)
+	for i, r := range spec.Routes {
+		_, dst, err := net.ParseCIDR(r.Dst)
+		if err != nil {
+			return fmt.Errorf("trexConfig.Spec.Routes[%d].Dst %q is not a valid CIDR: %v", i, r.Dst, err)
+		}
+		gw := net.ParseIP(r.Gw)
+		if gw == nil {
+			return fmt.Errorf("trexConfig.Spec.Routes[%d].Gw %q is not a valid IP address", i, r.Gw)
+		}
+		if (dst.IP.To4() == nil) != (gw.To4() == nil) {
+			return fmt.Errorf("trexConfig.Spec.Routes[%d] destination %s and gateway %s are of different address families", i, r.Dst, r.Gw)
+		}
+		if !gatewayReachable(addrs, gw) {
+			return fmt.Errorf("trexConfig.Spec.Routes[%d].Gw %s is not in the subnet of any management address %v", i, r.Gw, addrs)
+		}
+	}
+	return nil
+}
+
+// replicaNames 返回部署的全部副本名称，单副本时为部署名称本身，多副本时为<name>-0、<name>-1...
+func replicaNames(config TRExConfig) []string {
+	name := config.Metadata.Name
+	if config.Spec.Replicas <= 1 {
+		return []string{name}
+	}
+	names := make([]string, config.Spec.Replicas)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", name, i)
+	}
+	return names
+}
+
+// replicaConfigs 将部署拆分为各副本的配置：端口按顺序平均分配，管理地址按副本序号依次递增
+func replicaConfigs(config TRExConfig) ([]TRExConfig, error) {
+	if config.Spec.Replicas <= 1 {
+		config.Spec.Port = dataPorts(config.Spec)
+		return []TRExConfig{config}, nil
+	}
+
+	names := replicaNames(config)
+	portsPerReplica := len(config.Spec.Port) / config.Spec.Replicas
+	replicas := make([]TRExConfig, 0, len(names))
+	for i, name := range names {
+		replica := config
+		replica.Metadata.Name = name
+		replica.Metadata.Deployment = config.Metadata.Name
+		replica.Spec.Replicas = 1
+		if config.Spec.Hostname != "" {
+			replica.Spec.Hostname = fmt.Sprintf("%s-%d", config.Spec.Hostname, i)
+		}
+		replica.Spec.Port = config.Spec.Port[i*portsPerReplica : (i+1)*portsPerReplica]
+		replica.Spec.Port = dataPorts(replica.Spec)
+
+		var err error
+		if replica.Spec.MgmtIP != "" {
+			if replica.Spec.MgmtIP, err = offsetAddress(config.Spec.MgmtIP, i); err != nil {
+				return nil, err
+			}
+		}
+		replica.Spec.MgmtIPs = make([]string, len(config.Spec.MgmtIPs))
+		for j, ip := range config.Spec.MgmtIPs {
+			if replica.Spec.MgmtIPs[j], err = offsetAddress(ip, i); err != nil {
+				return nil, err
+			}
+		}
+		if err := validateMgmtAddresses(mgmtAddresses(replica.Spec), replica.Spec.MgmtGateway); err != nil {
+			return nil, fmt.Errorf("replica %s: %v", name, err)
+		}
+		if config.Spec.MgmtMAC != "" {
+			if replica.Spec.MgmtMAC, err = offsetMAC(config.Spec.MgmtMAC, i); err != nil {
+				return nil, err
+			}
+		}
+		replicas = append(replicas, replica)
+	}
+	return replicas, nil
+}
+
+// offsetMAC 将MAC地址的低3字节增加offset，OUI保持不变
+func offsetMAC(mac string, offset int) (string, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return "", fmt.Errorf("management MAC %q is not a valid MAC address", mac)
+	}
+	n := int(hw[3])<<16 | int(hw[4])<<8 | int(hw[5])
+	if n+offset > 0xffffff {
+		return "", fmt.Errorf("management MAC %q overflows with offset %d", mac, offset)
+	}
+	n += offset
+	hw[3], hw[4], hw[5] = byte(n>>16), byte(n>>8), byte(n)
+	return hw.String(), nil
+}
+
+// offsetAddress 将地址（可带掩码）增加offset，掩码保持不变
+func offsetAddress(addr string, offset int) (string, error) {
+	ipStr, mask, hasMask := strings.Cut(addr, "/")
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return "", fmt.Errorf("management address %q is not a valid IP address", addr)
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	result := make(net.IP, len(ip))
+	copy(result, ip)
+	carry := offset
+	for i := len(result) - 1; i >= 0 && carry > 0; i-- {
+		sum := int(result[i]) + carry
+		result[i] = byte(sum)
+		carry = sum >> 8
+	}
+	if carry > 0 {
+		return "", fmt.Errorf("management address %q overflows with offset %d", addr, offset)
+	}
+
+	if hasMask {
+		return fmt.Sprintf("%s/%s", result, mask), nil
+	}
+	return result.String(), nil
+}
@@ -0,0 +1,694 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestGenerateRandomIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidr    string
+		exclude []string
+		want    []string // 可能的结果，为空表示应返回错误
+	}{
+		{name: "/30 with one free host", cidr: "10.0.0.1/30", exclude: []string{"10.0.0.1"}, want: []string{"10.0.0.2"}},
+		{name: "/30 fully used", cidr: "10.0.0.1/30", exclude: []string{"10.0.0.1", "10.0.0.2"}},
+		{name: "/31", cidr: "10.0.0.0/31", exclude: []string{"10.0.0.0", "10.0.0.1"}},
+		{name: "/31 without exclusions", cidr: "10.0.0.0/31"},
+		{name: "/32", cidr: "10.0.0.1/32", exclude: []string{"10.0.0.1"}},
+		{name: "/29", cidr: "10.0.0.1/29", exclude: []string{"10.0.0.1", "10.0.0.2"}, want: []string{"10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"}},
+		{name: "IPv6", cidr: "2001:db8::1/64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var exclude []net.IP
+			for _, e := range tt.exclude {
+				exclude = append(exclude, net.ParseIP(e))
+			}
+			// 多次执行覆盖不同的随机起点
+			for n := 0; n < 50; n++ {
+				ip, err := generateRandomIP(tt.cidr, exclude)
+				if len(tt.want) == 0 {
+					if err == nil {
+						t.Fatalf("generateRandomIP(%s) = %s, want error", tt.cidr, ip)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("generateRandomIP(%s): %v", tt.cidr, err)
+				}
+				found := false
+				for _, w := range tt.want {
+					found = found || ip.String() == w
+				}
+				if !found {
+					t.Fatalf("generateRandomIP(%s) = %s, want one of %v", tt.cidr, ip, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPortInfosNoFreeDummyAddress(t *testing.T) {
+	port := Port{IP: "10.0.0.1/30", Gateway: "10.0.0.2"}
+	_, _, dummyIP, _, err := portInfos(port, 0)
+	if err == nil {
+		t.Fatalf("portInfos returned dummy IP %q, want error", dummyIP)
+	}
+	if !strings.Contains(err.Error(), "no free address") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// renderTrexConfig 使用固定的随机种子生成trex_cfg.yaml并返回其内容，dummy端口的地址在多次运行中保持不变
+func renderTrexConfig(t *testing.T, config TRExConfig) string {
+	t.Helper()
+	dir := *configDir
+	*configDir = t.TempDir()
+	t.Cleanup(func() { *configDir = dir })
+
+	rngMu.Lock()
+	saved := rng
+	rng = rand.New(rand.NewSource(1))
+	rngMu.Unlock()
+	t.Cleanup(func() {
+		rngMu.Lock()
+		rng = saved
+		rngMu.Unlock()
+	})
+
+	vfPCIMap := make(map[string]string)
+	for i, port := range config.Spec.Port {
+		vfPCIMap[vfIFName(config.Spec.ParentInterface, port.VFIndex)] = fmt.Sprintf("0000:3b:02.%d", i)
+	}
+	path, err := createVFConfigFile(config.Metadata.Name, vfPCIMap, config)
+	if err != nil {
+		t.Fatalf("createVFConfigFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// checkGolden 比较生成的内容与testdata中的golden文件，-update时改写golden文件
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s mismatch\n--- want\n%s\n--- got\n%s", path, want, got)
+	}
+}
+
+// twoPortConfig 两个SRIOV端口的部署，端口地址固定
+func twoPortConfig() TRExConfig {
+	return TRExConfig{
+		Metadata: Metadata{Name: "trex-test", Image: "trex:v3.04"},
+		Spec: Spec{
+			NetworkType:     "SRIOV",
+			ParentInterface: "ens1f0",
+			Cores:           1,
+			Port: []Port{
+				{VFIndex: 0, IP: "10.0.0.2/24", Gateway: "10.0.0.1"},
+				{VFIndex: 1, IP: "10.0.1.2/24", Gateway: "10.0.1.1"},
+			},
+		},
+	}
+}
+
+func TestCreateVFConfigFileCoresAndSocket(t *testing.T) {
+	config := twoPortConfig()
+	config.Spec.Port = append(config.Spec.Port,
+		Port{VFIndex: 2, IP: "10.0.2.2/24", Gateway: "10.0.2.1"},
+		Port{VFIndex: 3, IP: "10.0.3.2/24", Gateway: "10.0.3.1"},
+	)
+	config.Spec.Cores = 2
+	config.Spec.NumaSocket = 1
+	config.Spec.PortLimit = 2
+
+	got := renderTrexConfig(t, config)
+	checkGolden(t, "trex_cfg_cores_numa.yaml", got)
+
+	// 与TRex接受的配置结构一致：顶层为单个端口配置，每个dual_if使用独立的线程，0和1保留给master和latency
+	var file TrexConfigFile
+	if err := yaml.Unmarshal([]byte(got), &file); err != nil {
+		t.Fatalf("generated config is not valid YAML: %v", err)
+	}
+	if len(file) != 1 {
+		t.Fatalf("got %d port configs, want 1", len(file))
+	}
+	cfg := file[0]
+	if cfg.Version != 2 || cfg.PortLimit != 2 || cfg.Cores != 2 {
+		t.Errorf("version/port_limit/c = %d/%d/%d, want 2/2/2", cfg.Version, cfg.PortLimit, cfg.Cores)
+	}
+	if len(cfg.Interfaces) != 4 || len(cfg.PortInfo) != 4 {
+		t.Errorf("got %d interfaces and %d port_info entries, want 4 each", len(cfg.Interfaces), len(cfg.PortInfo))
+	}
+	if cfg.PortInfo[0].IP != "10.0.0.2" || cfg.PortInfo[0].DefaultGateway != "10.0.0.1" {
+		t.Errorf("port_info[0] = %+v, want ip 10.0.0.2 and default_gw 10.0.0.1", cfg.PortInfo[0])
+	}
+	if cfg.Platform.MasterThreadID != 0 || cfg.Platform.LatencyThreadID != 1 {
+		t.Errorf("master/latency threads = %d/%d, want 0/1", cfg.Platform.MasterThreadID, cfg.Platform.LatencyThreadID)
+	}
+	// sysfs中没有这些VF，socket取NumaSocket
+	wantThreads := [][]int{{2, 3}, {4, 5}}
+	if len(cfg.Platform.DualIf) != len(wantThreads) {
+		t.Fatalf("got %d dual_if entries, want %d", len(cfg.Platform.DualIf), len(wantThreads))
+	}
+	for k, dualIf := range cfg.Platform.DualIf {
+		if dualIf.Socket != 1 {
+			t.Errorf("dual_if[%d].socket = %d, want 1", k, dualIf.Socket)
+		}
+		if !slices.Equal(dualIf.Threads, wantThreads[k]) {
+			t.Errorf("dual_if[%d].threads = %v, want %v", k, dualIf.Threads, wantThreads[k])
+		}
+	}
+}
+
+func TestCreateVFConfigFileDefaultPortLimit(t *testing.T) {
+	tests := []struct {
+		ports int
+		want  int
+	}{
+		{1, 2},
+		{2, 2},
+		{3, 4},
+	}
+	for _, tt := range tests {
+		config := twoPortConfig()
+		config.Spec.Port = nil
+		for i := 0; i < tt.ports; i++ {
+			config.Spec.Port = append(config.Spec.Port, Port{VFIndex: i, IP: fmt.Sprintf("10.0.%d.2/24", i), Gateway: fmt.Sprintf("10.0.%d.1", i)})
+		}
+		var file TrexConfigFile
+		if err := yaml.Unmarshal([]byte(renderTrexConfig(t, config)), &file); err != nil {
+			t.Fatal(err)
+		}
+		// 未设置PortLimit时为全部接口数（包括dummy）
+		if file[0].PortLimit != tt.want {
+			t.Errorf("%d ports: port_limit = %d, want %d", tt.ports, file[0].PortLimit, tt.want)
+		}
+	}
+}
+
+func TestCreateVFConfigFilePortInfo(t *testing.T) {
+	mac := "00:11:22:33:44:55"
+	l2 := Port{VFIndex: 1, DestMAC: mac}
+
+	// 两个端口组成端口对，各自使用自己的寻址方式
+	config := twoPortConfig()
+	config.Spec.Port[1] = l2
+	var file TrexConfigFile
+	if err := yaml.Unmarshal([]byte(renderTrexConfig(t, config)), &file); err != nil {
+		t.Fatal(err)
+	}
+	infos := file[0].PortInfo
+	want := []TrexPortInfo{{IP: "10.0.0.2", DefaultGateway: "10.0.0.1"}, {DestMAC: mac}}
+	if !slices.Equal(infos, want) {
+		t.Errorf("paired port_info = %+v, want %+v", infos, want)
+	}
+
+	// 单个L3端口：dummy端口在同一子网内，避开端口地址和网关
+	config.Spec.Port = config.Spec.Port[:1]
+	file = nil
+	if err := yaml.Unmarshal([]byte(renderTrexConfig(t, config)), &file); err != nil {
+		t.Fatal(err)
+	}
+	infos = file[0].PortInfo
+	if len(infos) != 2 {
+		t.Fatalf("got %d port_info entries, want 2", len(infos))
+	}
+	if infos[0] != want[0] {
+		t.Errorf("port_info[0] = %+v, want %+v", infos[0], want[0])
+	}
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	dummy := net.ParseIP(infos[1].IP)
+	if dummy == nil || !subnet.Contains(dummy) || infos[1].IP == "10.0.0.2" || infos[1].IP == "10.0.0.1" || infos[1].DefaultGateway != "10.0.0.1" {
+		t.Errorf("dummy port_info %+v is not a free host of %s", infos[1], subnet)
+	}
+
+	// 单个L2端口：dummy端口同样只设置dest_mac
+	config.Spec.Port[0] = l2
+	file = nil
+	if err := yaml.Unmarshal([]byte(renderTrexConfig(t, config)), &file); err != nil {
+		t.Fatal(err)
+	}
+	if wantL2 := []TrexPortInfo{{DestMAC: mac}, {DestMAC: mac}}; !slices.Equal(file[0].PortInfo, wantL2) {
+		t.Errorf("L2 port_info = %+v, want %+v", file[0].PortInfo, wantL2)
+	}
+}
+
+func TestCreateVFConfigFileGolden(t *testing.T) {
+	unpaired := twoPortConfig()
+	unpaired.Spec.Port = unpaired.Spec.Port[:1]
+
+	tests := []struct {
+		golden string
+		config TRExConfig
+	}{
+		{"trex_cfg_unpaired.yaml", unpaired},
+		{"trex_cfg_paired.yaml", twoPortConfig()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			checkGolden(t, tt.golden, renderTrexConfig(t, tt.config))
+		})
+	}
+}
+
+func TestPortPairs(t *testing.T) {
+	port := Port{}
+	peer := Port{Peered: true}
+	tests := []struct {
+		name  string
+		ports []Port
+		want  [][2]int
+	}{
+		{"single port", []Port{port}, [][2]int{{0, -1}}},
+		{"two ports", []Port{port, port}, [][2]int{{0, 1}}},
+		{"odd ports", []Port{port, port, port}, [][2]int{{0, 1}, {2, -1}}},
+		{"peer", []Port{port, peer}, [][2]int{{0, 1}}},
+		{"peer after pair", []Port{port, port, port, peer}, [][2]int{{0, 1}, {2, 3}}},
+		{"peer splits ports", []Port{port, port, peer, port}, [][2]int{{0, -1}, {1, 2}, {3, -1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := portPairs(Spec{Port: tt.ports})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("portPairs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// validConfig 通过LoadConfig校验的最小配置
+func validConfig() TRExConfig {
+	return TRExConfig{
+		Metadata: Metadata{Name: "trex-test", Image: "trex:v3.04"},
+		Spec: Spec{
+			MgmtIP:          "192.168.100.10/24",
+			MgmtGateway:     "192.168.100.1",
+			ParentInterface: "ens1f0",
+			Port: []Port{
+				{VFIndex: 0, IP: "10.0.0.2/24", Gateway: "10.0.0.1"},
+				{VFIndex: 1, IP: "10.0.1.2/24", Gateway: "10.0.1.1"},
+			},
+		},
+	}
+}
+
+func TestLoadConfigValid(t *testing.T) {
+	config := validConfig()
+	if err := LoadConfig(&config); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+}
+
+func TestValidatePorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Spec)
+		wantErr string // 为空表示应通过校验
+	}{
+		{
+			name:   "distinct VF indices",
+			mutate: func(s *Spec) {},
+		},
+		{
+			name:    "negative VF index",
+			mutate:  func(s *Spec) { s.Port[1].VFIndex = -1 },
+			wantErr: "Port[1].VFIndex -1 must not be negative",
+		},
+		{
+			name:    "duplicate VF index",
+			mutate:  func(s *Spec) { s.Port[1].VFIndex = 0 },
+			wantErr: "use the same VFIndex 0",
+		},
+		{
+			name: "duplicate VF index in VETH mode",
+			mutate: func(s *Spec) {
+				s.NetworkType = "VETH"
+				s.Port[0].IFName = "data0"
+				s.Port[1].IFName = "data1"
+				s.Port[1].VFIndex = 0
+			},
+		},
+		{
+			name: "duplicate interface name",
+			mutate: func(s *Spec) {
+				s.Port[0].IFName = "data0"
+				s.Port[1].IFName = "data0"
+			},
+			wantErr: "use the same IFName data0",
+		},
+		{
+			name: "qinq with config template",
+			mutate: func(s *Spec) {
+				s.ConfigTemplate = "{{.Name}}"
+				s.Port[0].VlanId = 100
+				s.Port[0].VlanProto = "802.1ad"
+				s.Port[0].QinQ = 20
+			},
+		},
+		{
+			name: "qinq without config template",
+			mutate: func(s *Spec) {
+				s.Port[0].VlanId = 100
+				s.Port[0].VlanProto = "802.1ad"
+				s.Port[0].QinQ = 20
+			},
+			wantErr: "QinQ requires ConfigTemplate",
+		},
+		{
+			name: "peer reusing a port VF index",
+			mutate: func(s *Spec) {
+				s.Port = s.Port[:1]
+				s.Port[0].Peer = &Peer{VFIndex: 0, IP: "10.0.1.2/24", Gateway: "10.0.1.1"}
+			},
+			wantErr: "use the same VFIndex 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			tt.mutate(&config.Spec)
+			err := LoadConfig(&config)
+			checkErr(t, err, tt.wantErr)
+		})
+	}
+}
+
+// checkErr 检查err是否包含wantErr，wantErr为空时要求没有错误
+func checkErr(t *testing.T, err error, wantErr string) {
+	t.Helper()
+	if wantErr == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Fatalf("got no error, want %q", wantErr)
+	}
+	if !strings.Contains(err.Error(), wantErr) {
+		t.Fatalf("got error %q, want it to contain %q", err, wantErr)
+	}
+}
+
+func TestLoadConfigDualStack(t *testing.T) {
+	config := validConfig()
+	config.Spec.MgmtIPs = []string{"fd00:100::10/64", "fd00:200::10"}
+	config.Spec.Routes = []Route{{Dst: "fd00:300::/64", Gw: "fd00:100::1"}}
+	if err := LoadConfig(&config); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	// 未带掩码的IPv6地址补全为/128
+	want := []string{"192.168.100.10/24", "fd00:100::10/64", "fd00:200::10/128"}
+	if got := mgmtAddresses(config.Spec); !slices.Equal(got, want) {
+		t.Errorf("mgmtAddresses = %v, want %v", got, want)
+	}
+
+	// IPv6网关只能通过IPv6地址到达
+	config = validConfig()
+	config.Spec.MgmtGateway = "fd00:100::1"
+	checkErr(t, LoadConfig(&config), "is not in the subnet of any management address")
+	config.Spec.MgmtIPs = []string{"fd00:100::10/64"}
+	checkErr(t, LoadConfig(&config), "")
+}
+
+func TestOffsetAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		offset  int
+		want    string
+		wantErr string
+	}{
+		{addr: "192.168.100.10/24", offset: 2, want: "192.168.100.12/24"},
+		{addr: "192.168.100.255", offset: 1, want: "192.168.101.0"},
+		{addr: "fd00:100::10/64", offset: 1, want: "fd00:100::11/64"},
+		{addr: "fd00:100::ffff/64", offset: 1, want: "fd00:100::1:0/64"},
+		{addr: "fd00:200::10", offset: 3, want: "fd00:200::13"},
+		{addr: "255.255.255.255", offset: 1, wantErr: "overflows"},
+		{addr: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/64", offset: 1, wantErr: "overflows"},
+		{addr: "mgmt/24", offset: 1, wantErr: "is not a valid IP address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			got, err := offsetAddress(tt.addr, tt.offset)
+			checkErr(t, err, tt.wantErr)
+			if got != tt.want {
+				t.Errorf("offsetAddress(%q, %d) = %q, want %q", tt.addr, tt.offset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplicaConfigsDualStack(t *testing.T) {
+	config := validConfig()
+	config.Spec.MgmtIPs = []string{"fd00:100::10/64"}
+	config.Spec.Replicas = 2
+	if err := LoadConfig(&config); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	replicas, err := replicaConfigs(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"192.168.100.10/24", "fd00:100::10/64"},
+		{"192.168.100.11/24", "fd00:100::11/64"},
+	}
+	for i, replica := range replicas {
+		if got := mgmtAddresses(replica.Spec); !slices.Equal(got, want[i]) {
+			t.Errorf("replica %d addresses = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestPortInfos(t *testing.T) {
+	t.Run("L3", func(t *testing.T) {
+		ip, gateway, dummyIP, infos, err := portInfos(Port{IP: "10.0.0.2/24", Gateway: "10.0.0.1"}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ip != "10.0.0.2/24" || gateway != "10.0.0.1" {
+			t.Errorf("ip/gateway = %s/%s, want 10.0.0.2/24/10.0.0.1", ip, gateway)
+		}
+		// dummy端口在同一子网内，避开端口地址和网关
+		_, subnet, _ := net.ParseCIDR(ip)
+		dummy := net.ParseIP(dummyIP)
+		if dummy == nil || !subnet.Contains(dummy) || dummyIP == "10.0.0.2" || dummyIP == gateway {
+			t.Errorf("dummy IP %q is not a free host of %s", dummyIP, subnet)
+		}
+		// port_info中的ip不带掩码
+		want := [2]TrexPortInfo{{IP: "10.0.0.2", DefaultGateway: gateway}, {IP: dummyIP, DefaultGateway: gateway}}
+		if infos != want {
+			t.Errorf("port_info = %+v, want %+v", infos, want)
+		}
+	})
+
+	t.Run("L2", func(t *testing.T) {
+		mac := "00:11:22:33:44:55"
+		ip, gateway, dummyIP, infos, err := portInfos(Port{DestMAC: mac}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ip != "" || gateway != "" || dummyIP != "" {
+			t.Errorf("L2 port got ip/gateway/dummy %q/%q/%q, want none", ip, gateway, dummyIP)
+		}
+		// dummy端口同样只设置dest_mac
+		want := [2]TrexPortInfo{{DestMAC: mac}, {DestMAC: mac}}
+		if infos != want {
+			t.Errorf("port_info = %+v, want %+v", infos, want)
+		}
+	})
+
+	t.Run("generated address", func(t *testing.T) {
+		ip, gateway, _, _, err := portInfos(Port{}, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ip != "192.168.3.13/24" || gateway != "192.168.3.1" {
+			t.Errorf("ip/gateway = %s/%s, want 192.168.3.13/24/192.168.3.1", ip, gateway)
+		}
+	})
+}
+
+func TestValidatePortsDestMAC(t *testing.T) {
+	tests := []struct {
+		name    string
+		port    Port
+		wantErr string
+	}{
+		{name: "L3", port: Port{VFIndex: 1, IP: "10.0.1.2/24", Gateway: "10.0.1.1"}},
+		{name: "L2", port: Port{VFIndex: 1, DestMAC: "00:11:22:33:44:55"}},
+		{name: "invalid MAC", port: Port{VFIndex: 1, DestMAC: "00:11:22"}, wantErr: "is not a valid MAC address"},
+		{name: "DestMAC with IP", port: Port{VFIndex: 1, DestMAC: "00:11:22:33:44:55", IP: "10.0.1.2/24"}, wantErr: "either DestMAC or IP/Gateway, not both"},
+		{name: "DestMAC with gateway", port: Port{VFIndex: 1, DestMAC: "00:11:22:33:44:55", Gateway: "10.0.1.1"}, wantErr: "either DestMAC or IP/Gateway, not both"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			config.Spec.Port[1] = tt.port
+			checkErr(t, LoadConfig(&config), tt.wantErr)
+		})
+	}
+}
+
+func TestLoadConfigMgmtGateway(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Spec)
+		wantErr string
+	}{
+		{name: "gateway in subnet", mutate: func(s *Spec) {}},
+		{name: "no gateway", mutate: func(s *Spec) { s.MgmtGateway = "" }},
+		{
+			name: "no gateway with routes",
+			mutate: func(s *Spec) {
+				s.MgmtGateway = ""
+				s.Routes = []Route{{Dst: "10.10.0.0/16", Gw: "192.168.100.254"}}
+			},
+		},
+		{
+			name: "no gateway with unreachable route",
+			mutate: func(s *Spec) {
+				s.MgmtGateway = ""
+				s.Routes = []Route{{Dst: "10.10.0.0/16", Gw: "172.16.0.1"}}
+			},
+			wantErr: "Routes[0].Gw 172.16.0.1 is not in the subnet",
+		},
+		{
+			name:    "gateway outside subnet",
+			mutate:  func(s *Spec) { s.MgmtGateway = "192.168.200.1" },
+			wantErr: "MgmtGateway 192.168.200.1 is not in the subnet",
+		},
+		{
+			name:    "invalid gateway",
+			mutate:  func(s *Spec) { s.MgmtGateway = "gw" },
+			wantErr: "is not a valid IP address",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			tt.mutate(&config.Spec)
+			checkErr(t, LoadConfig(&config), tt.wantErr)
+		})
+	}
+}
+
+func TestReplicaConfigsWithoutGateway(t *testing.T) {
+	config := validConfig()
+	config.Spec.MgmtGateway = ""
+	config.Spec.Replicas = 2
+	if err := LoadConfig(&config); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	replicas, err := replicaConfigs(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, replica := range replicas {
+		if replica.Spec.MgmtGateway != "" {
+			t.Errorf("replica %d MgmtGateway = %q, want empty", i, replica.Spec.MgmtGateway)
+		}
+	}
+}
+
+func TestLoadConfigMgmtMAC(t *testing.T) {
+	tests := []struct {
+		name     string
+		mac      string
+		replicas int
+		wantErr  string
+	}{
+		{name: "unicast", mac: "02:42:ac:11:00:10"},
+		{name: "upper case", mac: "02:42:AC:11:00:10"},
+		{name: "replicas within range", mac: "02:42:ac:ff:ff:fe", replicas: 2},
+		{name: "malformed", mac: "02:42:ac:11:00", wantErr: "is not a valid MAC address"},
+		{name: "EUI-64", mac: "02:42:ac:11:00:10:00:01", wantErr: "is not a valid MAC address"},
+		{name: "multicast", mac: "01:00:5e:00:00:01", wantErr: "is a multicast address"},
+		{name: "broadcast", mac: "ff:ff:ff:ff:ff:ff", wantErr: "is a multicast address"},
+		{name: "replicas overflow", mac: "02:42:ac:ff:ff:ff", replicas: 2, wantErr: "overflows with offset 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			config.Spec.MgmtMAC = tt.mac
+			config.Spec.Replicas = tt.replicas
+			checkErr(t, LoadConfig(&config), tt.wantErr)
+		})
+	}
+}
+
+func TestReplicaConfigsMgmtMAC(t *testing.T) {
+	config := validConfig()
+	config.Spec.Port = append(config.Spec.Port,
+		Port{VFIndex: 2, IP: "10.0.2.2/24", Gateway: "10.0.2.1"},
+		Port{VFIndex: 3, IP: "10.0.3.2/24", Gateway: "10.0.3.1"},
+		Port{VFIndex: 4, IP: "10.0.4.2/24", Gateway: "10.0.4.1"},
+		Port{VFIndex: 5, IP: "10.0.5.2/24", Gateway: "10.0.5.1"},
+	)
+	config.Spec.MgmtMAC = "02:42:ac:11:00:ff"
+	config.Spec.Replicas = 3
+	if err := LoadConfig(&config); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	replicas, err := replicaConfigs(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 低3字节进位，OUI保持不变
+	want := []string{"02:42:ac:11:00:ff", "02:42:ac:11:01:00", "02:42:ac:11:01:01"}
+	for i, replica := range replicas {
+		if replica.Spec.MgmtMAC != want[i] {
+			t.Errorf("replica %d MgmtMAC = %s, want %s", i, replica.Spec.MgmtMAC, want[i])
+		}
+	}
+}
+
+func TestLoadConfigNoPauseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		wantErr string
+	}{
+		{policy: ""},
+		{policy: "no"},
+		{policy: "on-failure", wantErr: "NoPause requires the no restart policy"},
+		{policy: "always", wantErr: "NoPause requires the no restart policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			config := validConfig()
+			config.Spec.NoPause = true
+			config.Spec.RestartPolicy = tt.policy
+			checkErr(t, LoadConfig(&config), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"trex-controller/pkg/api"
+)
+
+// 构建信息，通过 -ldflags "-X main.version=..." 注入
+var (
+	version   = "dev"
+	gitCommit = "unknown"
+	buildDate = "unknown"
+)
+
+// VersionInfo /version 接口返回的版本信息，定义在pkg/api中
+type VersionInfo = api.VersionInfo
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(VersionInfo{
+		Version:   version,
+		GitCommit: gitCommit,
+		BuildDate: buildDate,
+	})
+}
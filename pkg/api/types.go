@@ -0,0 +1,242 @@
+// Package api 定义trex-controller API使用的配置和返回结构，供控制器、trexctl及其他Go程序共用
+package api
+
+import "time"
+
+// Metadata 部署的名称和镜像
+type Metadata struct {
+	Name       string `json:"name" yaml:"name"`
+	Image      string `json:"image" yaml:"image"`
+	Deployment string `json:"-" yaml:"-"` // 副本所属的部署名称，仅内部使用
+}
+
+// Port 数据端口
+type Port struct {
+	IFName    string `json:"ifName" yaml:"ifName"`
+	VFIndex   int    `json:"vfIndex" yaml:"vfIndex"`
+	IP        string `json:"ip" yaml:"ip"`
+	Gateway   string `json:"gateway" yaml:"gateway"`
+	VlanId    int    `json:"vlanId" yaml:"vlanId"`
+	VlanProto string `json:"vlanProto" yaml:"vlanProto"`           // SRIOV模式下VF VLAN的协议：802.1q（默认）或802.1ad（QinQ外层S-tag）
+	QinQ      int    `json:"qinq" yaml:"qinq"`                     // QinQ内层C-tag，只传递给配置模板由TRex添加，需要设置ConfigTemplate
+	VlanQos   int    `json:"vlanQos" yaml:"vlanQos"`               // SRIOV模式下VF VLAN的QoS优先级（0-7），需要设置VlanId
+	LinkState string `json:"linkState" yaml:"linkState"`           // SRIOV模式下VF的管理链路状态：auto、enable、disable，未设置时保持不变
+	DestMAC   string `json:"destMac" yaml:"destMac"`               // L2模式下的目的MAC，与IP/Gateway互斥
+	Hairpin   bool   `json:"hairpin" yaml:"hairpin"`               // VETH模式下在网桥端口上开启hairpin
+	Bridge    string `json:"bridge" yaml:"bridge"`                 // VETH模式下数据端口接入的网桥，未设置时使用Spec.BrName
+	Peer      *Peer  `json:"peer,omitempty" yaml:"peer,omitempty"` // SRIOV模式下与该端口组成端口对的第二个VF，未设置时与dummy配对
+	Peered    bool   `json:"-" yaml:"-"`                           // 由Peer展开的端口，仅控制器内部使用
+}
+
+// Peer 端口对中的第二个真实VF，VLAN设置与所属端口相同
+type Peer struct {
+	VFIndex int    `json:"vfIndex" yaml:"vfIndex"`
+	IP      string `json:"ip" yaml:"ip"`
+	Gateway string `json:"gateway" yaml:"gateway"`
+	DestMAC string `json:"destMac" yaml:"destMac"` // L2模式下的目的MAC，与IP/Gateway互斥
+}
+
+// Route 管理网的静态路由
+type Route struct {
+	Dst string `json:"dst" yaml:"dst"` // 目的网段（CIDR）
+	Gw  string `json:"gw" yaml:"gw"`   // 下一跳地址
+}
+
+// Mount 工作容器的额外挂载
+type Mount struct {
+	Source   string `json:"source" yaml:"source"`
+	Target   string `json:"target" yaml:"target"`
+	ReadOnly bool   `json:"readOnly" yaml:"readOnly"`
+}
+
+// Spec 部署的网络、容器及trex_cfg.yaml配置
+type Spec struct {
+	BrName            string   `json:"brName" yaml:"brName"`
+	MgmtIP            string   `json:"mgmtIP" yaml:"mgmtIP"`
+	MgmtIPs           []string `json:"mgmtIPs" yaml:"mgmtIPs"`
+	MgmtGateway       string   `json:"mgmtGateway" yaml:"mgmtGateway"` // 管理网默认路由的网关，为空时不添加默认路由
+	MgmtIFName        string   `json:"mgmtIFName" yaml:"mgmtIFName"`
+	MgmtMAC           string   `json:"mgmtMAC" yaml:"mgmtMAC"`         // 容器端管理网接口的MAC地址，多副本时按副本序号递增，未设置时使用内核分配的地址
+	NetworkType       string   `json:"networkType" yaml:"networkType"` // SRIOV（默认）或VETH，VETH模式下数据端口为接入网桥的veth或VLAN子接口
+	ParentInterface   string   `json:"parentInterface" yaml:"parentInterface"`
+	Port              []Port   `json:"port" yaml:"port"`
+	StopTimeout       *int     `json:"stopTimeout,omitempty" yaml:"stopTimeout,omitempty"` // 停止容器的超时时间（秒）
+	HugepagesPath     string   `json:"hugepagesPath" yaml:"hugepagesPath"`                 // 宿主机大页目录，默认/mnt/huge
+	HugepagesTarget   string   `json:"hugepagesTarget" yaml:"hugepagesTarget"`             // 容器内大页目录，默认与HugepagesPath相同
+	DisableHugepages  bool     `json:"disableHugepages" yaml:"disableHugepages"`           // 不挂载大页目录（software/af_packet模式）
+	ConfigTarget      string   `json:"configTarget" yaml:"configTarget"`                   // 容器内trex_cfg.yaml的挂载路径，默认/etc/trex_cfg.yaml
+	Mounts            []Mount  `json:"mounts" yaml:"mounts"`                               // 工作容器的额外挂载，与内置挂载目标相同时覆盖内置挂载
+	Env               []string `json:"env" yaml:"env"`                                     // 工作容器的环境变量，格式为KEY=VALUE
+	Command           []string `json:"command" yaml:"command"`                             // 工作容器的启动命令，默认保持容器运行
+	WorkingDir        string   `json:"workingDir" yaml:"workingDir"`                       // 工作容器的工作目录
+	RestartPolicy     string   `json:"restartPolicy" yaml:"restartPolicy"`                 // 工作容器和pause容器的重启策略：no（默认）、on-failure、always、unless-stopped
+	RestartMaxRetries int      `json:"restartMaxRetries" yaml:"restartMaxRetries"`         // on-failure策略的最大重试次数，0表示不限制
+	Replicas          int      `json:"replicas" yaml:"replicas"`                           // 副本数，默认1，多副本时平均分配端口
+	DriverBind        string   `json:"driverBind" yaml:"driverBind"`                       // VF绑定的驱动，目前仅支持vfio-pci，需启用-allow-driver-bind
+	Cores             int      `json:"cores" yaml:"cores"`                                 // trex_cfg.yaml中每个端口对使用的核心数(c)，默认1
+	NumaSocket        int      `json:"numaSocket" yaml:"numaSocket"`                       // 工作容器使用的NUMA节点，默认0；SRIOV部署中dual_if的socket优先取VF所在节点，节点未知时使用该值
+	AutoNuma          bool     `json:"autoNuma" yaml:"autoNuma"`                           // 根据VF所在的NUMA节点自动设置NumaSocket并限制工作容器的内存节点（cpuset.mems），仅SRIOV
+	Privileged        *bool    `json:"privileged,omitempty" yaml:"privileged,omitempty"`   // 工作容器是否以特权模式运行，默认true；false时只授予NET_ADMIN、IPC_LOCK、SYS_NICE及VFIO设备
+	NoPause           bool     `json:"noPause" yaml:"noPause"`                             // 不创建pause容器，由工作容器持有网络命名空间，先启动工作容器再配置网络
+	DNS               []string `json:"dns" yaml:"dns"`                                     // 工作容器的DNS服务器，写入挂载的/etc/resolv.conf
+	DNSSearch         []string `json:"dnsSearch" yaml:"dnsSearch"`                         // 工作容器的DNS搜索域
+	Hostname          string   `json:"hostname" yaml:"hostname"`                           // 容器的主机名，默认为部署名称，多副本时追加副本序号（<hostname>-0）
+	ExtraHosts        []string `json:"extraHosts" yaml:"extraHosts"`                       // 写入/etc/hosts的额外条目，格式为主机名:IP
+	Routes            []Route  `json:"routes" yaml:"routes"`                               // 管理网的额外静态路由
+	PullPolicy        string   `json:"pullPolicy" yaml:"pullPolicy"`                       // 镜像拉取策略：IfNotPresent（默认）、Always、Never
+	ExposeNetns       bool     `json:"exposeNetns" yaml:"exposeNetns"`                     // 将网络命名空间绑定挂载到/var/run/netns/<name>，便于ip netns exec
+	PortLimit         int      `json:"portLimit" yaml:"portLimit"`                         // trex_cfg.yaml中的port_limit，默认为全部接口数
+	ConfigTemplate    string   `json:"configTemplate" yaml:"configTemplate"`               // trex_cfg.yaml的text/template模板，主机上的绝对路径或内联模板，为空时使用内置生成
+	RawTrexConfig     string   `json:"rawTrexConfig" yaml:"rawTrexConfig"`                 // 用户提供的完整trex_cfg.yaml，主机上的绝对路径或内联内容，原样挂载，不再生成
+	VlanFiltering     bool     `json:"vlanFiltering" yaml:"vlanFiltering"`                 // 开启网桥VLAN过滤，VETH数据端口按VlanId设置PVID实现隔离
+	PromiscMode       bool     `json:"promiscMode" yaml:"promiscMode"`                     // 开启网桥的混杂模式，用于镜像等场景
+	MTU               int      `json:"mtu" yaml:"mtu"`                                     // 网桥、veth及VF的MTU，默认1500
+	TxQLen            *int     `json:"txQLen" yaml:"txQLen"`                               // veth的txqueuelen，不设置时使用内核默认值；高包速率下建议1000-10000，0会影响依赖队列长度的流量整形
+	Uplink            string   `json:"uplink" yaml:"uplink"`                               // 接入网桥的上联接口，使流量可以离开主机
+	BridgeType        string   `json:"bridgeType" yaml:"bridgeType"`                       // 网桥类型，linux（默认）或ovs
+	Profile           string   `json:"profile" yaml:"profile"`                             // 主机上的流量配置文件（.py/.yaml），只读挂载到工作容器的/etc/trex/profiles下
+	StartCommand      []string `json:"startCommand" yaml:"startCommand"`                   // /start 在工作容器中执行的命令，默认后台启动t-rex-64加载Profile
+	StopCommand       []string `json:"stopCommand" yaml:"stopCommand"`                     // /stop 在工作容器中执行的命令，默认结束t-rex-64进程
+	PublishPorts      []string `json:"publishPorts" yaml:"publishPorts"`                   // 发布到主机的端口，格式为hostPort:containerPort[/tcp|udp]，通过iptables DNAT转发到管理地址
+}
+
+// ReplicaResult 描述一个已创建副本的工作容器、生成的trex_cfg.yaml及VF到PCI地址的映射
+type ReplicaResult struct {
+	Name             string            `json:"name"`
+	ContainerID      string            `json:"containerID"`                // 工作容器ID
+	PauseContainerID string            `json:"pauseContainerID,omitempty"` // pause容器ID，NoPause时为空
+	NetnsPID         int               `json:"netnsPID"`                   // 持有网络命名空间的进程PID（pause容器，NoPause时为工作容器），可用于nsenter
+	BrName           string            `json:"brName"`
+	HostVeth         string            `json:"hostVeth"`            // 管理网veth的host端接口名
+	NetnsName        string            `json:"netnsName,omitempty"` // 开启ExposeNetns时可用于ip netns exec的名称
+	ConfigFile       string            `json:"configFile"`
+	VFPCIMap         map[string]string `json:"vfPCIMap,omitempty"`
+	VFNumaNodes      map[string]int    `json:"vfNumaNodes,omitempty"` // VF所在的NUMA节点，-1表示未知
+}
+
+// ActionResult 定义操作的结果，创建部署时包含各副本的详细信息
+type ActionResult struct {
+	Message  string          `json:"message"`
+	Replicas []ReplicaResult `json:"replicas,omitempty"`
+}
+
+// APIVersionV1 当前的配置版本，未设置apiVersion的配置按该版本处理
+const APIVersionV1 = "trex.controller/v1"
+
+// SupportedAPIVersions 控制器支持的配置版本，新版本修改默认值时旧版本配置的行为保持不变
+var SupportedAPIVersions = []string{APIVersionV1}
+
+// TRExConfig 定义TREx容器的配置
+type TRExConfig struct {
+	APIVersion string   `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"` // 配置版本，未设置时按当前版本trex.controller/v1处理
+	Kind       string   `json:"kind" yaml:"kind"`                                 // 资源类型 TrexConfig
+	Metadata   Metadata `json:"metadata" yaml:"metadata"`
+	Spec       Spec     `json:"spec" yaml:"spec"`
+}
+
+// DeploymentSummary /list 接口返回的部署概要
+type DeploymentSummary struct {
+	Name      string    `json:"name" yaml:"name"`
+	Image     string    `json:"image" yaml:"image"`
+	BrName    string    `json:"brName" yaml:"brName"`
+	UpdatedAt time.Time `json:"updatedAt" yaml:"updatedAt"`
+}
+
+// ContainerStatus 容器的运行状态，容器不存在时State为missing
+type ContainerStatus struct {
+	ID       string `json:"id,omitempty" yaml:"id,omitempty"`
+	State    string `json:"state" yaml:"state"`
+	ExitCode int    `json:"exitCode,omitempty" yaml:"exitCode,omitempty"`
+}
+
+// PortStatus 数据端口的状态
+type PortStatus struct {
+	Interface string `json:"interface" yaml:"interface"` // SRIOV模式为VF接口名，VETH模式为容器内的数据端口名
+	PCI       string `json:"pci,omitempty" yaml:"pci,omitempty"`
+	Bridge    string `json:"bridge,omitempty" yaml:"bridge,omitempty"` // VETH模式下数据端口接入的网桥
+	VLAN      string `json:"vlan" yaml:"vlan"`
+	IP        string `json:"ip,omitempty" yaml:"ip,omitempty"`
+	Gateway   string `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	DestMAC   string `json:"destMac,omitempty" yaml:"destMac,omitempty"`
+}
+
+// ReplicaStatus 单个副本的容器、网络及配置文件状态，Problems列出发现的异常
+type ReplicaStatus struct {
+	Name            string           `json:"name" yaml:"name"`
+	Worker          ContainerStatus  `json:"worker" yaml:"worker"`
+	Pause           *ContainerStatus `json:"pause,omitempty" yaml:"pause,omitempty"` // NoPause部署没有pause容器
+	BrName          string           `json:"brName" yaml:"brName"`
+	HostVeth        string           `json:"hostVeth" yaml:"hostVeth"`
+	HostVethPresent bool             `json:"hostVethPresent" yaml:"hostVethPresent"`
+	ConfigFile      string           `json:"configFile" yaml:"configFile"`
+	Ports           []PortStatus     `json:"ports" yaml:"ports"`
+	Problems        []string         `json:"problems,omitempty" yaml:"problems,omitempty"`
+}
+
+// DeploymentStatus /status接口返回的部署详细状态
+type DeploymentStatus struct {
+	Name      string          `json:"name" yaml:"name"`
+	Image     string          `json:"image" yaml:"image"`
+	UpdatedAt time.Time       `json:"updatedAt" yaml:"updatedAt"`
+	Degraded  bool            `json:"degraded" yaml:"degraded"`
+	Replicas  []ReplicaStatus `json:"replicas" yaml:"replicas"`
+}
+
+// Event 部署生命周期事件，由/events以SSE推送
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`   // started、succeeded、failed、repaired
+	Action    string    `json:"action"` // apply、update、delete或reconcile
+	Name      string    `json:"name"`
+	RequestID string    `json:"requestID,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// NetStats 网络接口的收发计数
+type NetStats struct {
+	RxBytes   uint64 `json:"rxBytes"`
+	RxPackets uint64 `json:"rxPackets"`
+	TxBytes   uint64 `json:"txBytes"`
+	TxPackets uint64 `json:"txPackets"`
+}
+
+// ContainerStats /stats接口返回的一个副本工作容器的资源使用情况
+type ContainerStats struct {
+	Name          string              `json:"name"`
+	CPUPercent    float64             `json:"cpuPercent"`
+	OnlineCPUs    uint32              `json:"onlineCPUs"`
+	MemoryUsage   uint64              `json:"memoryUsage"`
+	MemoryLimit   uint64              `json:"memoryLimit"`
+	MemoryPercent float64             `json:"memoryPercent"`
+	Networks      map[string]NetStats `json:"networks"`
+}
+
+// RestartResult 描述一个副本重启后的工作容器
+type RestartResult struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"containerID"`
+	PID         int    `json:"pid"`
+	Recreated   bool   `json:"recreated"` // 是否重建了工作容器（force）
+}
+
+// ExecResult 描述/start、/stop在一个副本的工作容器中执行命令的结果
+type ExecResult struct {
+	Name     string   `json:"name"`
+	Command  []string `json:"command"`
+	ExitCode int      `json:"exitCode"`
+	Output   string   `json:"output"`
+}
+
+// GCResult /gc接口的返回结果
+type GCResult struct {
+	DryRun      bool     `json:"dryRun"`
+	Veths       []string `json:"veths"`
+	ConfigFiles []string `json:"configFiles"`
+}
+
+// VersionInfo /version接口返回的版本信息
+type VersionInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildDate string `json:"buildDate"`
+}
@@ -0,0 +1,437 @@
+// Package client 是trex-controller API的Go客户端，trexctl也通过它访问控制器
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"trex-controller/pkg/api"
+)
+
+// Client 访问一个trex-controller实例，可以被多个goroutine并发使用
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+	retries    int
+}
+
+// Option 配置Client
+type Option func(*Client)
+
+// WithHTTPClient 使用指定的HTTP客户端，用于配置TLS和超时，默认为http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithToken 在请求中携带Bearer Token，对应控制器的-auth-token
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetries 设置GET请求在连接错误时的重试次数，按指数退避
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		c.retries = n
+	}
+}
+
+// NewClient 创建访问baseURL（如http://localhost:21111）的客户端
+func NewClient(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid controller address %q: %w", baseURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid controller address %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Action 对部署的修改操作
+type Action string
+
+const (
+	ActionApply  Action = "apply"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+type actionOptions struct {
+	recreate bool
+	force    bool
+	dryRun   bool
+}
+
+// ActionOption 设置apply/update的可选参数
+type ActionOption func(*actionOptions)
+
+// Recreate 更新时完整重建部署，不复用pause容器也不在线调整网络
+func Recreate() ActionOption {
+	return func(o *actionOptions) { o.recreate = true }
+}
+
+// Force apply时删除并重建同名部署，失败时回滚
+func Force() ActionOption {
+	return func(o *actionOptions) { o.force = true }
+}
+
+// DryRun 只校验配置，不修改任何资源
+func DryRun() ActionOption {
+	return func(o *actionOptions) { o.dryRun = true }
+}
+
+// Apply 创建部署，同名部署已存在时按配置更新
+func (c *Client) Apply(ctx context.Context, config api.TRExConfig, opts ...ActionOption) (*api.ActionResult, error) {
+	return c.submitConfig(ctx, ActionApply, config, opts)
+}
+
+// Update 更新已存在的部署
+func (c *Client) Update(ctx context.Context, config api.TRExConfig, opts ...ActionOption) (*api.ActionResult, error) {
+	return c.submitConfig(ctx, ActionUpdate, config, opts)
+}
+
+// Delete 按名称删除部署
+func (c *Client) Delete(ctx context.Context, name string) (*api.ActionResult, error) {
+	return c.submitConfig(ctx, ActionDelete, api.TRExConfig{Metadata: api.Metadata{Name: name}}, nil)
+}
+
+func (c *Client) submitConfig(ctx context.Context, action Action, config api.TRExConfig, opts []ActionOption) (*api.ActionResult, error) {
+	body, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding config: %w", err)
+	}
+	return c.Submit(ctx, action, body, "application/json", opts...)
+}
+
+// Submit 提交原始的YAML或JSON配置，可以包含多个文档。
+// 单个配置创建部署时返回各副本的详细信息，其余情况只有Message。DryRun和Force只能用于apply
+func (c *Client) Submit(ctx context.Context, action Action, body []byte, contentType string, opts ...ActionOption) (*api.ActionResult, error) {
+	var o actionOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.dryRun && action != ActionApply {
+		return nil, fmt.Errorf("dry run is only supported by %s, not %s", ActionApply, action)
+	}
+	if o.force && action != ActionApply {
+		return nil, fmt.Errorf("force is only supported by %s, not %s", ActionApply, action)
+	}
+
+	query := url.Values{}
+	if o.dryRun {
+		query.Set("dryRun", "true")
+	}
+	if o.recreate && action != ActionDelete {
+		query.Set("recreate", "true")
+	}
+	endpoint := "/" + string(action)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := c.NewRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if o.force {
+		req.Header.Set("X-Force", "true")
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var result api.ActionResult
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("error decoding response: %w", err)
+		}
+		return &result, nil
+	}
+	result.Message = strings.TrimSpace(string(data))
+	return &result, nil
+}
+
+// List 返回控制器管理的全部部署，按名称排序
+func (c *Client) List(ctx context.Context) ([]api.DeploymentSummary, error) {
+	var deployments []api.DeploymentSummary
+	if err := c.getJSON(ctx, "/list", &deployments); err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+// Status 返回部署各副本的容器、网络和配置文件状态
+func (c *Client) Status(ctx context.Context, name string) (*api.DeploymentStatus, error) {
+	var status api.DeploymentStatus
+	if err := c.getJSON(ctx, "/status/"+url.PathEscape(name), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// LogsOptions 日志查询参数
+type LogsOptions struct {
+	Tail   int  // 返回最后的行数，0表示全部
+	Follow bool // 持续输出新日志，直到ctx取消
+}
+
+// Logs 返回副本工作容器的日志流，多副本部署使用副本名称（<name>-0），调用方负责关闭。
+// HTTP客户端的Timeout同样会中断Follow的日志流，长时间跟随时应使用不设超时的客户端并通过ctx结束
+func (c *Client) Logs(ctx context.Context, name string, opts LogsOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	if opts.Tail > 0 {
+		query.Set("tail", strconv.Itoa(opts.Tail))
+	}
+	if opts.Follow {
+		query.Set("follow", "true")
+	}
+	endpoint := "/logs/" + url.PathEscape(name)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := c.NewRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		_, err := readBody(resp)
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Stats 返回部署各副本工作容器的CPU、内存和网络使用情况
+func (c *Client) Stats(ctx context.Context, name string) ([]api.ContainerStats, error) {
+	var stats []api.ContainerStats
+	if err := c.getJSON(ctx, "/stats/"+url.PathEscape(name), &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Restart 使用保存的配置重启部署各副本的工作容器，force为true时重建工作容器
+func (c *Client) Restart(ctx context.Context, name string, force bool) ([]api.RestartResult, error) {
+	endpoint := "/restart/" + url.PathEscape(name)
+	if force {
+		endpoint += "?force=true"
+	}
+	var results []api.RestartResult
+	if err := c.doJSON(ctx, "POST", endpoint, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Start 在部署各副本的工作容器中启动流量配置，返回各副本的执行结果
+func (c *Client) Start(ctx context.Context, name string) ([]api.ExecResult, error) {
+	return c.control(ctx, "start", name)
+}
+
+// Stop 停止部署各副本工作容器中的TRex，返回各副本的执行结果
+func (c *Client) Stop(ctx context.Context, name string) ([]api.ExecResult, error) {
+	return c.control(ctx, "stop", name)
+}
+
+func (c *Client) control(ctx context.Context, action, name string) ([]api.ExecResult, error) {
+	var results []api.ExecResult
+	if err := c.doJSON(ctx, "POST", "/"+action+"/"+url.PathEscape(name), &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GC 清理没有对应部署的veth和trex_cfg.yaml，dryRun为true时只返回将被清理的资源
+func (c *Client) GC(ctx context.Context, dryRun bool) (*api.GCResult, error) {
+	endpoint := "/gc"
+	if dryRun {
+		endpoint += "?dryRun=true"
+	}
+	var result api.GCResult
+	if err := c.doJSON(ctx, "POST", endpoint, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Version 返回控制器的版本信息
+func (c *Client) Version(ctx context.Context) (*api.VersionInfo, error) {
+	var info api.VersionInfo
+	if err := c.getJSON(ctx, "/version", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// TrexConfig 返回控制器为部署生成的trex_cfg.yaml
+func (c *Client) TrexConfig(ctx context.Context, name string) ([]byte, error) {
+	return c.doBody(ctx, "GET", "/config/"+url.PathEscape(name), nil, "")
+}
+
+// AppliedConfig 返回部署最后一次应用的配置（YAML，已补全默认值），部署不存在时返回ErrNotFound
+func (c *Client) AppliedConfig(ctx context.Context, name string) ([]byte, error) {
+	return c.doBody(ctx, "GET", "/config/"+url.PathEscape(name)+"?applied=true", nil, "")
+}
+
+// RenderConfig 由控制器校验body中名为name的配置并补全默认值，输出格式与AppliedConfig相同，用于比较
+func (c *Client) RenderConfig(ctx context.Context, name string, body []byte, contentType string) ([]byte, error) {
+	return c.doBody(ctx, "POST", "/config/"+url.PathEscape(name)+"?applied=true", bytes.NewReader(body), contentType)
+}
+
+// Events 订阅部署生命周期事件，对每个事件调用handle，直到连接关闭或ctx取消。
+// 事件流是长连接，HTTP客户端的Timeout同样会中断订阅，应使用不设超时的客户端
+func (c *Client) Events(ctx context.Context, handle func(api.Event)) error {
+	req, err := c.NewRequest(ctx, "GET", "/events", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		_, err := readBody(resp)
+		return err
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event api.Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("error decoding event: %w", err)
+		}
+		handle(event)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading events: %w", err)
+	}
+	return nil
+}
+
+// getJSON 发送GET请求并解码JSON响应
+func (c *Client) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	return c.doJSON(ctx, "GET", endpoint, v)
+}
+
+// doJSON 发送不带正文的请求并解码JSON响应
+func (c *Client) doJSON(ctx context.Context, method, endpoint string, v interface{}) error {
+	data, err := c.doBody(ctx, method, endpoint, nil, "")
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// doBody 发送请求并返回响应正文，contentType为空时不设置Content-Type
+func (c *Client) doBody(ctx context.Context, method, endpoint string, body io.Reader, contentType string) ([]byte, error) {
+	req, err := c.NewRequest(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readBody(resp)
+}
+
+// NewRequest 创建发往控制器的请求并设置认证信息，endpoint包含路径和查询参数
+func (c *Client) NewRequest(ctx context.Context, method, endpoint string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// Do 发送请求并原样返回响应，不检查状态码；GET请求在连接错误时按指数退避重试
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if req.Method == "GET" && c.retries > 0 {
+		attempts += c.retries
+	}
+
+	backoff := 500 * time.Millisecond
+	for i := 0; ; i++ {
+		resp, err := c.httpClient.Do(req)
+		if err == nil {
+			return resp, nil
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() && c.httpClient.Timeout > 0 {
+			err = fmt.Errorf("request timed out after %s: %w", c.httpClient.Timeout, err)
+		}
+		if i+1 >= attempts || req.Context().Err() != nil {
+			return nil, fmt.Errorf("error sending request: %w", err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-req.Context().Done():
+			return nil, fmt.Errorf("error sending request: %w", req.Context().Err())
+		}
+		backoff *= 2
+	}
+}
+
+// readBody 读取响应正文，状态码表示失败时返回*APIError
+func readBody(resp *http.Response) ([]byte, error) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	return data, nil
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"trex-controller/pkg/api"
+)
+
+// newTestClient 创建访问handler的客户端，测试结束时关闭服务
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := NewClient(server.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// dropFirst 前n个请求直接断开连接而不返回响应，模拟连接错误，之后的请求交给next处理
+func dropFirst(t *testing.T, n int32, attempts *int32, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(attempts, 1) <= n {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("hijack: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		next(w, r)
+	}
+}
+
+func TestStatusCodeErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusTooManyRequests, ErrTooManyRequests},
+		{http.StatusGatewayTimeout, ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "controller message", tt.status)
+			})
+			_, err := c.Status(context.Background(), "trex1")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Status error = %v, want errors.Is %v", err, tt.want)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Message != "controller message" {
+				t.Errorf("Status error = %#v, want *APIError with status %d and the response body", err, tt.status)
+			}
+			// 状态码只匹配对应的错误
+			if tt.want != ErrNotFound && errors.Is(err, ErrNotFound) {
+				t.Errorf("status %d matched ErrNotFound", tt.status)
+			}
+		})
+	}
+}
+
+func TestRetriesOnlyGet(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version":"v1.0.0"}`))
+	}
+
+	t.Run("GET", func(t *testing.T) {
+		var attempts int32
+		c := newTestClient(t, dropFirst(t, 1, &attempts, ok), WithRetries(1))
+		info, err := c.Version(context.Background())
+		if err != nil {
+			t.Fatalf("Version: %v", err)
+		}
+		if n := atomic.LoadInt32(&attempts); info.Version != "v1.0.0" || n != 2 {
+			t.Errorf("Version = %+v after %d attempts, want v1.0.0 after 2", info, n)
+		}
+	})
+
+	t.Run("POST", func(t *testing.T) {
+		var attempts int32
+		c := newTestClient(t, dropFirst(t, 1, &attempts, ok), WithRetries(1))
+		if _, err := c.Apply(context.Background(), api.TRExConfig{Metadata: api.Metadata{Name: "trex1"}}); err == nil {
+			t.Fatal("Apply succeeded, want the connection error")
+		}
+		if n := atomic.LoadInt32(&attempts); n != 1 {
+			t.Errorf("POST was sent %d times, want 1", n)
+		}
+	})
+}
+
+func TestSubmitOptions(t *testing.T) {
+	sent := make(chan *http.Request, 1)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		sent <- r.Clone(context.Background())
+		w.Write([]byte("ok"))
+	})
+	ctx := context.Background()
+
+	if _, err := c.Apply(ctx, api.TRExConfig{}, DryRun(), Force()); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	got := <-sent
+	if got.URL.Path != "/apply" || got.URL.Query().Get("dryRun") != "true" || got.Header.Get("X-Force") != "true" {
+		t.Errorf("apply request = %s %s with X-Force %q, want dryRun=true and X-Force true", got.Method, got.URL, got.Header.Get("X-Force"))
+	}
+
+	// update和delete不支持预演和force，不能发出会忽略这些选项的请求
+	tests := []struct {
+		action Action
+		opt    ActionOption
+		want   string
+	}{
+		{ActionUpdate, DryRun(), "dry run is only supported by apply"},
+		{ActionDelete, DryRun(), "dry run is only supported by apply"},
+		{ActionUpdate, Force(), "force is only supported by apply"},
+		{ActionDelete, Force(), "force is only supported by apply"},
+	}
+	for _, tt := range tests {
+		_, err := c.Submit(ctx, tt.action, []byte("{}"), "application/json", tt.opt)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Submit(%s) error = %v, want %q", tt.action, err, tt.want)
+		}
+		select {
+		case got := <-sent:
+			t.Errorf("Submit(%s) sent %s %s, want no request", tt.action, got.Method, got.URL)
+		default:
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 按HTTP状态码区分的API错误，可用errors.Is判断，例如 errors.Is(err, client.ErrNotFound)
+var (
+	ErrBadRequest      = errors.New("bad request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrNotFound        = errors.New("not found")
+	ErrBodyTooLarge    = errors.New("request body too large")
+	ErrTooManyRequests = errors.New("too many concurrent deployments")
+	ErrTimeout         = errors.New("timed out waiting for the Docker daemon")
+)
+
+// APIError trex-controller返回的错误响应，Message为响应正文
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return http.StatusText(e.StatusCode)
+	}
+	return e.Message
+}
+
+// Is 将状态码映射为对应的错误，没有对应错误的状态码只能通过StatusCode判断
+func (e *APIError) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusRequestEntityTooLarge:
+		return target == ErrBodyTooLarge
+	case http.StatusTooManyRequests:
+		return target == ErrTooManyRequests
+	case http.StatusGatewayTimeout:
+		return target == ErrTimeout
+	}
+	return false
+}
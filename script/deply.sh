@@ -15,4 +15,5 @@ echo "Use 'docker logs trex-controller' to view console logs"
 docker run -it --network=host --cap-add=ALL \
   -v /var/run/docker.sock:/var/run/docker.sock \
   -v /var/log/trex:/var/log  -v /tmp/trex:/tmp/trex \
+  -v /var/lib/trex-controller:/var/lib/trex-controller -v /mnt/huge:/mnt/huge \
   registry.cn-beijing.aliyuncs.com/killmaster/trex-controller:trex-controller
\ No newline at end of file
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"trex-controller/pkg/api"
+)
+
+// 查询 trex-controller 管理的全部部署
+func listDeployments() ([]api.DeploymentSummary, error) {
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.List(context.Background())
+}
+
+// completeDeploymentNames 补全部署名称，controller不可达时静默返回空结果
+func completeDeploymentNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	deployments, err := listDeployments()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, d := range deployments {
+		if strings.HasPrefix(d.Name, toComplete) {
+			names = append(names, d.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
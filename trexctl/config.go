@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:               "config NAME",
+	Short:             "Print the trex_cfg.yaml generated for a deployment",
+	Args:              cobra.ExactArgs(1),
+	Run:               configHandler,
+	ValidArgsFunction: completeDeploymentNames,
+}
+
+func configHandler(cmd *cobra.Command, args []string) {
+	if err := printConfig(args[0]); err != nil {
+		fmt.Printf("Get config failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// 获取 trex-controller 为部署生成的 trex_cfg.yaml 并原样输出
+func printConfig(name string) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	content, err := c.TrexConfig(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(content))
+	return nil
+}
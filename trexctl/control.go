@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var startCmd = &cobra.Command{
+	Use:               "start NAME",
+	Short:             "Start the traffic profile in a deployment's worker containers",
+	Args:              cobra.ExactArgs(1),
+	Run:               controlHandler("start"),
+	ValidArgsFunction: completeDeploymentNames,
+}
+
+var stopCmd = &cobra.Command{
+	Use:               "stop NAME",
+	Short:             "Stop TRex in a deployment's worker containers",
+	Args:              cobra.ExactArgs(1),
+	Run:               controlHandler("stop"),
+	ValidArgsFunction: completeDeploymentNames,
+}
+
+func controlHandler(action string) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		if err := runControl(action, args[0]); err != nil {
+			fmt.Printf("%s failed: %v\n", strings.ToUpper(action[:1])+action[1:], err)
+			os.Exit(1)
+		}
+	}
+}
+
+// 请求 trex-controller 在工作容器中执行启动/停止命令，任一副本退出码非0时返回错误
+func runControl(action, name string) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	run := c.Start
+	if action == "stop" {
+		run = c.Stop
+	}
+	results, err := run(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if !quiet {
+			fmt.Printf("%s: exit code %d\n", result.Name, result.ExitCode)
+			if out := strings.TrimRight(result.Output, "\n"); out != "" {
+				fmt.Println(out)
+			}
+		}
+		if result.ExitCode != 0 {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("command exited with non-zero code in %d of %d containers", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"trex-controller/pkg/client"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff -f FILE|DIR",
+	Short: "Show differences between configuration files and the deployed configuration",
+	Long: `Show a unified diff between each configuration in the files and the configuration
+last applied to the deployment with the same name. Both sides are rendered by the
+controller with defaults filled in, so only real changes are shown.
+
+Exit status is 0 if nothing differs, 1 if any configuration differs and 2 on errors.`,
+	Args: cobra.NoArgs,
+	Run:  diffHandler,
+}
+
+func init() {
+	diffCmd.Flags().StringArrayVarP(&files, "file", "f", nil, "Configuration file or directory, may be repeated (required)")
+	diffCmd.Flags().StringVar(&contentType, "type", "", "Content type of the configuration (yaml|json), required when reading from a terminal with -f -")
+	diffCmd.MarkFlagRequired("file")
+}
+
+func diffHandler(cmd *cobra.Command, args []string) {
+	paths, err := expandFiles(files)
+	if err != nil {
+		fmt.Printf("Diff failed: %v\n", err)
+		os.Exit(2)
+	}
+	if len(paths) == 0 {
+		fmt.Println("Diff failed: no configuration files found")
+		os.Exit(2)
+	}
+
+	c, err := newClient()
+	if err != nil {
+		fmt.Printf("Diff failed: %v\n", err)
+		os.Exit(2)
+	}
+
+	changed := false
+	for _, path := range paths {
+		differs, err := diffFile(c, path)
+		if err != nil {
+			fmt.Printf("Diff %s failed: %v\n", path, err)
+			os.Exit(2)
+		}
+		changed = changed || differs
+	}
+	if changed {
+		os.Exit(1)
+	}
+}
+
+// 比较文件中的每个配置与对应部署保存的配置，输出统一格式的差异，返回是否存在差异
+func diffFile(c *client.Client, path string) (bool, error) {
+	content, err := readConfig(path)
+	if err != nil {
+		return false, err
+	}
+	mediaType := contentTypeFor(path, content)
+	headers, err := decodeHeaders(content, mediaType)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for i, h := range headers {
+		name := h.Metadata.Name
+		if name == "" {
+			return false, fmt.Errorf("document %d: metadata.name is empty", i)
+		}
+		// 部署不存在时本地配置全部显示为新增
+		live, err := c.AppliedConfig(context.Background(), name)
+		if err != nil && !errors.Is(err, client.ErrNotFound) {
+			return false, err
+		}
+		local, err := c.RenderConfig(context.Background(), name, content, mediaType)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(live, local) {
+			continue
+		}
+		changed = true
+		fmt.Printf("--- %s (deployed)\n+++ %s (%s)\n", name, name, path)
+		fmt.Print(unifiedDiff(splitLines(string(live)), splitLines(string(local)), 3))
+	}
+	return changed, nil
+}
+
+// 按行拆分，每行保留换行符
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// 按最长公共子序列生成统一格式的差异，每个变更块前后保留 context 行上下文
+func unifiedDiff(a, b []string, context int) string {
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type edit struct {
+		op   byte // ' '、'-' 或 '+'
+		line string
+		ai   int // 该行在 a 中的下标（'+' 时为插入位置）
+		bi   int
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', a[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(edits); {
+		// 找到下一个变更，向前保留上下文
+		first := start
+		for first < len(edits) && edits[first].op == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		from := max(first-context, start)
+
+		// 相邻变更之间的相同行不超过 2*context 时合并为一个块
+		end := first
+		for k := first; k < len(edits); k++ {
+			if edits[k].op != ' ' {
+				end = k + 1
+			} else if k-end >= 2*context {
+				break
+			}
+		}
+		to := min(end+context, len(edits))
+
+		aStart, bStart, aCount, bCount := edits[from].ai, edits[from].bi, 0, 0
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				aCount++
+			}
+			if e.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, e := range edits[from:to] {
+			out.WriteByte(e.op)
+			out.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				out.WriteString("\n")
+			}
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// 统一格式中的行范围，空范围的起始行为其前一行
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
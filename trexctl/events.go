@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"trex-controller/pkg/api"
+	"trex-controller/pkg/client"
+)
+
+var eventsFollow bool
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Stream deployment lifecycle events from the controller",
+	Args:  cobra.NoArgs,
+	Run:   eventsHandler,
+}
+
+func init() {
+	eventsCmd.Flags().BoolVarP(&eventsFollow, "follow", "f", false, "Reconnect and keep streaming when the connection is closed")
+}
+
+func eventsHandler(cmd *cobra.Command, args []string) {
+	// 事件流是长连接，不使用 --timeout；重连时复用同一个客户端
+	c, err := newStreamClient()
+	if err != nil {
+		fmt.Printf("Events failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	for {
+		err := c.Events(context.Background(), printEvent)
+		// 认证失败等HTTP错误重连也无法恢复
+		var rejected *client.APIError
+		if !eventsFollow || errors.As(err, &rejected) {
+			if err != nil {
+				fmt.Printf("Events failed: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Events stream interrupted: %v, reconnecting...\n", err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func printEvent(event api.Event) {
+	line := fmt.Sprintf("%s  %-9s %-9s %s", event.Time.Local().Format(time.RFC3339), event.Type, event.Action, event.Name)
+	if event.Message != "" {
+		line += ": " + event.Message
+	}
+	if event.RequestID != "" {
+		line += fmt.Sprintf(" (request %s)", event.RequestID)
+	}
+	fmt.Println(line)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var gcDryRun bool
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Clean up orphaned veths and config files on the controller host",
+	Args:  cobra.NoArgs,
+	Run:   gcHandler,
+}
+
+func init() {
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "Only report what would be deleted")
+}
+
+func gcHandler(cmd *cobra.Command, args []string) {
+	if err := runGC(); err != nil {
+		fmt.Printf("GC failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// 请求 trex-controller 清理孤立的 veth 和配置文件，JSON格式化输出结果，--quiet 时不输出
+func runGC() error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	result, err := c.GC(context.Background(), gcDryRun)
+	if err != nil {
+		return err
+	}
+	if quiet {
+		return nil
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+	"trex-controller/pkg/api"
+)
+
+var getOutput string
+
+var getCmd = &cobra.Command{
+	Use:               "get NAME",
+	Short:             "Show detailed status of a deployment",
+	Args:              cobra.ExactArgs(1),
+	Run:               getHandler,
+	ValidArgsFunction: completeDeploymentNames,
+}
+
+func init() {
+	getCmd.Flags().StringVarP(&getOutput, "output", "o", "", "Output format (yaml|json), human-readable by default")
+}
+
+func getHandler(cmd *cobra.Command, args []string) {
+	status, err := getStatus(args[0])
+	if err != nil {
+		fmt.Printf("Get %s failed: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	switch getOutput {
+	case "json":
+		data, _ := json.MarshalIndent(status, "", "  ")
+		fmt.Println(string(data))
+	case "yaml":
+		data, err := yaml.Marshal(status)
+		if err != nil {
+			fmt.Printf("Get %s failed: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		fmt.Print(string(data))
+	case "":
+		printStatus(status)
+	default:
+		fmt.Printf("Get %s failed: invalid --output %q, must be yaml or json\n", args[0], getOutput)
+		os.Exit(1)
+	}
+}
+
+// 获取部署的详细状态
+func getStatus(name string) (*api.DeploymentStatus, error) {
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.Status(context.Background(), name)
+}
+
+func printStatus(s *api.DeploymentStatus) {
+	health := "Healthy"
+	if s.Degraded {
+		health = "DEGRADED"
+	}
+	fmt.Printf("Name:     %s\n", s.Name)
+	fmt.Printf("Image:    %s\n", s.Image)
+	fmt.Printf("Updated:  %s\n", s.UpdatedAt.Local().Format(time.RFC3339))
+	fmt.Printf("Status:   %s\n", health)
+
+	for _, r := range s.Replicas {
+		fmt.Printf("\nReplica %s:\n", r.Name)
+		fmt.Printf("  Worker:       %s\n", formatContainer(r.Worker))
+		if r.Pause != nil {
+			fmt.Printf("  Pause:        %s\n", formatContainer(*r.Pause))
+		}
+		fmt.Printf("  Bridge:       %s\n", r.BrName)
+		veth := r.HostVeth
+		if !r.HostVethPresent {
+			veth += " (missing)"
+		}
+		fmt.Printf("  Host veth:    %s\n", veth)
+		fmt.Printf("  Config file:  %s\n", r.ConfigFile)
+
+		fmt.Println("  Ports:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "    INTERFACE\tPCI/BRIDGE\tVLAN\tIP\tGATEWAY/DEST MAC")
+		for _, p := range r.Ports {
+			next := p.Gateway
+			if p.DestMAC != "" {
+				next = p.DestMAC
+			}
+			// SRIOV端口显示VF的PCI地址，VETH端口显示接入的网桥
+			attach := p.PCI
+			if attach == "" {
+				attach = p.Bridge
+			}
+			fmt.Fprintf(w, "    %s\t%s\t%s\t%s\t%s\n", p.Interface, dash(attach), p.VLAN, dash(p.IP), dash(next))
+		}
+		w.Flush()
+
+		if len(r.Problems) > 0 {
+			fmt.Println("  Problems:")
+			for _, p := range r.Problems {
+				fmt.Printf("    ! %s\n", p)
+			}
+		}
+	}
+}
+
+func formatContainer(c api.ContainerStatus) string {
+	if c.ID == "" {
+		return c.State
+	}
+	id := c.ID
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	if c.State == "exited" {
+		return fmt.Sprintf("%s (exited, code %d)", id, c.ExitCode)
+	}
+	return fmt.Sprintf("%s (%s)", id, c.State)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
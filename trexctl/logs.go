@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"trex-controller/pkg/client"
+)
+
+var (
+	logsTail   int
+	logsFollow bool
+)
+
+var logsCmd = &cobra.Command{
+	Use:               "logs NAME",
+	Short:             "Print the logs of a deployment's worker container",
+	Long:              "Print the logs of a deployment's worker container. Replicas of a multi-replica deployment are addressed as NAME-0, NAME-1, ...",
+	Args:              cobra.ExactArgs(1),
+	Run:               logsHandler,
+	ValidArgsFunction: completeDeploymentNames,
+}
+
+func init() {
+	logsCmd.Flags().IntVar(&logsTail, "tail", 0, "Number of lines to show from the end of the logs (0 shows all)")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Follow the log output")
+}
+
+func logsHandler(cmd *cobra.Command, args []string) {
+	if err := printLogs(args[0]); err != nil {
+		fmt.Printf("Get logs of %s failed: %v\n", args[0], err)
+		os.Exit(1)
+	}
+}
+
+// 输出工作容器的日志，--follow 时持续输出直到连接关闭
+func printLogs(name string) error {
+	// 跟随日志是长连接，不使用 --timeout
+	newLogsClient := newClient
+	if logsFollow {
+		newLogsClient = newStreamClient
+	}
+	c, err := newLogsClient()
+	if err != nil {
+		return err
+	}
+
+	logs, err := c.Logs(context.Background(), name, client.LogsOptions{Tail: logsTail, Follow: logsFollow})
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	_, err = io.Copy(os.Stdout, logs)
+	return err
+}
@@ -2,13 +2,22 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"trex-controller/pkg/api"
+	"trex-controller/pkg/client"
 )
 
 const (
@@ -21,38 +30,72 @@ var rootCmd = &cobra.Command{
 }
 
 var applyCmd = &cobra.Command{
-	Use:   "apply -f FILE",
+	Use:   "apply -f FILE|DIR",
 	Short: "Apply configuration from file",
 	Run:   applyHandler,
 }
 
 var updateCmd = &cobra.Command{
-	Use:   "update -f FILE",
+	Use:   "update -f FILE|DIR",
 	Short: "Update configuration from file",
 	Run:   updateHandler,
 }
 
 var deleteCmd = &cobra.Command{
-	Use:   "delete -f FILE",
-	Short: "Delete configuration from file",
-	Run:   deleteHandler,
+	Use:               "delete (-f FILE|DIR | NAME...)",
+	Short:             "Delete configuration from file or by deployment name",
+	Run:               deleteHandler,
+	ValidArgsFunction: completeDeploymentNames,
 }
 
-var file string
+var (
+	files              []string
+	server             string
+	caCert             string
+	insecureSkipVerify bool
+	token              string
+	contentType        string
+	quiet              bool
+	timeout            time.Duration
+	retries            int
+	recreate           bool
+	force              bool
+)
 
 func init() {
-	// 为所有命令添加文件标志
-	applyCmd.Flags().StringVarP(&file, "file", "f", "", "Configuration file (required)")
-	updateCmd.Flags().StringVarP(&file, "file", "f", "", "Configuration file (required)")
-	deleteCmd.Flags().StringVarP(&file, "file", "f", "", "Configuration file (required)")
+	// 全局连接参数
+	rootCmd.PersistentFlags().StringVar(&server, "server", controllerURL, "trex-controller address")
+	rootCmd.PersistentFlags().StringVar(&caCert, "ca-cert", "", "CA certificate used to verify the controller's TLS certificate")
+	rootCmd.PersistentFlags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "Skip verification of the controller's TLS certificate")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 60*time.Second, "Timeout for each request to the controller")
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", 0, "Number of retries for read-only requests on connection errors")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Do not print the controller's response on success")
+	rootCmd.PersistentFlags().StringVar(&token, "token", os.Getenv("TREX_TOKEN"), "Bearer token for the controller API (env TREX_TOKEN)")
+
+	// 为所有命令添加文件标志，可重复指定，也可以是目录
+	applyCmd.Flags().StringArrayVarP(&files, "file", "f", nil, "Configuration file or directory, may be repeated (required)")
+	updateCmd.Flags().StringArrayVarP(&files, "file", "f", nil, "Configuration file or directory, may be repeated (required)")
+	deleteCmd.Flags().StringArrayVarP(&files, "file", "f", nil, "Configuration file or directory, may be repeated")
+
+	// 从标准输入读取时指定内容类型
+	for _, cmd := range []*cobra.Command{applyCmd, updateCmd, deleteCmd} {
+		cmd.Flags().StringVar(&contentType, "type", "", "Content type of the configuration (yaml|json), required when reading from a terminal with -f -")
+	}
+
+	// 更新时完整重建部署，不复用pause容器也不在线调整网络
+	for _, cmd := range []*cobra.Command{applyCmd, updateCmd} {
+		cmd.Flags().BoolVar(&recreate, "recreate", false, "Always recreate the whole deployment on update instead of reusing the pause container or reconfiguring in place")
+	}
+
+	// apply时覆盖同名部署，包括未被控制器跟踪的残留容器
+	applyCmd.Flags().BoolVar(&force, "force", false, "Delete and recreate the deployment if the name already exists, rolling back on failure")
 
 	// 标记文件标志为必需
 	applyCmd.MarkFlagRequired("file")
 	updateCmd.MarkFlagRequired("file")
-	deleteCmd.MarkFlagRequired("file")
 
 	// 添加子命令
-	rootCmd.AddCommand(applyCmd, updateCmd, deleteCmd)
+	rootCmd.AddCommand(applyCmd, updateCmd, deleteCmd, validateCmd, getCmd, configCmd, gcCmd, startCmd, stopCmd, restartCmd, diffCmd, eventsCmd, statsCmd, logsCmd, versionCmd)
 }
 
 func main() {
@@ -62,87 +105,281 @@ func main() {
 	}
 }
 
+// 根据命令行参数创建HTTP客户端
+func newHTTPClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	if caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in %s", caCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
+
+// 根据命令行参数创建 trex-controller 客户端，每个命令只创建一次并用于该命令的全部请求
+func newClient() (*client.Client, error) {
+	hc, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.NewClient(server, client.WithHTTPClient(hc), client.WithToken(token), client.WithRetries(retries))
+}
+
+// 创建用于日志、事件等长连接的客户端，不使用 --timeout
+func newStreamClient() (*client.Client, error) {
+	hc, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	hc.Timeout = 0
+	return client.NewClient(server, client.WithHTTPClient(hc), client.WithToken(token), client.WithRetries(retries))
+}
+
 // 发送请求到 trex-controller
-func sendToController(action, filePath string) error {
-	// 读取文件内容
-	content, err := os.ReadFile(filePath)
+func sendToController(c *client.Client, action, filePath string) error {
+	switch contentType {
+	case "", "yaml", "yml", "json":
+	default:
+		return fmt.Errorf("invalid --type %q, must be yaml or json", contentType)
+	}
+
+	// 读取文件内容，"-" 表示从标准输入读取
+	content, err := readConfig(filePath)
 	if err != nil {
-		return fmt.Errorf("error reading file: %w", err)
+		return err
 	}
 
-	// 根据操作确定端点
-	endpoint := ""
+	// 根据操作确定请求及参数
+	var opts []client.ActionOption
+	var clientAction client.Action
 	switch action {
 	case "apply":
-		endpoint = "/apply"
+		clientAction = client.ActionApply
 	case "update":
-		endpoint = "/update"
+		clientAction = client.ActionUpdate
 	case "delete":
-		endpoint = "/delete"
+		clientAction = client.ActionDelete
+	case "validate":
+		// 服务端校验使用 dry-run apply，不会修改任何资源
+		clientAction = client.ActionApply
+		opts = append(opts, client.DryRun())
 	default:
 		return fmt.Errorf("invalid action: %s", action)
 	}
+	if recreate && (action == "apply" || action == "update") {
+		opts = append(opts, client.Recreate())
+	}
+	if force && action == "apply" {
+		opts = append(opts, client.Force())
+	}
+
+	result, err := c.Submit(context.Background(), clientAction, content, contentTypeFor(filePath, content), opts...)
+	if err != nil {
+		return err
+	}
+	return printResult(result)
+}
+
+// 输出操作结果，创建部署时格式化输出各副本的详细信息，--quiet 时不输出
+func printResult(result *api.ActionResult) error {
+	if quiet {
+		return nil
+	}
+	if len(result.Replicas) > 0 {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	if result.Message != "" {
+		fmt.Println(result.Message)
+	}
+	return nil
+}
+
+// 读取配置内容，"-" 表示从标准输入读取
+func readConfig(filePath string) ([]byte, error) {
+	if filePath != "-" {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file: %w", err)
+		}
+		return content, nil
+	}
 
-	// 创建请求
-	url := controllerURL + endpoint
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(content))
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 && contentType == "" {
+		return nil, fmt.Errorf("no input piped to stdin; pipe a configuration or set --type yaml|json")
+	}
+	content, err := io.ReadAll(os.Stdin)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("error reading stdin: %w", err)
+	}
+	return content, nil
+}
+
+// 确定请求的内容类型：--type 优先，其次根据文件扩展名，标准输入根据内容判断
+func contentTypeFor(filePath string, content []byte) string {
+	ext := "." + contentType
+	if contentType == "" {
+		ext = filepath.Ext(filePath)
+	}
+	if contentType == "" && filePath == "-" {
+		ext = ".yaml"
+		if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			ext = ".json"
+		}
 	}
 
-	// 设置内容类型（根据文件扩展名）
-	ext := filepath.Ext(filePath)
 	switch ext {
 	case ".yaml", ".yml":
-		req.Header.Set("Content-Type", "application/yaml")
+		return "application/yaml"
 	case ".json":
-		req.Header.Set("Content-Type", "application/json")
+		return "application/json"
 	default:
-		req.Header.Set("Content-Type", "text/plain")
+		return "text/plain"
+	}
+}
+
+// 展开文件参数，目录递归查找 *.yaml、*.yml 和 *.json 文件
+func expandFiles(paths []string) ([]string, error) {
+	var result []string
+	for _, path := range paths {
+		if path == "-" {
+			result = append(result, path)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file: %w", err)
+		}
+		if !info.IsDir() {
+			result = append(result, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			switch filepath.Ext(p) {
+			case ".yaml", ".yml", ".json":
+				if !d.IsDir() {
+					result = append(result, p)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading directory %s: %w", path, err)
+		}
 	}
+	return result, nil
+}
 
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// 对每个文件依次执行操作，出错时继续处理剩余文件，最后输出汇总结果
+func runForFiles(c *client.Client, action, label string) {
+	// 单个文件保持原有行为
+	if len(files) == 1 {
+		if info, err := os.Stat(files[0]); files[0] == "-" || err != nil || !info.IsDir() {
+			if err := sendToController(c, action, files[0]); err != nil {
+				fmt.Printf("%s failed: %v\n", label, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	paths, err := expandFiles(files)
 	if err != nil {
-		return fmt.Errorf("error sending request: %w", err)
+		fmt.Printf("%s failed: %v\n", label, err)
+		os.Exit(1)
+	}
+	if len(paths) == 0 {
+		fmt.Printf("%s failed: no configuration files found\n", label)
+		os.Exit(1)
 	}
-	defer resp.Body.Close()
 
-	// 处理响应
-	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("%s", string(body))
+	failed := 0
+	results := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if err := sendToController(c, action, path); err != nil {
+			failed++
+			results = append(results, fmt.Sprintf("  %s: FAILED: %v", path, strings.TrimSpace(err.Error())))
+			continue
+		}
+		results = append(results, fmt.Sprintf("  %s: OK", path))
 	}
 
-	// 解析成功响应
-	//var result string
-	//if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-	//	return fmt.Errorf("error decoding response: %w", err)
-	//}
-	//
-	//fmt.Printf("Success: %s\n", result)
-	return nil
+	fmt.Printf("%s summary (%d succeeded, %d failed):\n", label, len(paths)-failed, failed)
+	for _, result := range results {
+		fmt.Println(result)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
 }
 
 // 命令处理函数
 func applyHandler(cmd *cobra.Command, args []string) {
-	if err := sendToController("apply", file); err != nil {
-		fmt.Println("Apply failed:", err)
-		os.Exit(1)
-	}
+	runForFiles(mustClient("Apply"), "apply", "Apply")
 }
 
 func updateHandler(cmd *cobra.Command, args []string) {
-	if err := sendToController("update", file); err != nil {
-		fmt.Println("Update failed:", err)
+	runForFiles(mustClient("Update"), "update", "Update")
+}
+
+// 创建客户端，失败时以 label 为前缀输出错误并退出
+func mustClient(label string) *client.Client {
+	c, err := newClient()
+	if err != nil {
+		fmt.Printf("%s failed: %v\n", label, err)
 		os.Exit(1)
 	}
+	return c
 }
 
 func deleteHandler(cmd *cobra.Command, args []string) {
-	if err := sendToController("delete", file); err != nil {
-		fmt.Println("Delete failed:", err)
+	if len(files) == 0 && len(args) == 0 {
+		fmt.Println("Delete failed: either -f FILE or at least one NAME is required")
 		os.Exit(1)
 	}
+
+	c := mustClient("Delete")
+	if len(files) > 0 {
+		runForFiles(c, "delete", "Delete")
+	}
+
+	for _, name := range args {
+		if err := deleteByName(c, name); err != nil {
+			fmt.Printf("Delete %s failed: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// 按名称删除部署，删除操作只需要 metadata.name
+func deleteByName(c *client.Client, name string) error {
+	result, err := c.Delete(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	return printResult(result)
 }
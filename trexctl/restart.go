@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var restartForce bool
+
+var restartCmd = &cobra.Command{
+	Use:               "restart NAME",
+	Short:             "Restart a deployment's worker containers using the last applied config",
+	Args:              cobra.ExactArgs(1),
+	Run:               restartHandler,
+	ValidArgsFunction: completeDeploymentNames,
+}
+
+func init() {
+	restartCmd.Flags().BoolVar(&restartForce, "force", false, "Recreate the worker containers instead of restarting them in place")
+}
+
+func restartHandler(cmd *cobra.Command, args []string) {
+	if err := runRestart(args[0]); err != nil {
+		fmt.Printf("Restart failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// 请求 trex-controller 重启部署的工作容器，输出新的容器ID和PID
+func runRestart(name string) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	results, err := c.Restart(context.Background(), name, restartForce)
+	if err != nil {
+		return err
+	}
+	if quiet {
+		return nil
+	}
+	for _, result := range results {
+		verb := "restarted"
+		if result.Recreated {
+			verb = "recreated"
+		}
+		fmt.Printf("%s: %s, container %.12s, PID %d\n", result.Name, verb, result.ContainerID, result.PID)
+	}
+	return nil
+}
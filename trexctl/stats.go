@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"trex-controller/pkg/api"
+)
+
+var (
+	statsWatch    bool
+	statsInterval time.Duration
+)
+
+var statsCmd = &cobra.Command{
+	Use:               "stats NAME",
+	Short:             "Show CPU, memory and network usage of a deployment",
+	Args:              cobra.ExactArgs(1),
+	Run:               statsHandler,
+	ValidArgsFunction: completeDeploymentNames,
+}
+
+func init() {
+	statsCmd.Flags().BoolVarP(&statsWatch, "watch", "w", false, "Refresh the stats periodically")
+	statsCmd.Flags().DurationVar(&statsInterval, "interval", 2*time.Second, "Refresh interval with --watch")
+}
+
+func statsHandler(cmd *cobra.Command, args []string) {
+	c, err := newClient()
+	if err != nil {
+		fmt.Printf("Get stats failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	for {
+		stats, err := c.Stats(context.Background(), args[0])
+		if err != nil {
+			fmt.Printf("Get stats failed: %v\n", err)
+			os.Exit(1)
+		}
+		if statsWatch {
+			// 清屏并将光标移到左上角
+			fmt.Print("\033[H\033[2J")
+		}
+		printStats(stats)
+		if !statsWatch {
+			return
+		}
+		time.Sleep(statsInterval)
+	}
+}
+
+func printStats(stats []api.ContainerStats) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCPU %\tMEM USAGE / LIMIT\tMEM %\tINTERFACE\tRX\tTX")
+	for _, s := range stats {
+		prefix := fmt.Sprintf("%s\t%.2f%%\t%s / %s\t%.2f%%", s.Name, s.CPUPercent, formatBytes(s.MemoryUsage), formatBytes(s.MemoryLimit), s.MemoryPercent)
+
+		var ifNames []string
+		for ifName := range s.Networks {
+			ifNames = append(ifNames, ifName)
+		}
+		sort.Strings(ifNames)
+		if len(ifNames) == 0 {
+			fmt.Fprintf(w, "%s\t-\t-\t-\n", prefix)
+			continue
+		}
+		for i, ifName := range ifNames {
+			n := s.Networks[ifName]
+			if i > 0 {
+				prefix = "\t\t\t"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s (%d pkts)\t%s (%d pkts)\n", prefix, ifName, formatBytes(n.RxBytes), n.RxPackets, formatBytes(n.TxBytes), n.TxPackets)
+		}
+	}
+	w.Flush()
+}
+
+// formatBytes 以二进制单位格式化字节数
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f%ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+	"trex-controller/pkg/api"
+	"trex-controller/pkg/client"
+)
+
+var validateServerSide bool
+
+var validateCmd = &cobra.Command{
+	Use:   "validate -f FILE|DIR",
+	Short: "Validate configuration files without applying them",
+	Args:  cobra.NoArgs,
+	Run:   validateHandler,
+}
+
+func init() {
+	validateCmd.Flags().StringArrayVarP(&files, "file", "f", nil, "Configuration file or directory, may be repeated (required)")
+	validateCmd.Flags().StringVar(&contentType, "type", "", "Content type of the configuration (yaml|json), required when reading from a terminal with -f -")
+	validateCmd.Flags().BoolVar(&validateServerSide, "server-side", false, "Also validate on the controller with a dry-run apply")
+	validateCmd.MarkFlagRequired("file")
+}
+
+// configHeader 本地校验只关心的配置字段
+type configHeader struct {
+	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string `json:"kind" yaml:"kind"`
+	Metadata   struct {
+		Name  string `json:"name" yaml:"name"`
+		Image string `json:"image" yaml:"image"`
+	} `json:"metadata" yaml:"metadata"`
+}
+
+func validateHandler(cmd *cobra.Command, args []string) {
+	paths, err := expandFiles(files)
+	if err != nil {
+		fmt.Printf("Validate failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(paths) == 0 {
+		fmt.Println("Validate failed: no configuration files found")
+		os.Exit(1)
+	}
+
+	var c *client.Client
+	if validateServerSide {
+		c = mustClient("Validate")
+	}
+
+	failed := 0
+	for _, path := range paths {
+		err := validateFile(path)
+		if err == nil && validateServerSide {
+			err = sendToController(c, "validate", path)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("  %s: INVALID: %v\n", path, strings.TrimSpace(err.Error()))
+			continue
+		}
+		fmt.Printf("  %s: OK\n", path)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// 在本地解析配置文件并检查 apiVersion 和必填的 metadata 字段
+func validateFile(path string) error {
+	content, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+
+	headers, err := decodeHeaders(content, contentTypeFor(path, content))
+	if err != nil {
+		return err
+	}
+	for i, h := range headers {
+		if h.APIVersion != "" && !slices.Contains(api.SupportedAPIVersions, h.APIVersion) {
+			return fmt.Errorf("document %d: apiVersion %q is not supported, must be one of %s", i, h.APIVersion, strings.Join(api.SupportedAPIVersions, ", "))
+		}
+		if h.Metadata.Name == "" {
+			return fmt.Errorf("document %d: metadata.name is empty", i)
+		}
+		if h.Metadata.Image == "" {
+			return fmt.Errorf("document %d: metadata.image is empty", i)
+		}
+	}
+	return nil
+}
+
+// 按内容类型解码配置，YAML 支持以"---"分隔的多个文档，JSON 支持顶层数组
+func decodeHeaders(content []byte, mediaType string) ([]configHeader, error) {
+	if mediaType == "application/json" {
+		if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+			var headers []configHeader
+			if err := json.Unmarshal(content, &headers); err != nil {
+				return nil, fmt.Errorf("invalid JSON: %w", err)
+			}
+			return headers, nil
+		}
+		var h configHeader
+		if err := json.Unmarshal(content, &h); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return []configHeader{h}, nil
+	}
+	if mediaType != "application/yaml" {
+		return nil, fmt.Errorf("unsupported file type, must be .yaml, .yml or .json")
+	}
+
+	var headers []configHeader
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var h configHeader
+		err := decoder.Decode(&h)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		headers = append(headers, h)
+	}
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("no configuration found")
+	}
+	return headers, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"trex-controller/pkg/api"
+)
+
+// 构建信息，通过 -ldflags "-X main.version=..." 注入
+var (
+	version   = "dev"
+	gitCommit = "unknown"
+	buildDate = "unknown"
+)
+
+var clientOnly bool
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the client and server version information",
+	Run:   versionHandler,
+}
+
+func init() {
+	versionCmd.Flags().BoolVar(&clientOnly, "client", false, "Only print the client version")
+}
+
+// 查询 trex-controller 的版本信息
+func getServerVersion() (*api.VersionInfo, error) {
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.Version(context.Background())
+}
+
+func versionHandler(cmd *cobra.Command, args []string) {
+	fmt.Printf("Client Version: %s (commit: %s, built: %s)\n", version, gitCommit, buildDate)
+	if clientOnly {
+		return
+	}
+
+	info, err := getServerVersion()
+	if err != nil {
+		fmt.Printf("Server Version: unavailable (%v)\n", err)
+		return
+	}
+	fmt.Printf("Server Version: %s (commit: %s, built: %s)\n", info.Version, info.GitCommit, info.BuildDate)
+
+	if info.Version != version || info.GitCommit != gitCommit {
+		fmt.Println("Warning: client and server versions do not match")
+	}
+}